@@ -4,117 +4,130 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"path/filepath"
 	"strings"
 
 	"github.com/spf13/cobra"
 
-	askquery "scry/internal/query/ask"
-	"scry/pkg/metadata"
-	"scry/pkg/search"
-	"scry/pkg/workspace"
+	"scry/pkg/ask"
+	"scry/pkg/config"
 )
 
-func newAskCmd() *cobra.Command {
+func newAskCmd(cfg *config.Config) *cobra.Command {
 	var limit int
+	var explain bool
 	cmd := &cobra.Command{
 		Use:   "ask <question>",
 		Short: "RAG-style answers with citations",
 		Args:  cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			root, err := os.Getwd()
-			if err != nil {
-				return exitError{code: exitRuntimeError, err: err}
-			}
-			root, err = filepath.Abs(root)
-			if err != nil {
-				return exitError{code: exitRuntimeError, err: err}
-			}
-			paths := workspace.Resolve(root)
-			if !workspace.Exists(paths) {
-				return exitError{code: exitIndexMissing, err: fmt.Errorf("index not found; run `scry index`")}
+			if !cmd.Flags().Changed("k") {
+				limit = cfg.Ask.K
 			}
-			store, err := metadata.Open(paths.IndexDBPath)
+			ctx, cancel := commandContext(cmd)
+			defer cancel()
+			searcher, release, err := openSearcher(ctx, cmd, cfg)
 			if err != nil {
-				return exitError{code: exitRuntimeError, err: err}
+				return err
 			}
-			engine := search.New(store)
-			question := strings.Join(args, " ")
-			results, err := engine.Search(question, limit)
+			defer release()
+			asker := &ask.Asker{Searcher: searcher, Options: ask.Options{
+				K:            limit,
+				MaxEvidence:  cfg.Ask.MaxEvidence,
+				SnippetChars: cfg.Ask.SnippetChars,
+				MinScore:     cfg.Ask.MinScore,
+				Rules:        cfg.Ask.Rules,
+				Analyzer:     cfg.Analyzer.Lexical(),
+			}}
+			answer, err := asker.Ask(ctx, strings.Join(args, " "))
 			if err != nil {
-				return exitError{code: exitRuntimeError, err: err}
+				return runError("ask", err)
 			}
-			jsonOut, _ := cmd.Flags().GetBool("json")
-			terms := askquery.TokenizeQuery(question)
-			var candidates []askquery.Chunk
-			for _, r := range results {
-				candidates = append(candidates, askquery.Chunk{
-					ID:        r.Chunk.ID,
-					FilePath:  r.Chunk.FilePath,
-					StartLine: r.Chunk.StartLine,
-					EndLine:   r.Chunk.EndLine,
-					Text:      r.Chunk.Content,
-					Score:     r.Score,
-				})
-			}
-
-			decision := askquery.BuildPipeline(candidates, terms, askquery.AskOptions{
-				MaxEvidence:  2,
-				SnippetChars: 240,
-				MinScore:     1.0,
-			})
 
-			if len(decision.Evidence) == 0 {
+			jsonOut, _ := cmd.Flags().GetBool("json")
+			if !answer.Known() {
 				hint := "No relevant evidence found in indexed chunks."
 				if jsonOut {
 					_ = json.NewEncoder(os.Stdout).Encode(map[string]any{
 						"type":   "answer",
-						"text":   "I don't know.",
-						"reason": decision.Reason,
+						"text":   answer.Text,
+						"reason": answer.Reason,
 						"hint":   hint,
 					})
 				} else {
-					fmt.Fprintln(os.Stdout, "I don't know.")
+					fmt.Fprintln(os.Stdout, answer.Text)
 					fmt.Fprintln(os.Stdout, hint)
 				}
 				return nil
 			}
 
-			answerHeader := askquery.AnswerHeader(decision.Evidence)
 			if jsonOut {
 				_ = json.NewEncoder(os.Stdout).Encode(map[string]any{
 					"type": "answer",
-					"text": answerHeader,
+					"text": answer.Text,
 				})
-				for i, ev := range decision.Evidence {
-					_ = json.NewEncoder(os.Stdout).Encode(map[string]any{
+				for i, ev := range answer.Evidence {
+					out := map[string]any{
 						"type":       "evidence",
 						"id":         i + 1,
 						"snippet":    ev.Snippet,
 						"path":       ev.Chunk.FilePath,
 						"start_line": ev.Chunk.StartLine,
 						"end_line":   ev.Chunk.EndLine,
-					})
+					}
+					if ev.Chunk.Repo != "" {
+						out["repo"] = ev.Chunk.Repo
+					}
+					if explain {
+						out["explain"] = ev.Chunk.Explain()
+					}
+					_ = json.NewEncoder(os.Stdout).Encode(out)
 				}
 				_ = json.NewEncoder(os.Stdout).Encode(map[string]any{
 					"type": "summary",
-					"k":    len(decision.Evidence),
+					"k":    len(answer.Evidence),
 				})
 				return nil
 			}
 
 			fmt.Fprintln(os.Stdout, "Answer:")
-			fmt.Fprintln(os.Stdout, answerHeader)
+			fmt.Fprintln(os.Stdout, answer.Text)
 			fmt.Fprintln(os.Stdout, "")
 			fmt.Fprintln(os.Stdout, "Evidence:")
-			for i, ev := range decision.Evidence {
-				fmt.Fprintf(os.Stdout, "[%d] %s:%d-%d\n", i+1, ev.Chunk.FilePath, ev.Chunk.StartLine, ev.Chunk.EndLine)
+			for i, ev := range answer.Evidence {
+				fmt.Fprintf(os.Stdout, "[%d] %s%s:%d-%d\n", i+1, repoLabel(ev.Chunk.Repo), ev.Chunk.FilePath, ev.Chunk.StartLine, ev.Chunk.EndLine)
 				fmt.Fprintf(os.Stdout, "    %s\n", ev.Snippet)
+				if explain {
+					e := ev.Chunk.Explain()
+					fmt.Fprintf(os.Stdout, "    search: %s\n", formatExplanation(e.Search))
+					preferred := ""
+					if e.Preferred {
+						preferred = " (preferred over single-term matches)"
+					}
+					fmt.Fprintf(os.Stdout, "    matches: %d distinct terms%s\n", e.MatchCount, preferred)
+					fmt.Fprintf(os.Stdout, "    rules: %s\n", formatFirings(e.Rules))
+					fmt.Fprintf(os.Stdout, "    score: %.2f\n", e.Score)
+				}
 			}
 			return nil
 		},
 	}
 	addCommonFlags(cmd)
-	cmd.Flags().IntVar(&limit, "k", 6, "number of context chunks")
+	addTestsFlag(cmd)
+	addTimeoutFlag(cmd)
+	addScopeFlag(cmd)
+	addReposFlags(cmd)
+	cmd.Flags().IntVar(&limit, "k", config.Default().Ask.K, "number of context chunks (default from config)")
+	cmd.Flags().BoolVar(&explain, "explain", false, "break each evidence score down by term, match count and rule")
 	return cmd
 }
+
+func formatFirings(fired []ask.Firing) string {
+	if len(fired) == 0 {
+		return "none"
+	}
+	parts := make([]string, len(fired))
+	for i, f := range fired {
+		parts[i] = fmt.Sprintf("%s (%s %+g)", f.Rule, f.Kind, f.Delta)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"scry/pkg/config"
+)
+
+func newConfigCmd(cfg *config.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect configuration",
+	}
+	cmd.AddCommand(newConfigShowCmd(cfg))
+	return cmd
+}
+
+func newConfigShowCmd(cfg *config.Config) *cobra.Command {
+	var origin bool
+	cmd := &cobra.Command{
+		Use:   "show",
+		Short: "Print the effective value of every config key",
+		Long: "Prints each key after merging the defaults, the user config\n" +
+			"(" + config.UserPath() + "), the repo config and SCRY_*\n" +
+			"environment variables, later layers winning. Command-line flags\n" +
+			"override all of them for the command they are given to.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			jsonOut, _ := cmd.Flags().GetBool("json")
+			if jsonOut {
+				enc := json.NewEncoder(os.Stdout)
+				for _, s := range cfg.Settings() {
+					out := map[string]any{
+						"type":  "config",
+						"key":   s.Key,
+						"value": s.Value,
+					}
+					if origin {
+						layer := s.Origin.Layer
+						if layer == "" {
+							layer = config.LayerDefault
+						}
+						out["layer"] = layer
+						if s.Origin.Path != "" {
+							out["source"] = s.Origin.Path
+						}
+						if s.Origin.Line > 0 {
+							out["line"] = s.Origin.Line
+						}
+					}
+					_ = enc.Encode(out)
+				}
+				return nil
+			}
+			tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			for _, s := range cfg.Settings() {
+				if origin {
+					fmt.Fprintf(tw, "%s = %s\t# %s\n", s.Key, s.Value, s.Origin)
+				} else {
+					fmt.Fprintf(tw, "%s = %s\n", s.Key, s.Value)
+				}
+			}
+			return tw.Flush()
+		},
+	}
+	addCommonFlags(cmd)
+	cmd.Flags().BoolVar(&origin, "origin", false, "show which layer set each value")
+	return cmd
+}
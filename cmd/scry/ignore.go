@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"scry/pkg/config"
+	"scry/pkg/ignore"
+)
+
+func newIgnoreCmd(cfg *config.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "ignore",
+		Short: "Inspect ignore rules",
+	}
+	cmd.AddCommand(newIgnoreCheckCmd(cfg))
+	return cmd
+}
+
+func newIgnoreCheckCmd(cfg *config.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "check <path>...",
+		Aliases: []string{"explain"},
+		Short:   "Show whether paths are ignored and by which pattern",
+		Long: "Reports, for each path, the pattern that decides whether it is ignored,\n" +
+			"formatted like `git check-ignore -v -n`: <source>:<line>:<pattern>\\t<path>.\n" +
+			"Paths that no pattern matches are printed as ::\\t<path>.",
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root, err := repoRoot(cmd)
+			if err != nil {
+				return exitError{code: exitRuntimeError, err: err}
+			}
+			matcher, err := ignore.LoadOptions(root, cfg.Ignore.MatcherOptions())
+			if err != nil {
+				return exitError{code: exitRuntimeError, err: err}
+			}
+			jsonOut, _ := cmd.Flags().GetBool("json")
+			for _, arg := range args {
+				rel, isDir, err := relToRoot(root, arg)
+				if err != nil {
+					return exitError{code: exitUsageError, err: err}
+				}
+				pattern, ignored := matcher.Explain(rel, isDir)
+				if jsonOut {
+					out := map[string]any{
+						"type":    "ignore",
+						"path":    arg,
+						"ignored": ignored,
+					}
+					if pattern != nil {
+						out["source"] = pattern.Source
+						out["line"] = pattern.Line
+						out["pattern"] = pattern.Text
+					}
+					_ = json.NewEncoder(os.Stdout).Encode(out)
+					continue
+				}
+				if pattern == nil {
+					fmt.Fprintf(os.Stdout, "::\t%s\n", arg)
+					continue
+				}
+				fmt.Fprintf(os.Stdout, "%s:%s\t%s\n", pattern.Location(), pattern.Text, arg)
+			}
+			return nil
+		},
+	}
+	addCommonFlags(cmd)
+	return cmd
+}
+
+// relToRoot converts a command-line path into a slash-separated path relative
+// to root, reporting whether it names a directory.
+func relToRoot(root, arg string) (string, bool, error) {
+	abs, err := filepath.Abs(arg)
+	if err != nil {
+		return "", false, err
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false, fmt.Errorf("%s: outside repository %s", arg, root)
+	}
+	isDir := strings.HasSuffix(arg, "/")
+	if info, err := os.Stat(abs); err == nil {
+		isDir = info.IsDir()
+	}
+	return filepath.ToSlash(rel), isDir, nil
+}
@@ -2,70 +2,142 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
-	"path/filepath"
 
 	"github.com/spf13/cobra"
 
+	"scry/pkg/config"
 	"scry/pkg/indexer"
+	"scry/pkg/workspace"
 )
 
-func newIndexCmd() *cobra.Command {
+func newIndexCmd(cfg *config.Config) *cobra.Command {
 	var (
 		clean        bool
 		noEmbeddings bool
+		gitMode      bool
+		untracked    bool
+		jobs         int
+		batchSize    int
+		verify       bool
+		wait         bool
 	)
 	cmd := &cobra.Command{
 		Use:   "index",
 		Short: "Create or update local indexes",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			root, err := os.Getwd()
+			root, err := repoRoot(cmd)
 			if err != nil {
 				return exitError{code: exitRuntimeError, err: err}
 			}
-			root, err = filepath.Abs(root)
-			if err != nil {
-				return exitError{code: exitRuntimeError, err: err}
+			if jobs < 0 {
+				return exitError{code: exitUsageError, err: fmt.Errorf("--jobs must not be negative")}
+			}
+			if batchSize < 0 {
+				return exitError{code: exitUsageError, err: fmt.Errorf("--batch-size must not be negative")}
 			}
 			jsonOut, _ := cmd.Flags().GetBool("json")
+			scanOpts := cfg.ScanOptions()
+			if cmd.Flags().Changed("git") {
+				scanOpts.Git = gitMode
+			}
+			if cmd.Flags().Changed("untracked") {
+				scanOpts.Untracked = untracked
+			}
 			opts := indexer.Options{
-				Root:         root,
-				Clean:        clean,
-				NoEmbeddings: noEmbeddings,
-				JSON:         jsonOut,
+				Root:          root,
+				Clean:         clean,
+				NoEmbeddings:  noEmbeddings,
+				JSON:          jsonOut,
+				Scan:          scanOpts,
+				Storage:       cfg.Index.Storage(),
+				MaxChunkLines: cfg.Chunking.MaxLines,
+				Analyzer:      cfg.Analyzer.Lexical(),
+				Jobs:          jobs,
+				BatchSize:     batchSize,
+				Verify:        verify,
+				Wait:          wait,
 			}
-			emit := func(p indexer.Progress) {
-				if jsonOut {
-					enc := json.NewEncoder(os.Stdout)
-					_ = enc.Encode(p)
-					return
-				}
-				switch p.Stage {
-				case "scan":
-					fmt.Fprintf(os.Stdout, "scan: %d files\\n", p.FilesTotal)
-				case "index":
-					fmt.Fprintf(os.Stdout, "indexed: %s (%d chunks)\\n", p.File, p.Chunks)
-				}
+			emit := progressPrinter(jsonOut)
+			ctx, cancel := commandContext(cmd)
+			defer cancel()
+			summary, err := indexer.Run(ctx, opts, emit)
+			if errors.Is(err, workspace.ErrLocked) {
+				return exitError{code: exitRuntimeError, err: fmt.Errorf("%w (use --wait to wait for it)", err)}
 			}
-			summary, err := indexer.Run(opts, emit)
 			if err != nil {
-				return exitError{code: exitRuntimeError, err: err}
-			}
-			if jsonOut {
-				_ = json.NewEncoder(os.Stdout).Encode(map[string]any{
-					"type":           "summary",
-					"files_indexed":  summary.FilesIndexed,
-					"chunks_indexed": summary.ChunksIndexed,
-				})
-			} else {
-				fmt.Fprintf(os.Stdout, "done: %d files, %d chunks\\n", summary.FilesIndexed, summary.ChunksIndexed)
+				return runError("index", err)
 			}
+			printSummary(jsonOut, summary)
 			return nil
 		},
 	}
 	addCommonFlags(cmd)
+	addTimeoutFlag(cmd)
 	cmd.Flags().BoolVar(&clean, "clean", false, "rebuild index from scratch")
 	cmd.Flags().BoolVar(&noEmbeddings, "no-embeddings", false, "skip embeddings")
+	cmd.Flags().BoolVar(&gitMode, "git", false, "list files from the git index instead of walking the tree")
+	cmd.Flags().BoolVar(&untracked, "untracked", false, "with --git, also index untracked files that are not ignored")
+	cmd.Flags().IntVarP(&jobs, "jobs", "j", 0, "files to read and parse concurrently (default: number of CPUs)")
+	cmd.Flags().BoolVar(&wait, "wait", false, "wait for another index run to finish instead of failing")
+	cmd.Flags().BoolVar(&verify, "verify", false, "hash every file instead of trusting unchanged size and mtime")
+	cmd.Flags().IntVar(&batchSize, "batch-size", 0, fmt.Sprintf("files written per transaction (default %d)", indexer.DefaultBatchSize))
 	return cmd
 }
+
+// progressPrinter writes indexer progress as JSON lines or human text.
+func progressPrinter(jsonOut bool) func(indexer.Progress) {
+	return func(p indexer.Progress) {
+		if jsonOut {
+			enc := json.NewEncoder(os.Stdout)
+			_ = enc.Encode(p)
+			return
+		}
+		switch p.Stage {
+		case "scan":
+			fmt.Fprintf(os.Stdout, "scan: %d files\n", p.FilesTotal)
+		case "seed":
+			fmt.Fprintf(os.Stdout, "seeded from: %s\n", p.Message)
+		case "rebuild":
+			if p.Reason == "chunking_changed" {
+				fmt.Fprintln(os.Stdout, "chunking settings changed: rebuilding the index")
+			} else {
+				fmt.Fprintln(os.Stdout, "analyzer settings changed: rebuilding the index")
+			}
+		case "skip":
+			fmt.Fprintf(os.Stdout, "skipped: %s (%s)\n", p.File, p.Reason)
+		case "verify":
+			fmt.Fprintf(os.Stdout, "changed without stat change: %s\n", p.File)
+		case "delete":
+			fmt.Fprintf(os.Stdout, "removed: %s\n", p.File)
+		case "index":
+			if p.Reused > 0 {
+				fmt.Fprintf(os.Stdout, "indexed: %s (%d chunks, %d unchanged)\n", p.File, p.Chunks, p.Reused)
+			} else {
+				fmt.Fprintf(os.Stdout, "indexed: %s (%d chunks)\n", p.File, p.Chunks)
+			}
+		case "watch":
+			fmt.Fprintln(os.Stdout, p.Message)
+		}
+		if p.Type == "error" {
+			fmt.Fprintf(os.Stderr, "error: %s\n", p.Message)
+		}
+	}
+}
+
+func printSummary(jsonOut bool, summary indexer.Summary) {
+	if jsonOut {
+		_ = json.NewEncoder(os.Stdout).Encode(map[string]any{
+			"type":           "summary",
+			"files_indexed":  summary.FilesIndexed,
+			"chunks_indexed": summary.ChunksIndexed,
+			"chunks_reused":  summary.ChunksReused,
+			"files_racy":     summary.FilesRacy,
+			"files_deleted":  summary.FilesDeleted,
+		})
+		return
+	}
+	fmt.Fprintf(os.Stdout, "done: %d files, %d chunks\n", summary.FilesIndexed, summary.ChunksIndexed)
+}
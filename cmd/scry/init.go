@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"scry/pkg/config"
+	"scry/pkg/workspace"
+)
+
+func newInitCmd(cfg *config.Config) *cobra.Command {
+	var gitignore bool
+	cmd := &cobra.Command{
+		Use:   "init",
+		Short: "Set up the workspace, a starter config and ignore suggestions",
+		Long: "Creates .scry/, writes a commented starter config unless one exists,\n" +
+			"and adds ignore patterns for dependency and build directories found in\n" +
+			"the repository to .scryignore. With --gitignore, also adds .scry/ to\n" +
+			".gitignore. Running it again only fills in what is missing.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root, err := repoRoot(cmd)
+			if err != nil {
+				return exitError{code: exitRuntimeError, err: err}
+			}
+			configPath, err := filepath.Abs(cfg.Path)
+			if err != nil {
+				return exitError{code: exitRuntimeError, err: err}
+			}
+			steps, err := workspace.Init(workspace.ResolveStorage(root, cfg.Index.Storage()), workspace.InitOptions{
+				ConfigPath: configPath,
+				Config:     config.Starter(),
+				Gitignore:  gitignore,
+			})
+			if err != nil {
+				return exitError{code: exitRuntimeError, err: err}
+			}
+			jsonOut, _ := cmd.Flags().GetBool("json")
+			for _, step := range steps {
+				if jsonOut {
+					_ = json.NewEncoder(os.Stdout).Encode(struct {
+						Type string `json:"type"`
+						workspace.InitStep
+					}{"init", step})
+					continue
+				}
+				line := fmt.Sprintf("%-10s %s", step.Action, step.Path)
+				if len(step.Patterns) > 0 {
+					line += " (" + strings.Join(step.Patterns, ", ") + ")"
+				}
+				if step.Detail != "" {
+					line += ": " + step.Detail
+				}
+				fmt.Fprintln(os.Stdout, line)
+			}
+			return nil
+		},
+	}
+	addCommonFlags(cmd)
+	cmd.Flags().BoolVar(&gitignore, "gitignore", false, "add .scry/ to .gitignore if git does not ignore it")
+	return cmd
+}
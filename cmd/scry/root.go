@@ -1,13 +1,21 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
 
 	"github.com/spf13/cobra"
 
 	"scry/pkg/config"
+	"scry/pkg/metadata"
+	"scry/pkg/search"
+	"scry/pkg/workspace"
 )
 
 const (
@@ -36,7 +44,10 @@ func execute() int {
 	root := newRootCmd()
 	root.SilenceErrors = true
 	root.SilenceUsage = true
-	if err := root.Execute(); err != nil {
+	// Commands see Ctrl-C and SIGTERM as cancellation of cmd.Context().
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := root.ExecuteContext(ctx); err != nil {
 		var ee exitError
 		if errors.As(err, &ee) {
 			if !ee.silent && ee.err != nil {
@@ -52,6 +63,7 @@ func execute() int {
 
 func newRootCmd() *cobra.Command {
 	var configPath string
+	cfg := config.Default()
 	root := &cobra.Command{
 		Use:   "scry",
 		Short: "Local-first codebase memory engine",
@@ -66,30 +78,79 @@ func newRootCmd() *cobra.Command {
 				return nil
 			}
 			explicit := cmd.Flags().Changed("config")
-			if err := loadConfig(configPath, explicit); err != nil {
+			path := configPath
+			if !explicit {
+				root, err := repoRoot(cmd)
+				if err != nil {
+					return exitError{code: exitRuntimeError, err: err}
+				}
+				path = filepath.Join(root, ".scry.yml")
+			}
+			loaded, err := loadConfig(path, explicit)
+			if err != nil {
 				return exitError{code: exitRuntimeError, err: err}
 			}
+			cfg = loaded
 			return nil
 		},
 	}
 
-	root.PersistentFlags().StringVarP(&configPath, "config", "c", "", "Config file (default: .memengine.yml)")
+	root.PersistentFlags().StringVarP(&configPath, "config", "c", "", "repo config file (default: .scry.yml in the workspace root)")
+	root.PersistentFlags().String("root", "", "workspace root (default: nearest directory with .scry/, .scry.yml or .git)")
 
-	root.AddCommand(newIndexCmd())
-	root.AddCommand(newSearchCmd())
-	root.AddCommand(newAskCmd())
-	root.AddCommand(newStatusCmd())
+	root.AddCommand(newInitCmd(&cfg))
+	root.AddCommand(newIndexCmd(&cfg))
+	root.AddCommand(newSearchCmd(&cfg))
+	root.AddCommand(newAskCmd(&cfg))
+	root.AddCommand(newStatusCmd(&cfg))
 	root.AddCommand(newImpactCmd())
+	root.AddCommand(newIgnoreCmd(&cfg))
+	root.AddCommand(newWatchCmd(&cfg))
+	root.AddCommand(newConfigCmd(&cfg))
+	root.AddCommand(newWorkspaceCmd())
 
 	return root
 }
 
-func loadConfig(path string, required bool) error {
-	_, err := config.Load(path, required)
+// loadConfig merges the user config, the repo config at path and SCRY_*
+// environment variables over the defaults.
+func loadConfig(path string, required bool) (config.Config, error) {
+	cfg, err := config.LoadSources(config.Sources{
+		User:         config.UserPath(),
+		Repo:         path,
+		RepoRequired: required,
+		Env:          os.LookupEnv,
+	})
+	if err != nil {
+		return config.Config{}, fmt.Errorf("config: %w", err)
+	}
+	return cfg, nil
+}
+
+// repoRoot returns the workspace root: --root if given, otherwise the
+// nearest directory at or above the working directory holding .scry/,
+// .scry.yml or .git, falling back to the working directory itself.
+func repoRoot(cmd *cobra.Command) (string, error) {
+	if flag, _ := cmd.Flags().GetString("root"); flag != "" {
+		return filepath.Abs(flag)
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	root, _ := workspace.FindRoot(cwd)
+	return root, nil
+}
+
+// openSnapshot opens the index for reading in a single read transaction, so
+// an index run committing mid-command cannot mix two versions. release must
+// be called when done.
+func openSnapshot(ctx context.Context, paths workspace.Paths) (*metadata.DB, func(), error) {
+	store, err := metadata.OpenSnapshot(ctx, paths.IndexDBPath)
 	if err != nil {
-		return fmt.Errorf("config: %w", err)
+		return nil, nil, err
 	}
-	return nil
+	return store, func() { _ = store.Close() }, nil
 }
 
 func addCommonFlags(cmd *cobra.Command) {
@@ -97,6 +158,67 @@ func addCommonFlags(cmd *cobra.Command) {
 	cmd.Flags().Bool("quiet", false, "suppress progress output")
 }
 
+func addTimeoutFlag(cmd *cobra.Command) {
+	cmd.Flags().Duration("timeout", 0, "give up after this long (0: no limit)")
+}
+
+// commandContext derives the context a command runs under, applying its
+// --timeout flag if it has one.
+func commandContext(cmd *cobra.Command) (context.Context, context.CancelFunc) {
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+	if timeout > 0 {
+		return context.WithTimeout(cmd.Context(), timeout)
+	}
+	return context.WithCancel(cmd.Context())
+}
+
+// runError maps an error from a command's work to an exit error, reporting
+// interruptions and timeouts by name.
+func runError(what string, err error) error {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return exitError{code: exitRuntimeError, err: fmt.Errorf("%s interrupted", what)}
+	case errors.Is(err, context.DeadlineExceeded):
+		return exitError{code: exitRuntimeError, err: fmt.Errorf("%s timed out", what)}
+	}
+	return exitError{code: exitRuntimeError, err: err}
+}
+
+func addScopeFlag(cmd *cobra.Command) {
+	cmd.Flags().Bool("here", false, "only return results under the current directory")
+}
+
+// cwdScope returns the working directory relative to root, for scoping
+// results to it.
+func cwdScope(root string) (string, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(root, cwd)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("current directory is outside the workspace root %s", root)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+func addTestsFlag(cmd *cobra.Command) {
+	cmd.Flags().String("tests", "", "test chunks: include|downrank|exclude|only (default from config)")
+}
+
+// testMode resolves the --tests flag, falling back to the configured preset.
+func testMode(cmd *cobra.Command, cfg *config.Config) (search.TestMode, error) {
+	flag, _ := cmd.Flags().GetString("tests")
+	if flag == "" && cfg.Ignore.Tests == config.TestsSeparate {
+		return search.TestsDownRank, nil
+	}
+	mode, err := search.ParseTestMode(flag)
+	if err != nil {
+		return "", exitError{code: exitUsageError, err: err}
+	}
+	return mode, nil
+}
+
 func runNotImplemented(cmd *cobra.Command, args []string) error {
 	fmt.Fprintln(os.Stdout, "not implemented")
 	return exitError{code: exitRuntimeError, silent: true}
@@ -1,47 +1,39 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
-	"path/filepath"
 	"strings"
 
 	"github.com/spf13/cobra"
 
+	"scry/pkg/config"
+	"scry/pkg/indexer"
 	"scry/pkg/metadata"
 	"scry/pkg/search"
 	"scry/pkg/workspace"
 )
 
-func newSearchCmd() *cobra.Command {
+func newSearchCmd(cfg *config.Config) *cobra.Command {
 	var limit int
+	var explain bool
 	cmd := &cobra.Command{
 		Use:   "search <query>",
 		Short: "Hybrid search across indexes",
 		Args:  cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			root, err := os.Getwd()
+			ctx, cancel := commandContext(cmd)
+			defer cancel()
+			searcher, release, err := openSearcher(ctx, cmd, cfg)
 			if err != nil {
-				return exitError{code: exitRuntimeError, err: err}
+				return err
 			}
-			root, err = filepath.Abs(root)
+			defer release()
+			results, err := searcher.Search(ctx, strings.Join(args, " "), limit)
 			if err != nil {
-				return exitError{code: exitRuntimeError, err: err}
-			}
-			paths := workspace.Resolve(root)
-			if !workspace.Exists(paths) {
-				return exitError{code: exitIndexMissing, err: fmt.Errorf("index not found; run `scry index`")}
-			}
-			store, err := metadata.Open(paths.IndexDBPath)
-			if err != nil {
-				return exitError{code: exitRuntimeError, err: err}
-			}
-			engine := search.New(store)
-			query := strings.Join(args, " ")
-			results, err := engine.Search(query, limit)
-			if err != nil {
-				return exitError{code: exitRuntimeError, err: err}
+				return runError("search", err)
 			}
 			jsonOut, _ := cmd.Flags().GetBool("json")
 			if len(results) == 0 {
@@ -58,7 +50,7 @@ func newSearchCmd() *cobra.Command {
 			for i, r := range results {
 				snippet := formatSnippet(r.Chunk.Content, 200)
 				if jsonOut {
-					_ = json.NewEncoder(os.Stdout).Encode(map[string]any{
+					out := map[string]any{
 						"type":       "result",
 						"rank":       i + 1,
 						"score":      r.Score,
@@ -66,10 +58,20 @@ func newSearchCmd() *cobra.Command {
 						"start_line": r.Chunk.StartLine,
 						"end_line":   r.Chunk.EndLine,
 						"snippet":    snippet,
-					})
+					}
+					if r.Repo != "" {
+						out["repo"] = r.Repo
+					}
+					if explain {
+						out["explain"] = r.Explain
+					}
+					_ = json.NewEncoder(os.Stdout).Encode(out)
 				} else {
-					fmt.Fprintf(os.Stdout, "%d. %s:%d-%d (score %.2f)\n", i+1, r.Chunk.FilePath, r.Chunk.StartLine, r.Chunk.EndLine, r.Score)
+					fmt.Fprintf(os.Stdout, "%d. %s%s:%d-%d (score %.2f)\n", i+1, repoLabel(r.Repo), r.Chunk.FilePath, r.Chunk.StartLine, r.Chunk.EndLine, r.Score)
 					fmt.Fprintf(os.Stdout, "   %s\n", snippet)
+					if explain {
+						fmt.Fprintf(os.Stdout, "   explain: %s\n", formatExplanation(r.Explain))
+					}
 				}
 			}
 			if jsonOut {
@@ -82,10 +84,104 @@ func newSearchCmd() *cobra.Command {
 		},
 	}
 	addCommonFlags(cmd)
+	addTestsFlag(cmd)
+	addTimeoutFlag(cmd)
+	addScopeFlag(cmd)
+	addReposFlags(cmd)
 	cmd.Flags().IntVar(&limit, "limit", 20, "max results")
+	cmd.Flags().BoolVar(&explain, "explain", false, "break each score down by term")
 	return cmd
 }
 
+// openSearcher returns a federation of the repositories selected by --all or
+// --repos, or else an engine over the workspace root's index, configured
+// from cfg and the search flags. release must be called when done.
+func openSearcher(ctx context.Context, cmd *cobra.Command, cfg *config.Config) (search.Searcher, func(), error) {
+	sources, release, err := federatedSources(ctx, cmd)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(sources) > 0 {
+		return search.Federation(sources), release, nil
+	}
+
+	root, err := repoRoot(cmd)
+	if err != nil {
+		return nil, nil, exitError{code: exitRuntimeError, err: err}
+	}
+	paths := workspace.ResolveStorage(root, cfg.Index.Storage())
+	if !workspace.Exists(paths) {
+		return nil, nil, exitError{code: exitIndexMissing, err: fmt.Errorf("index not found; run `scry index`")}
+	}
+	store, release, err := openSnapshot(ctx, paths)
+	if err != nil {
+		return nil, nil, runError(cmd.Name(), err)
+	}
+	engine, err := newEngine(ctx, cmd, cfg, store, "")
+	if err != nil {
+		release()
+		return nil, nil, err
+	}
+	if here, _ := cmd.Flags().GetBool("here"); here {
+		engine.Scope, err = cwdScope(root)
+		if err != nil {
+			release()
+			return nil, nil, exitError{code: exitRuntimeError, err: err}
+		}
+	}
+	return engine, release, nil
+}
+
+// newEngine returns an engine over store configured from cfg and the search
+// flags. It warns, prefixed with label, when the index was built with other
+// analyzer settings than cfg's.
+func newEngine(ctx context.Context, cmd *cobra.Command, cfg *config.Config, store *metadata.DB, label string) (*search.Engine, error) {
+	engine := search.New(store)
+	engine.TestWeight = cfg.Scoring.TestWeight
+	engine.Explain, _ = cmd.Flags().GetBool("explain")
+	engine.Analyzer = cfg.Analyzer.Lexical()
+	var err error
+	if engine.Tests, err = testMode(cmd, cfg); err != nil {
+		return nil, err
+	}
+	built, _, err := store.Setting(ctx, indexer.AnalyzerSetting)
+	if err != nil {
+		return nil, runError(cmd.Name(), err)
+	}
+	if built != engine.Analyzer.Fingerprint() {
+		fmt.Fprintf(os.Stderr, "%swarning: the index was built with other analyzer settings; run `scry index` to rebuild it\n", label)
+	}
+	return engine, nil
+}
+
+// formatExplanation writes a score breakdown on one line, e.g.
+// "scan tf=3 +3, rule tf=1 +1; x0.5 test = 2.00".
+func formatExplanation(e *search.Explanation) string {
+	if e == nil {
+		return "none"
+	}
+	parts := make([]string, len(e.Terms))
+	for i, t := range e.Terms {
+		parts[i] = fmt.Sprintf("%s tf=%d %+g", t.Term, t.TF, t.Score)
+	}
+	line := strings.Join(parts, ", ")
+	if e.TestWeight != 1 {
+		line += fmt.Sprintf("; x%g test", e.TestWeight)
+	}
+	if e.Scale != 1 {
+		line += fmt.Sprintf("; x%.3g repo scale", e.Scale)
+	}
+	return line + fmt.Sprintf(" = %.2f", e.Score)
+}
+
+// repoLabel prefixes results of federated queries with their repository.
+func repoLabel(repo string) string {
+	if repo == "" {
+		return ""
+	}
+	return "[" + repo + "] "
+}
+
 func formatSnippet(text string, max int) string {
 	s := strings.TrimSpace(text)
 	if len(s) <= max {
@@ -4,62 +4,71 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"path/filepath"
 
 	"github.com/spf13/cobra"
 
-	"scry/pkg/metadata"
+	"scry/pkg/config"
 	"scry/pkg/workspace"
 )
 
-func newStatusCmd() *cobra.Command {
+func newStatusCmd(cfg *config.Config) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "status",
 		Short: "Show repo index health",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			root, err := os.Getwd()
+			root, err := repoRoot(cmd)
 			if err != nil {
 				return exitError{code: exitRuntimeError, err: err}
 			}
-			root, err = filepath.Abs(root)
-			if err != nil {
-				return exitError{code: exitRuntimeError, err: err}
-			}
-			paths := workspace.Resolve(root)
+			paths := workspace.ResolveStorage(root, cfg.Index.Storage())
 			jsonOut, _ := cmd.Flags().GetBool("json")
 			if !workspace.Exists(paths) {
 				if jsonOut {
 					_ = json.NewEncoder(os.Stdout).Encode(map[string]any{
-						"type":  "status",
-						"repo":  root,
-						"index": "missing",
+						"type":       "status",
+						"repo":       root,
+						"index":      "missing",
+						"index_path": paths.IndexDBPath,
 					})
 					return nil
 				}
 				fmt.Fprintln(os.Stdout, "Index: missing")
+				fmt.Fprintf(os.Stdout, "Index path: %s\n", paths.IndexDBPath)
 				return exitError{code: exitIndexMissing, silent: true}
 			}
-			store, err := metadata.Open(paths.IndexDBPath)
+			store, release, err := openSnapshot(cmd.Context(), paths)
 			if err != nil {
 				return exitError{code: exitRuntimeError, err: err}
 			}
-			stats, err := store.Stats()
+			defer release()
+			stats, err := store.Stats(cmd.Context())
 			if err != nil {
 				return exitError{code: exitRuntimeError, err: err}
 			}
+			configPath := ""
+			if cfg.Found {
+				configPath = cfg.Path
+			}
 			if jsonOut {
 				_ = json.NewEncoder(os.Stdout).Encode(map[string]any{
-					"type":   "status",
-					"repo":   root,
-					"index":  "present",
-					"files":  stats.Files,
-					"chunks": stats.Chunks,
-					"terms":  stats.Terms,
+					"type":       "status",
+					"repo":       root,
+					"index":      "present",
+					"index_path": paths.IndexDBPath,
+					"config":     configPath,
+					"files":      stats.Files,
+					"chunks":     stats.Chunks,
+					"terms":      stats.Terms,
 				})
 				return nil
 			}
 			fmt.Fprintf(os.Stdout, "Repo: %s\n", root)
+			if configPath == "" {
+				configPath = "none (defaults)"
+			}
+			fmt.Fprintf(os.Stdout, "Config: %s\n", configPath)
 			fmt.Fprintf(os.Stdout, "Index: present\n")
+			fmt.Fprintf(os.Stdout, "Index path: %s\n", paths.IndexDBPath)
 			fmt.Fprintf(os.Stdout, "Files indexed: %d\n", stats.Files)
 			fmt.Fprintf(os.Stdout, "Chunks indexed: %d\n", stats.Chunks)
 			fmt.Fprintf(os.Stdout, "Terms indexed: %d\n", stats.Terms)
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"scry/pkg/config"
+	"scry/pkg/indexer"
+	"scry/pkg/watch"
+)
+
+func newWatchCmd(cfg *config.Config) *cobra.Command {
+	var (
+		debounce time.Duration
+		jobs     int
+	)
+	cmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Keep the index up to date as files change",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root, err := repoRoot(cmd)
+			if err != nil {
+				return exitError{code: exitRuntimeError, err: err}
+			}
+			if jobs < 0 {
+				return exitError{code: exitUsageError, err: fmt.Errorf("--jobs must not be negative")}
+			}
+			jsonOut, _ := cmd.Flags().GetBool("json")
+			opts := indexer.Options{
+				Root:          root,
+				JSON:          jsonOut,
+				Scan:          cfg.ScanOptions(),
+				Storage:       cfg.Index.Storage(),
+				MaxChunkLines: cfg.Chunking.MaxLines,
+				Analyzer:      cfg.Analyzer.Lexical(),
+				Jobs:          jobs,
+				// Manual index runs may hold the lock between batches.
+				Wait: true,
+			}
+			emit := progressPrinter(jsonOut)
+
+			ctx := cmd.Context()
+			// Watch before the initial run so changes made during it are not lost.
+			w, err := watch.New(root, watch.Options{Ignore: opts.Scan.Ignore, Debounce: debounce})
+			if err != nil {
+				return exitError{code: exitRuntimeError, err: err}
+			}
+			summary, err := indexer.Run(ctx, opts, emit)
+			if errors.Is(err, context.Canceled) {
+				w.Close()
+				return nil
+			}
+			if err != nil {
+				w.Close()
+				return exitError{code: exitRuntimeError, err: err}
+			}
+			printSummary(jsonOut, summary)
+			emit(indexer.Progress{Type: "progress", Stage: "watch", Message: "watching for changes"})
+
+			err = w.Run(ctx, func(b watch.Batch) error {
+				run := opts
+				if !b.Full {
+					run.Paths = b.Paths
+				}
+				summary, err := indexer.Run(ctx, run, emit)
+				if errors.Is(err, context.Canceled) {
+					return nil
+				}
+				if err != nil {
+					// Files often vanish mid-run while editing; the next
+					// batch will catch up.
+					emit(indexer.Progress{Type: "error", Message: err.Error()})
+					return nil
+				}
+				if summary.FilesIndexed > 0 || summary.FilesDeleted > 0 || b.Full {
+					printSummary(jsonOut, summary)
+				}
+				return nil
+			})
+			if err != nil {
+				return exitError{code: exitRuntimeError, err: err}
+			}
+			return nil
+		},
+	}
+	addCommonFlags(cmd)
+	cmd.Flags().DurationVar(&debounce, "debounce", watch.DefaultDebounce, "quiet period before reindexing a burst of changes")
+	cmd.Flags().IntVarP(&jobs, "jobs", "j", 0, "files to read and parse concurrently (default: number of CPUs)")
+	return cmd
+}
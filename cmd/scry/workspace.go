@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"scry/pkg/config"
+	"scry/pkg/search"
+	"scry/pkg/workspace"
+)
+
+func newWorkspaceCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "workspace",
+		Short: "Manage the repositories searched together with --all and --repos",
+	}
+	cmd.AddCommand(newWorkspaceAddCmd(), newWorkspaceListCmd(), newWorkspaceRemoveCmd())
+	return cmd
+}
+
+func newWorkspaceAddCmd() *cobra.Command {
+	var name string
+	cmd := &cobra.Command{
+		Use:   "add <path>",
+		Short: "Register a repository",
+		Long: "Registers the repository at path under a name (the directory name\n" +
+			"unless --name is given). Each repository is indexed on its own with\n" +
+			"`scry index`; search --all and ask --repos query their indexes.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root, err := filepath.Abs(args[0])
+			if err != nil {
+				return exitError{code: exitRuntimeError, err: err}
+			}
+			if info, err := os.Stat(root); err != nil || !info.IsDir() {
+				return exitError{code: exitUsageError, err: fmt.Errorf("%s: not a directory", args[0])}
+			}
+			if name == "" {
+				name = filepath.Base(root)
+			}
+			reg, err := loadRegistry()
+			if err != nil {
+				return exitError{code: exitRuntimeError, err: err}
+			}
+			if err := reg.Add(name, root); err != nil {
+				return exitError{code: exitUsageError, err: err}
+			}
+			if err := reg.Save(); err != nil {
+				return exitError{code: exitRuntimeError, err: err}
+			}
+			fmt.Fprintf(os.Stdout, "added %s (%s)\n", name, root)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&name, "name", "", "name used in --repos and result labels")
+	return cmd
+}
+
+func newWorkspaceListCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List registered repositories",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			reg, err := loadRegistry()
+			if err != nil {
+				return exitError{code: exitRuntimeError, err: err}
+			}
+			jsonOut, _ := cmd.Flags().GetBool("json")
+			tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			for _, repo := range reg.Repos {
+				if jsonOut {
+					_ = json.NewEncoder(os.Stdout).Encode(map[string]any{
+						"type": "repo",
+						"name": repo.Name,
+						"root": repo.Root,
+					})
+					continue
+				}
+				fmt.Fprintf(tw, "%s\t%s\n", repo.Name, repo.Root)
+			}
+			return tw.Flush()
+		},
+	}
+	addCommonFlags(cmd)
+	return cmd
+}
+
+func newWorkspaceRemoveCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "remove <name>",
+		Short: "Unregister a repository",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			reg, err := loadRegistry()
+			if err != nil {
+				return exitError{code: exitRuntimeError, err: err}
+			}
+			if err := reg.Remove(args[0]); err != nil {
+				return exitError{code: exitUsageError, err: err}
+			}
+			if err := reg.Save(); err != nil {
+				return exitError{code: exitRuntimeError, err: err}
+			}
+			return nil
+		},
+	}
+}
+
+// loadRegistry reads repos.json next to the user config file.
+func loadRegistry() (*workspace.Registry, error) {
+	user := config.UserPath()
+	if user == "" {
+		return nil, fmt.Errorf("cannot locate the user config directory")
+	}
+	return workspace.LoadRegistry(filepath.Join(filepath.Dir(user), "repos.json"))
+}
+
+func addReposFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("all", false, "query every repository registered with `scry workspace add`")
+	cmd.Flags().StringSlice("repos", nil, "query these registered repositories (comma-separated names)")
+}
+
+// federatedSources opens an engine per repository selected by --all or
+// --repos, configured from that repository's own config. It returns no
+// sources when neither flag is given. Repositories without an index are
+// reported and skipped. release must be called when done.
+func federatedSources(ctx context.Context, cmd *cobra.Command) ([]search.Source, func(), error) {
+	all, _ := cmd.Flags().GetBool("all")
+	names, _ := cmd.Flags().GetStringSlice("repos")
+	if !all && len(names) == 0 {
+		return nil, func() {}, nil
+	}
+	if all && len(names) > 0 {
+		return nil, nil, exitError{code: exitUsageError, err: fmt.Errorf("--all and --repos are mutually exclusive")}
+	}
+	if here, _ := cmd.Flags().GetBool("here"); here {
+		return nil, nil, exitError{code: exitUsageError, err: fmt.Errorf("--here cannot be combined with --all or --repos")}
+	}
+	reg, err := loadRegistry()
+	if err != nil {
+		return nil, nil, exitError{code: exitRuntimeError, err: err}
+	}
+	repos := reg.Repos
+	if !all {
+		if repos, err = reg.Lookup(names); err != nil {
+			return nil, nil, exitError{code: exitUsageError, err: err}
+		}
+	}
+	if len(repos) == 0 {
+		return nil, nil, exitError{code: exitUsageError, err: fmt.Errorf("no repositories registered; run `scry workspace add <path>`")}
+	}
+
+	var sources []search.Source
+	var releases []func()
+	release := func() {
+		for _, r := range releases {
+			r()
+		}
+	}
+	for _, repo := range repos {
+		cfg, err := loadConfig(filepath.Join(repo.Root, ".scry.yml"), false)
+		if err != nil {
+			release()
+			return nil, nil, exitError{code: exitRuntimeError, err: fmt.Errorf("%s: %w", repo.Name, err)}
+		}
+		paths := workspace.ResolveStorage(repo.Root, cfg.Index.Storage())
+		if !workspace.Exists(paths) {
+			fmt.Fprintf(os.Stderr, "%s: index not found; run `scry index` in %s\n", repo.Name, repo.Root)
+			continue
+		}
+		store, done, err := openSnapshot(ctx, paths)
+		if err != nil {
+			release()
+			return nil, nil, runError(cmd.Name(), fmt.Errorf("%s: %w", repo.Name, err))
+		}
+		releases = append(releases, done)
+		engine, err := newEngine(ctx, cmd, &cfg, store, repo.Name+": ")
+		if err != nil {
+			release()
+			return nil, nil, err
+		}
+		sources = append(sources, search.Source{Name: repo.Name, Engine: engine})
+	}
+	if len(sources) == 0 {
+		return nil, nil, exitError{code: exitIndexMissing, err: fmt.Errorf("no registered repository has an index")}
+	}
+	return sources, release, nil
+}
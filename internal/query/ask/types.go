@@ -1,11 +0,0 @@
-package ask
-
-type Chunk struct {
-	ID         string
-	FilePath   string
-	StartLine  int
-	EndLine    int
-	Text       string
-	Score      float64
-	MatchCount int
-}
@@ -0,0 +1,49 @@
+package ask
+
+import (
+	"fmt"
+
+	"scry/pkg/index/lexical"
+)
+
+type Decision struct {
+	Evidence []Evidence
+	Reason   string
+}
+
+// Options configures an Asker. K bounds the candidates it retrieves; zero
+// retrieves every match. BuildEvidence uses only MaxEvidence, SnippetChars,
+// Rules and Analyzer.
+type Options struct {
+	K            int
+	MaxEvidence  int
+	SnippetChars int
+	MinScore     float64
+	Rules        []Rule
+	// Analyzer splits the question into terms and matches them against
+	// chunk text. It must match the analyzer the index was built with.
+	Analyzer lexical.Analyzer
+}
+
+func decide(chunks []Chunk, words [][]string, opts Options) Decision {
+	evidence := BuildEvidence(chunks, words, opts)
+	if len(evidence) == 0 {
+		return Decision{Reason: ReasonNoEvidence}
+	}
+	if totalScore(evidence) < opts.MinScore {
+		return Decision{Reason: ReasonLowScore}
+	}
+	return Decision{Evidence: evidence}
+}
+
+func totalScore(evidence []Evidence) float64 {
+	var sum float64
+	for _, e := range evidence {
+		sum += e.Chunk.Score
+	}
+	return sum
+}
+
+func answerHeader(evidence []Evidence) string {
+	return fmt.Sprintf("Found %d relevant evidence chunk(s).", len(evidence))
+}
@@ -0,0 +1,68 @@
+package ask
+
+import (
+	"testing"
+
+	"scry/pkg/index/lexical"
+)
+
+func TestDecideIDKNoEvidence(t *testing.T) {
+	dec := decide(nil, [][]string{{"ignore"}}, Options{MaxEvidence: 2, SnippetChars: 80, MinScore: 1})
+	if dec.Reason != "no_evidence" {
+		t.Fatalf("expected no_evidence, got %s", dec.Reason)
+	}
+}
+
+func TestDecideIDKLowScore(t *testing.T) {
+	chunks := []Chunk{{ID: "1", Text: "ignore patterns", FilePath: "pkg/ignore/x.go", Score: 0.2}}
+	dec := decide(chunks, [][]string{{"ignore"}}, Options{MaxEvidence: 2, SnippetChars: 80, MinScore: 5})
+	if dec.Reason != "low_score" {
+		t.Fatalf("expected low_score, got %s", dec.Reason)
+	}
+}
+
+func TestDecideCountsQuestionWords(t *testing.T) {
+	// "indexing" also yields its stem and FileHash its parts; each is still
+	// one word of the question.
+	chunks := []Chunk{
+		{ID: "1", Text: "indexing fills the index", FilePath: "a.go", Score: 5},
+		{ID: "2", Text: "FileHash hashes a file", FilePath: "b.go", Score: 4},
+	}
+	for _, question := range []string{"indexing", "FileHash"} {
+		words := lexical.Analyzer{}.QueryWords(question)
+		dec := decide(chunks, words, Options{MaxEvidence: 1, SnippetChars: 80})
+		if len(dec.Evidence) != 1 || dec.Evidence[0].Chunk.MatchCount != 1 || dec.Evidence[0].Chunk.Explain().Preferred {
+			t.Fatalf("%s: expected a weak one-word match, got %+v", question, dec.Evidence)
+		}
+	}
+
+	// A chunk matching both words is preferred over a higher-scoring chunk
+	// matching one of them several ways.
+	chunks = append(chunks, Chunk{ID: "3", Text: "FileHash during indexing", FilePath: "c.go", Score: 1})
+	dec := decide(chunks, lexical.Analyzer{}.QueryWords("FileHash indexing"), Options{MaxEvidence: 2, SnippetChars: 80})
+	if len(dec.Evidence) != 1 || dec.Evidence[0].Chunk.ID != "3" || dec.Evidence[0].Chunk.MatchCount != 2 {
+		t.Fatalf("expected only the two-word match, got %+v", dec.Evidence)
+	}
+}
+
+func TestDecideReturnsEvidence(t *testing.T) {
+	chunks := []Chunk{
+		{ID: "1", Text: "ignore patterns", FilePath: "pkg/ignore/x.go", Score: 2.0},
+		{ID: "2", Text: "scan rules", FilePath: "pkg/scan/x.go", Score: 1.5},
+	}
+	dec := decide(chunks, [][]string{{"ignore"}}, Options{MaxEvidence: 2, SnippetChars: 80, MinScore: 1.0})
+	if dec.Reason != "" {
+		t.Fatalf("expected no reason, got %s", dec.Reason)
+	}
+	if len(dec.Evidence) == 0 {
+		t.Fatalf("expected evidence")
+	}
+}
+
+func TestAnswerHeader(t *testing.T) {
+	evidence := []Evidence{{Chunk: Chunk{ID: "1"}}}
+	got := answerHeader(evidence)
+	if got != "Found 1 relevant evidence chunk(s)." {
+		t.Fatalf("unexpected header: %s", got)
+	}
+}
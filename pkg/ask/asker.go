@@ -0,0 +1,73 @@
+package ask
+
+import (
+	"context"
+
+	"scry/pkg/search"
+)
+
+// Reasons an Answer has no evidence.
+const (
+	ReasonNoEvidence = "no_evidence"
+	ReasonLowScore   = "low_score"
+)
+
+// Answer is the result of Asker.Ask. When nothing relevant enough is
+// indexed, Evidence is empty, Text is "I don't know." and Reason says why.
+type Answer struct {
+	Text     string
+	Reason   string
+	Evidence []Evidence
+}
+
+// Known reports whether the answer has evidence.
+func (a Answer) Known() bool {
+	return len(a.Evidence) > 0
+}
+
+// Asker answers questions with extractive evidence: it retrieves
+// Options.K candidate chunks with Searcher, keeps those containing question
+// terms, re-ranks them by match count and Options.Rules, and returns the
+// best snippets. Candidates are ranked and held to MinScore by their raw
+// search scores, which a search.Federation leaves unnormalised.
+type Asker struct {
+	Searcher search.Searcher
+	Options  Options
+}
+
+// New returns an Asker over store, searched with the default engine
+// settings and opts.Analyzer. Set Searcher to a configured *search.Engine or
+// a search.Federation for more control.
+func New(store search.Store, opts Options) *Asker {
+	engine := search.New(store)
+	engine.Analyzer = opts.Analyzer
+	return &Asker{Searcher: engine, Options: opts}
+}
+
+// Ask answers question. Errors come from the search, including ctx's error
+// when it is cancelled.
+func (a *Asker) Ask(ctx context.Context, question string) (Answer, error) {
+	results, err := a.Searcher.Search(ctx, question, a.Options.K)
+	if err != nil {
+		return Answer{}, err
+	}
+	candidates := make([]Chunk, 0, len(results))
+	for _, r := range results {
+		candidates = append(candidates, Chunk{
+			ID:        r.Chunk.ID,
+			Repo:      r.Repo,
+			FilePath:  r.Chunk.FilePath,
+			Lang:      r.Chunk.Lang,
+			StartLine: r.Chunk.StartLine,
+			EndLine:   r.Chunk.EndLine,
+			Text:      r.Chunk.Content,
+			Score:     r.Raw,
+			Search:    r.Explain,
+		})
+	}
+	decision := decide(candidates, a.Options.Analyzer.QueryWords(question), a.Options)
+	if len(decision.Evidence) == 0 {
+		return Answer{Text: "I don't know.", Reason: decision.Reason}, nil
+	}
+	return Answer{Text: answerHeader(decision.Evidence), Evidence: decision.Evidence}, nil
+}
@@ -0,0 +1,87 @@
+package ask
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"scry/pkg/metadata"
+	"scry/pkg/search"
+)
+
+type memStore struct {
+	hits   map[string][]metadata.TermHit
+	chunks []metadata.ChunkView
+}
+
+func (m memStore) TermHits(ctx context.Context, term string) ([]metadata.TermHit, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.hits[term], nil
+}
+
+func (m memStore) GetChunksByIDs(ctx context.Context, ids []string) ([]metadata.ChunkView, error) {
+	return m.chunks, nil
+}
+
+func TestAsker(t *testing.T) {
+	store := memStore{
+		hits: map[string][]metadata.TermHit{
+			"scan":   {{ChunkID: "c1", TF: 2}, {ChunkID: "c2", TF: 1}},
+			"ignore": {{ChunkID: "c1", TF: 1}},
+		},
+		chunks: []metadata.ChunkView{
+			{ID: "c1", FilePath: "pkg/scan/scan.go", StartLine: 3, EndLine: 9, Content: "scan skips ignore matches"},
+			{ID: "c2", FilePath: "README.md", StartLine: 1, EndLine: 2, Content: "how to scan"},
+		},
+	}
+	asker := New(store, Options{K: 5, MaxEvidence: 2, SnippetChars: 80, MinScore: 1})
+	answer, err := asker.Ask(context.Background(), "how does scan ignore files")
+	if err != nil {
+		t.Fatalf("ask: %v", err)
+	}
+	if !answer.Known() || answer.Reason != "" || answer.Text != "Found 1 relevant evidence chunk(s)." {
+		t.Fatalf("unexpected answer: %+v", answer)
+	}
+	if ev := answer.Evidence[0]; ev.Chunk.FilePath != "pkg/scan/scan.go" || ev.Chunk.StartLine != 3 || ev.Snippet == "" {
+		t.Fatalf("unexpected evidence: %+v", ev)
+	}
+
+	asker.Options.MinScore = 10
+	if answer, err = asker.Ask(context.Background(), "scan ignore"); err != nil || answer.Known() || answer.Reason != ReasonLowScore || answer.Text != "I don't know." {
+		t.Fatalf("expected low score refusal, got %+v, %v", answer, err)
+	}
+	if answer, err = asker.Ask(context.Background(), "unrelated words"); err != nil || answer.Reason != ReasonNoEvidence {
+		t.Fatalf("expected no evidence, got %+v, %v", answer, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := asker.Ask(ctx, "scan"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancellation, got %v", err)
+	}
+}
+
+func TestAskerFederatedUsesRawScores(t *testing.T) {
+	repo := func(id string, tf int) *search.Engine {
+		return search.New(memStore{
+			hits:   map[string][]metadata.TermHit{"scan": {{ChunkID: id, TF: tf}}},
+			chunks: []metadata.ChunkView{{ID: id, FilePath: id + ".go", StartLine: 1, EndLine: 1, Content: "scan"}},
+		})
+	}
+	asker := &Asker{
+		Searcher: search.Federation{{Name: "strong", Engine: repo("s", 4)}, {Name: "weak", Engine: repo("w", 1)}},
+		Options:  Options{K: 5, MaxEvidence: 2, SnippetChars: 80, MinScore: 5},
+	}
+	answer, err := asker.Ask(context.Background(), "scan")
+	if err != nil || !answer.Known() || len(answer.Evidence) != 2 || answer.Evidence[0].Chunk.Repo != "strong" {
+		t.Fatalf("expected both repos as evidence, got %+v, %v", answer, err)
+	}
+	// Rescaled to the best score overall, the weak repo's match would count
+	// as much as the strong one's.
+	asker.Options.MinScore = 6
+	if answer, err = asker.Ask(context.Background(), "scan"); err != nil || answer.Reason != ReasonLowScore {
+		t.Fatalf("expected min_score to apply to raw scores, got %+v, %v", answer, err)
+	}
+}
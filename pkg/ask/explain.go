@@ -0,0 +1,30 @@
+package ask
+
+import "scry/pkg/search"
+
+// Explanation records how an evidence chunk was scored: the search's
+// breakdown of its retrieval score, then the ask pipeline's adjustments.
+type Explanation struct {
+	Search *search.Explanation `json:"search"`
+	// MatchCount is the number of question words in the chunk;
+	// chunks matching two or more (Preferred) outrank those matching one.
+	MatchCount int      `json:"match_count"`
+	Preferred  bool     `json:"preferred"`
+	Rules      []Firing `json:"rules"`
+	Score      float64  `json:"score"`
+}
+
+// Explain describes how c reached its score.
+func (c Chunk) Explain() Explanation {
+	rules := c.Fired
+	if rules == nil {
+		rules = []Firing{}
+	}
+	return Explanation{
+		Search:     c.Search,
+		MatchCount: c.MatchCount,
+		Preferred:  c.MatchCount >= 2,
+		Rules:      rules,
+		Score:      c.Score,
+	}
+}
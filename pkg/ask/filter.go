@@ -0,0 +1,18 @@
+package ask
+
+import "scry/pkg/index/lexical"
+
+// FilterByQueryTerms keeps only chunks with at least one query term among
+// the terms a produces for their text in their language.
+func FilterByQueryTerms(chunks []Chunk, terms []string, a lexical.Analyzer) []Chunk {
+	if len(terms) == 0 || len(chunks) == 0 {
+		return nil
+	}
+	var out []Chunk
+	for _, ch := range chunks {
+		if a.ForLang(ch.Lang).Contains(ch.Text, terms...) {
+			out = append(out, ch)
+		}
+	}
+	return out
+}
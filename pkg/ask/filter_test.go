@@ -0,0 +1,64 @@
+package ask
+
+import (
+	"testing"
+
+	"scry/pkg/index/lexical"
+)
+
+func TestFilterByQueryTerms(t *testing.T) {
+	chunks := []Chunk{
+		{ID: "1", Text: "This talks about ignore patterns."},
+		{ID: "2", Text: "Unrelated content."},
+		{ID: "3", Text: "Scan rules are defined here."},
+	}
+	terms := []string{"ignore", "scan"}
+	got := FilterByQueryTerms(chunks, terms, lexical.Analyzer{})
+	if len(got) != 2 {
+		t.Fatalf("expected 2 chunks, got %d", len(got))
+	}
+	if got[0].ID != "1" || got[1].ID != "3" {
+		t.Fatalf("unexpected chunk IDs: %v, %v", got[0].ID, got[1].ID)
+	}
+}
+
+func TestFilterByQueryTermsEmptyInputs(t *testing.T) {
+	if got := FilterByQueryTerms(nil, []string{"ignore"}, lexical.Analyzer{}); got != nil {
+		t.Fatalf("expected nil for empty chunks")
+	}
+	if got := FilterByQueryTerms([]Chunk{{ID: "1", Text: "ignore"}}, nil, lexical.Analyzer{}); got != nil {
+		t.Fatalf("expected nil for empty terms")
+	}
+}
+
+func TestFilterByQueryTermsUsesChunkLanguage(t *testing.T) {
+	chunks := []Chunk{
+		{ID: "1", Lang: "go", Text: "func Close() error { return nil }"},
+		{ID: "2", Lang: "md", Text: "Close returns an error."},
+	}
+	terms := lexical.Analyzer{}.QueryTerms("what does close return")
+	got := FilterByQueryTerms(chunks, terms, lexical.Analyzer{})
+	if len(got) != 2 {
+		t.Fatalf("expected both chunks to match close, got %v", got)
+	}
+	words := lexical.Analyzer{}.QueryWords("what does close return")
+	if n := WordMatchCount(words, chunks[0].Text, lexical.Analyzer{Lang: "go"}); n != 1 {
+		t.Fatalf("expected the Go keyword return not to match, got %d matches", n)
+	}
+	if n := WordMatchCount(words, chunks[1].Text, lexical.Analyzer{Lang: "md"}); n != 2 {
+		t.Fatalf("expected returns to match return in prose, got %d matches", n)
+	}
+}
+
+func TestFilterByQueryTermsMatchesWholeTerms(t *testing.T) {
+	chunks := []Chunk{
+		{ID: "1", Text: "reads .gitignore files"},
+		{ID: "2", Text: "opens the DB, then Go code"},
+	}
+	if got := FilterByQueryTerms(chunks, []string{"ignore"}, lexical.Analyzer{}); got != nil {
+		t.Fatalf("expected substrings not to match, got %v", got)
+	}
+	if got := FilterByQueryTerms(chunks, []string{"go", "db"}, lexical.Analyzer{}); len(got) != 1 || got[0].ID != "2" {
+		t.Fatalf("expected short indexed terms to match, got %v", got)
+	}
+}
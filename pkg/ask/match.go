@@ -0,0 +1,26 @@
+package ask
+
+import "scry/pkg/index/lexical"
+
+// WordMatchCount counts the query words, as grouped by
+// lexical.Analyzer.QueryWords, with at least one term among the terms a
+// produces for text.
+func WordMatchCount(words [][]string, text string, a lexical.Analyzer) int {
+	if len(words) == 0 || text == "" {
+		return 0
+	}
+	have := map[string]bool{}
+	for _, t := range a.Terms(text) {
+		have[t] = true
+	}
+	count := 0
+	for _, word := range words {
+		for _, t := range word {
+			if have[t] {
+				count++
+				break
+			}
+		}
+	}
+	return count
+}
@@ -0,0 +1,31 @@
+package ask
+
+import (
+	"testing"
+
+	"scry/pkg/index/lexical"
+)
+
+func TestWordMatchCount(t *testing.T) {
+	words := [][]string{{"ignore"}, {"scan"}, {"gitignore"}}
+	text := "We scan ignore patterns in gitignore files."
+	if got := WordMatchCount(words, text, lexical.Analyzer{}); got != 3 {
+		t.Fatalf("expected 3, got %d", got)
+	}
+}
+
+func TestWordMatchCountCountsWordsOnce(t *testing.T) {
+	words := [][]string{{"indexing", "index"}}
+	if got := WordMatchCount(words, "indexing fills the index", lexical.Analyzer{}); got != 1 {
+		t.Fatalf("expected 1, got %d", got)
+	}
+}
+
+func TestWordMatchCountEmpty(t *testing.T) {
+	if got := WordMatchCount(nil, "text", lexical.Analyzer{}); got != 0 {
+		t.Fatalf("expected 0, got %d", got)
+	}
+	if got := WordMatchCount([][]string{{"ignore"}}, "", lexical.Analyzer{}); got != 0 {
+		t.Fatalf("expected 0, got %d", got)
+	}
+}
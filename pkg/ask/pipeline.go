@@ -0,0 +1,26 @@
+package ask
+
+type Evidence struct {
+	Chunk   Chunk
+	Snippet string
+}
+
+// BuildEvidence selects evidence for a question given as the words
+// opts.Analyzer.QueryWords returns for it.
+func BuildEvidence(chunks []Chunk, words [][]string, opts Options) []Evidence {
+	var terms []string
+	for _, word := range words {
+		terms = append(terms, word...)
+	}
+	filtered := FilterByQueryTerms(chunks, terms, opts.Analyzer)
+	preferred := ApplyMatchPreference(filtered, words, opts.Analyzer)
+	boosted := ApplyRules(preferred, terms, opts.Rules, opts.Analyzer)
+	boosted = SortCandidates(boosted)
+	selected := SelectTopEvidence(boosted, opts.MaxEvidence)
+	var evidence []Evidence
+	for _, ch := range selected {
+		snippet := SnippetAroundTerm(ch.Text, terms, opts.SnippetChars, opts.Analyzer.ForLang(ch.Lang))
+		evidence = append(evidence, Evidence{Chunk: ch, Snippet: snippet})
+	}
+	return evidence
+}
@@ -1,16 +1,20 @@
 package ask
 
-import "sort"
+import (
+	"sort"
 
-// ApplyMatchPreference prefers chunks with >=2 distinct term matches, fallback to >=1.
-func ApplyMatchPreference(chunks []Chunk, terms []string) []Chunk {
-	if len(chunks) == 0 || len(terms) == 0 {
+	"scry/pkg/index/lexical"
+)
+
+// ApplyMatchPreference prefers chunks matching >=2 distinct query words, fallback to >=1.
+func ApplyMatchPreference(chunks []Chunk, words [][]string, a lexical.Analyzer) []Chunk {
+	if len(chunks) == 0 || len(words) == 0 {
 		return nil
 	}
 	var strong []Chunk
 	var weak []Chunk
 	for _, ch := range chunks {
-		matches := DistinctTermMatchCount(terms, ch.Text)
+		matches := WordMatchCount(words, ch.Text, a.ForLang(ch.Lang))
 		ch.MatchCount = matches
 		if matches >= 2 {
 			strong = append(strong, ch)
@@ -24,10 +28,13 @@ func ApplyMatchPreference(chunks []Chunk, terms []string) []Chunk {
 	return weak
 }
 
-// SortCandidates sorts by score desc, then path asc, then start line asc.
+// SortCandidates sorts by score desc, then repo, path and start line asc.
 func SortCandidates(chunks []Chunk) []Chunk {
 	sort.Slice(chunks, func(i, j int) bool {
 		if chunks[i].Score == chunks[j].Score {
+			if chunks[i].Repo != chunks[j].Repo {
+				return chunks[i].Repo < chunks[j].Repo
+			}
 			if chunks[i].FilePath == chunks[j].FilePath {
 				return chunks[i].StartLine < chunks[j].StartLine
 			}
@@ -1,14 +1,18 @@
 package ask
 
-import "testing"
+import (
+	"testing"
+
+	"scry/pkg/index/lexical"
+)
 
 func TestApplyMatchPreference(t *testing.T) {
 	chunks := []Chunk{
 		{ID: "1", Text: "ignore scan", FilePath: "pkg/scan/x.go"},
 		{ID: "2", Text: "ignore", FilePath: "pkg/ignore/x.go"},
 	}
-	terms := []string{"ignore", "scan"}
-	got := ApplyMatchPreference(chunks, terms)
+	words := [][]string{{"ignore"}, {"scan"}}
+	got := ApplyMatchPreference(chunks, words, lexical.Analyzer{})
 	if len(got) != 1 {
 		t.Fatalf("expected 1 strong chunk, got %d", len(got))
 	}
@@ -22,18 +26,18 @@ func TestApplyMatchPreferenceFallback(t *testing.T) {
 		{ID: "1", Text: "ignore", FilePath: "pkg/ignore/x.go"},
 		{ID: "2", Text: "scan", FilePath: "pkg/scan/x.go"},
 	}
-	terms := []string{"ignore", "scan"}
-	got := ApplyMatchPreference(chunks, terms)
+	words := [][]string{{"ignore"}, {"scan"}}
+	got := ApplyMatchPreference(chunks, words, lexical.Analyzer{})
 	if len(got) != 2 {
 		t.Fatalf("expected fallback to weak matches, got %d", len(got))
 	}
 }
 
 func TestApplyMatchPreferenceEmpty(t *testing.T) {
-	if got := ApplyMatchPreference(nil, []string{"ignore"}); got != nil {
+	if got := ApplyMatchPreference(nil, [][]string{{"ignore"}}, lexical.Analyzer{}); got != nil {
 		t.Fatalf("expected nil for empty chunks")
 	}
-	if got := ApplyMatchPreference([]Chunk{{ID: "1", Text: "ignore"}}, nil); got != nil {
+	if got := ApplyMatchPreference([]Chunk{{ID: "1", Text: "ignore"}}, nil, lexical.Analyzer{}); got != nil {
 		t.Fatalf("expected nil for empty terms")
 	}
 }
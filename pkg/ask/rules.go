@@ -0,0 +1,100 @@
+package ask
+
+import (
+	"scry/pkg/ignore"
+	"scry/pkg/index/lexical"
+)
+
+// Rule kinds.
+const (
+	// RuleBoost adds Weight to matching chunks.
+	RuleBoost = "boost"
+	// RulePenalty subtracts Weight from matching chunks.
+	RulePenalty = "penalty"
+	// RuleWhitelist adds Weight to matching chunks and shields them from
+	// penalties.
+	RuleWhitelist = "whitelist"
+)
+
+// Rule adjusts the score of chunks whose path matches one of Paths (globs
+// in ignore pattern syntax) when the question contains one of Terms, or for
+// every question when Terms is empty.
+type Rule struct {
+	Name   string
+	Kind   string
+	Terms  []string
+	Paths  []string
+	Weight float64
+}
+
+// Firing records a rule that changed a chunk's score.
+type Firing struct {
+	Rule  string  `json:"rule"`
+	Kind  string  `json:"kind"`
+	Delta float64 `json:"delta"`
+}
+
+// ApplyRules adjusts chunk scores by the rules that match them, in order,
+// recording each adjustment in the chunk's Fired list. Rule terms are
+// analyzed with a before they are compared with the query terms.
+func ApplyRules(chunks []Chunk, terms []string, rules []Rule, a lexical.Analyzer) []Chunk {
+	if len(chunks) == 0 || len(terms) == 0 || len(rules) == 0 {
+		return chunks
+	}
+	termSet := map[string]struct{}{}
+	for _, t := range terms {
+		termSet[t] = struct{}{}
+	}
+	var active []Rule
+	for _, rule := range rules {
+		if termsMatch(rule.Terms, termSet, a) {
+			active = append(active, rule)
+		}
+	}
+	for i := range chunks {
+		var matched []Rule
+		shielded := false
+		for _, rule := range active {
+			if pathMatch(rule.Paths, chunks[i].FilePath) {
+				matched = append(matched, rule)
+				shielded = shielded || rule.Kind == RuleWhitelist
+			}
+		}
+		for _, rule := range matched {
+			delta := rule.Weight
+			switch rule.Kind {
+			case RulePenalty:
+				if shielded {
+					continue
+				}
+				delta = -delta
+			}
+			chunks[i].Score += delta
+			chunks[i].Fired = append(chunks[i].Fired, Firing{Rule: rule.Name, Kind: rule.Kind, Delta: delta})
+		}
+	}
+	return chunks
+}
+
+func termsMatch(ruleTerms []string, termSet map[string]struct{}, a lexical.Analyzer) bool {
+	if len(ruleTerms) == 0 {
+		return true
+	}
+	for _, rt := range ruleTerms {
+		for _, t := range a.QueryTerms(rt) {
+			if _, ok := termSet[t]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func pathMatch(globs []string, path string) bool {
+	for _, g := range globs {
+		if ignore.MatchGlob(g, path) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package ask
+
+import (
+	"reflect"
+	"testing"
+
+	"scry/pkg/index/lexical"
+)
+
+func TestApplyRules(t *testing.T) {
+	rules := []Rule{
+		{Name: "scanner", Kind: RuleBoost, Terms: []string{"Scan"}, Paths: []string{"pkg/scan/"}, Weight: 2},
+		{Name: "no-cli", Kind: RulePenalty, Paths: []string{"cmd/"}, Weight: 1.5},
+		{Name: "flags", Kind: RuleWhitelist, Terms: []string{"flag"}, Paths: []string{"cmd/**/*.go"}, Weight: 0.5},
+	}
+	chunks := func() []Chunk {
+		return []Chunk{
+			{ID: "1", FilePath: "pkg/scan/scan.go", Score: 1},
+			{ID: "2", FilePath: "cmd/scry/index.go", Score: 1},
+			{ID: "3", FilePath: "README.md", Score: 1},
+		}
+	}
+
+	got := ApplyRules(chunks(), []string{"scan"}, rules, lexical.Analyzer{})
+	if got[0].Score != 3 || got[1].Score != -0.5 || got[2].Score != 1 {
+		t.Fatalf("unexpected scores: %v %v %v", got[0].Score, got[1].Score, got[2].Score)
+	}
+	if len(got[0].Fired) != 1 || got[0].Fired[0] != (Firing{Rule: "scanner", Kind: RuleBoost, Delta: 2}) {
+		t.Fatalf("unexpected firings: %+v", got[0].Fired)
+	}
+	if len(got[2].Fired) != 0 {
+		t.Fatalf("expected no rules for README, got %+v", got[2].Fired)
+	}
+
+	// A triggered whitelist shields its paths from penalties.
+	got = ApplyRules(chunks(), []string{"flag"}, rules, lexical.Analyzer{})
+	if got[1].Score != 1.5 || len(got[1].Fired) != 1 || got[1].Fired[0].Rule != "flags" {
+		t.Fatalf("expected whitelist to cancel the penalty, got %v %+v", got[1].Score, got[1].Fired)
+	}
+	if got[0].Score != 1 {
+		t.Fatalf("expected boost to need its terms, got %v", got[0].Score)
+	}
+}
+
+func TestApplyRulesEarlyReturn(t *testing.T) {
+	rules := []Rule{{Name: "r", Kind: RuleBoost, Paths: []string{"pkg/"}, Weight: 1}}
+	chunks := []Chunk{{ID: "1", FilePath: "pkg/scan/scan.go", Score: 1.0}}
+	if got := ApplyRules(chunks, nil, rules, lexical.Analyzer{}); got == nil || got[0].Score != 1 {
+		t.Fatalf("expected chunks back unchanged for empty terms")
+	}
+	if got := ApplyRules(nil, []string{"scan"}, rules, lexical.Analyzer{}); got != nil {
+		t.Fatalf("expected nil for empty chunks")
+	}
+	if got := ApplyRules(chunks, []string{"scan"}, nil, lexical.Analyzer{}); got == nil || got[0].Score != 1 {
+		t.Fatalf("expected chunks back unchanged for empty rules")
+	}
+}
+
+func TestTermsMatch(t *testing.T) {
+	if !termsMatch(nil, map[string]struct{}{}, lexical.Analyzer{}) {
+		t.Fatalf("expected empty rule terms to match")
+	}
+	if termsMatch([]string{"alpha"}, map[string]struct{}{"beta": {}}, lexical.Analyzer{}) {
+		t.Fatalf("expected no match")
+	}
+	if !termsMatch([]string{"Alpha"}, map[string]struct{}{"alpha": {}}, lexical.Analyzer{}) {
+		t.Fatalf("expected case-insensitive match")
+	}
+}
+
+func TestBuildEvidenceExplain(t *testing.T) {
+	chunks := []Chunk{
+		{ID: "1", FilePath: "pkg/scan/scan.go", Text: "scan walks and applies ignore rules", Score: 2},
+		{ID: "2", FilePath: "README.md", Text: "scan the tree", Score: 5},
+	}
+	rules := []Rule{{Name: "scanner", Kind: RuleBoost, Paths: []string{"pkg/scan/"}, Weight: 1.5}}
+	evidence := BuildEvidence(chunks, [][]string{{"scan"}, {"ignore"}}, Options{MaxEvidence: 2, SnippetChars: 80, Rules: rules})
+	if len(evidence) != 1 {
+		t.Fatalf("expected only the two-term match, got %+v", evidence)
+	}
+	got := evidence[0].Chunk.Explain()
+	want := Explanation{MatchCount: 2, Preferred: true, Rules: []Firing{{Rule: "scanner", Kind: RuleBoost, Delta: 1.5}}, Score: 3.5}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected explanation: %+v", got)
+	}
+}
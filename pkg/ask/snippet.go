@@ -1,6 +1,10 @@
 package ask
 
-import "strings"
+import (
+	"strings"
+
+	"scry/pkg/index/lexical"
+)
 
 // SelectTopEvidence returns at most n chunks by score, stable by path/id.
 func SelectTopEvidence(chunks []Chunk, n int) []Chunk {
@@ -13,23 +17,13 @@ func SelectTopEvidence(chunks []Chunk, n int) []Chunk {
 	return chunks[:n]
 }
 
-// SnippetAroundTerm returns a snippet around the first matched term, up to maxChars.
-func SnippetAroundTerm(text string, terms []string, maxChars int) string {
+// SnippetAroundTerm returns a snippet, up to maxChars, around the first
+// occurrence of the first query term that a finds in text.
+func SnippetAroundTerm(text string, terms []string, maxChars int, a lexical.Analyzer) string {
 	if maxChars <= 0 {
 		return ""
 	}
-	lower := strings.ToLower(text)
-	pos := -1
-	for _, t := range terms {
-		if t == "" {
-			continue
-		}
-		idx := strings.Index(lower, t)
-		if idx >= 0 {
-			pos = idx
-			break
-		}
-	}
+	pos := termOffset(text, terms, a)
 	if pos < 0 {
 		return trimSnippet(text, maxChars)
 	}
@@ -51,6 +45,26 @@ func SnippetAroundTerm(text string, terms []string, maxChars int) string {
 	return snippet
 }
 
+// termOffset is the byte offset of the first term in terms that occurs in
+// text, or -1.
+func termOffset(text string, terms []string, a lexical.Analyzer) int {
+	if len(terms) == 0 {
+		return -1
+	}
+	first := map[string]int{}
+	for _, tok := range a.Tokens(text) {
+		if _, ok := first[tok.Term]; !ok {
+			first[tok.Term] = tok.Start
+		}
+	}
+	for _, t := range terms {
+		if pos, ok := first[t]; ok {
+			return pos
+		}
+	}
+	return -1
+}
+
 func trimSnippet(text string, maxChars int) string {
 	if len(text) <= maxChars {
 		return strings.TrimSpace(text)
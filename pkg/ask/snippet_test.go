@@ -3,6 +3,8 @@ package ask
 import (
 	"strings"
 	"testing"
+
+	"scry/pkg/index/lexical"
 )
 
 func TestSelectTopEvidenceMaxTwo(t *testing.T) {
@@ -24,7 +26,7 @@ func TestSelectTopEvidenceZeroLimit(t *testing.T) {
 func TestSnippetAroundTerm(t *testing.T) {
 	text := "alpha beta gamma delta epsilon"
 	terms := []string{"gamma"}
-	snippet := SnippetAroundTerm(text, terms, 10)
+	snippet := SnippetAroundTerm(text, terms, 10, lexical.Analyzer{})
 	if snippet == "" {
 		t.Fatalf("expected snippet")
 	}
@@ -36,14 +38,14 @@ func TestSnippetAroundTerm(t *testing.T) {
 func TestSnippetAroundTermNoMatchUsesTrim(t *testing.T) {
 	text := "alpha beta gamma delta epsilon"
 	terms := []string{"omega"}
-	got := SnippetAroundTerm(text, terms, 8)
+	got := SnippetAroundTerm(text, terms, 8, lexical.Analyzer{})
 	if got != "alpha be..." {
 		t.Fatalf("unexpected snippet: %q", got)
 	}
 }
 
 func TestSnippetAroundTermZeroMax(t *testing.T) {
-	got := SnippetAroundTerm("alpha", []string{"alpha"}, 0)
+	got := SnippetAroundTerm("alpha", []string{"alpha"}, 0, lexical.Analyzer{})
 	if got != "" {
 		t.Fatalf("expected empty snippet, got %q", got)
 	}
@@ -59,14 +61,14 @@ func TestTrimSnippetShortText(t *testing.T) {
 func TestSnippetAroundTermAddsEllipses(t *testing.T) {
 	text := "alpha beta gamma delta epsilon zeta"
 	terms := []string{"gamma"}
-	got := SnippetAroundTerm(text, terms, 10)
+	got := SnippetAroundTerm(text, terms, 10, lexical.Analyzer{})
 	if !strings.HasPrefix(got, "...") || !strings.HasSuffix(got, "...") {
 		t.Fatalf("expected ellipses around snippet, got %q", got)
 	}
 }
 
 func TestSnippetAroundTermEmptyTerms(t *testing.T) {
-	got := SnippetAroundTerm("alpha beta", nil, 5)
+	got := SnippetAroundTerm("alpha beta", nil, 5, lexical.Analyzer{})
 	if got != "alpha..." {
 		t.Fatalf("unexpected snippet: %q", got)
 	}
@@ -74,7 +76,7 @@ func TestSnippetAroundTermEmptyTerms(t *testing.T) {
 
 func TestSnippetAroundTermAtStart(t *testing.T) {
 	text := "alpha beta"
-	got := SnippetAroundTerm(text, []string{"alpha"}, 20)
+	got := SnippetAroundTerm(text, []string{"alpha"}, 20, lexical.Analyzer{})
 	if strings.HasPrefix(got, "...") || strings.HasSuffix(got, "...") {
 		t.Fatalf("did not expect ellipses, got %q", got)
 	}
@@ -82,7 +84,7 @@ func TestSnippetAroundTermAtStart(t *testing.T) {
 
 func TestSnippetAroundTermAtEnd(t *testing.T) {
 	text := "alpha beta gamma"
-	got := SnippetAroundTerm(text, []string{"gamma"}, 10)
+	got := SnippetAroundTerm(text, []string{"gamma"}, 10, lexical.Analyzer{})
 	if !strings.HasPrefix(got, "...") {
 		t.Fatalf("expected prefix ellipsis, got %q", got)
 	}
@@ -93,7 +95,7 @@ func TestSnippetAroundTermAtEnd(t *testing.T) {
 
 func TestSnippetAroundTermSkipsMissingTerm(t *testing.T) {
 	text := "alpha beta gamma"
-	got := SnippetAroundTerm(text, []string{"missing", "beta"}, 10)
+	got := SnippetAroundTerm(text, []string{"missing", "beta"}, 10, lexical.Analyzer{})
 	if !strings.Contains(got, "beta") {
 		t.Fatalf("expected snippet containing beta, got %q", got)
 	}
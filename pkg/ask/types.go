@@ -0,0 +1,20 @@
+package ask
+
+import "scry/pkg/search"
+
+type Chunk struct {
+	ID       string
+	Repo     string // set when chunks come from several repositories
+	FilePath string
+	// Lang selects the analyzer for Text.
+	Lang       string
+	StartLine  int
+	EndLine    int
+	Text       string
+	Score      float64
+	MatchCount int
+	// Fired lists the rules that adjusted Score.
+	Fired []Firing
+	// Search is the retrieval score breakdown, when the search explained it.
+	Search *search.Explanation
+}
@@ -7,4 +7,7 @@ type Chunk struct {
 	EndLine   int
 	Text      string
 	Lang      string
+	// Symbol names what the chunk defines within its file, e.g. "Scanner.ListFiles"
+	// or a markdown heading path. It is empty for chunks without one.
+	Symbol string
 }
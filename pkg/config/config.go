@@ -1,29 +1,327 @@
 package config
 
 import (
-	"errors"
+	"math"
 	"os"
+	"path/filepath"
+	"strings"
+
+	"scry/pkg/ask"
+	"scry/pkg/ignore"
+	"scry/pkg/index/lexical"
+	"scry/pkg/scan"
+	"scry/pkg/workspace"
+)
+
+// DefaultTestWeight is the default scoring.test_weight.
+const DefaultTestWeight = 0.5
+
+const (
+	TestsExclude  = "exclude"
+	TestsInclude  = "include"
+	TestsSeparate = "separate"
 )
 
+// Config is the typed form of the scry config files. Sections that are
+// absent keep their defaults; unknown keys are rejected.
 type Config struct {
-	Path  string
-	Raw   string
-	Found bool
+	Path       string
+	Raw        string
+	Found      bool
+	Scan       Scan
+	Ignore     Ignore
+	Index      Index
+	Analyzer   Analyzer
+	Chunking   Chunking
+	Scoring    Scoring
+	Ask        Ask
+	Embeddings Embeddings
+	// Origins maps dotted keys to the layer that set them; keys left at
+	// their defaults are absent.
+	Origins map[string]Origin
+}
+
+// Scan selects how files are enumerated. Git lists files from the git index
+// and Untracked adds untracked, non-ignored files to that listing. Files
+// larger than MaxFileSize bytes are skipped; zero disables the limit.
+type Scan struct {
+	Git            bool
+	Untracked      bool
+	FollowSymlinks bool
+	Submodules     bool
+	MaxFileSize    int64
+}
+
+// Ignore configures the built-in ignore patterns applied before .gitignore
+// and .scryignore. Tests selects a preset for test files: exclude drops them,
+// include indexes them like any other file, and separate indexes them flagged
+// so search ranks them below non-test code.
+type Ignore struct {
+	Tests        string
+	TestPatterns []string
+	Defaults     []string
+}
+
+// Index selects where the index is stored: in the repository's .scry
+// directory, or in CacheDir (the user cache when empty) keyed by repository
+// so read-only checkouts can be indexed and a new worktree's index can be
+// seeded from another worktree's.
+type Index struct {
+	Location string
+	CacheDir string
+}
+
+// Storage converts the index section into workspace storage options.
+func (i Index) Storage() workspace.Storage {
+	dir := i.CacheDir
+	if rest, ok := strings.CutPrefix(dir, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			dir = filepath.Join(home, rest)
+		}
+	}
+	return workspace.Storage{Location: i.Location, CacheDir: dir}
+}
+
+// Analyzer configures how indexed text and queries are split into terms.
+// Text is brought to the Unicode Normalization form, lowercased by the case
+// rules of Locale and, with FoldDiacritics, stripped of Latin accents so that
+// "nasıl" matches "nasil". SplitIdentifiers adds the words of identifiers
+// such as FileHash, and Stem their English stems. Terms shorter than
+// MinLength runes, Stopwords and, with BuiltinStopwords, the built-in
+// stopwords of the text's language are dropped. The next index run rebuilds
+// indexes built with other settings.
+type Analyzer struct {
+	Normalization    string
+	Lowercase        bool
+	Locale           string
+	FoldDiacritics   bool
+	SplitIdentifiers bool
+	Stem             bool
+	BuiltinStopwords bool
+	MinLength        int
+	Stopwords        []string
+}
+
+// Lexical converts the analyzer section into a lexical analyzer.
+func (a Analyzer) Lexical() lexical.Analyzer {
+	out := lexical.Analyzer{
+		Normalization:      a.Normalization,
+		KeepCase:           !a.Lowercase,
+		Locale:             a.Locale,
+		KeepDiacritics:     !a.FoldDiacritics,
+		NoSplit:            !a.SplitIdentifiers,
+		NoStem:             !a.Stem,
+		NoBuiltinStopwords: !a.BuiltinStopwords,
+		MinLength:          a.MinLength,
+	}
+	if len(a.Stopwords) > 0 {
+		out.Stopwords = map[string]bool{}
+		for _, w := range a.Stopwords {
+			out.Stopwords[out.Fold(w)] = true
+		}
+	}
+	return out
+}
+
+// Chunking limits chunk size. Chunks longer than MaxLines lines are split
+// into consecutive pieces; zero keeps parser chunks whole.
+type Chunking struct {
+	MaxLines int
+}
+
+// Scoring tunes lexical ranking. TestWeight scales the score of test chunks
+// when tests are down-ranked.
+type Scoring struct {
+	TestWeight float64
+}
+
+// Ask controls evidence selection for scry ask: K candidate chunks are
+// retrieved, Rules adjust their scores, at most MaxEvidence snippets of
+// SnippetChars characters are shown, and answers whose evidence scores below
+// MinScore are refused.
+type Ask struct {
+	K            int
+	MaxEvidence  int
+	SnippetChars int
+	MinScore     float64
+	Rules        []ask.Rule
+}
+
+// Embeddings selects an embedding provider. No provider ships yet, so
+// Enabled is rejected rather than silently indexing lexically only.
+type Embeddings struct {
+	Enabled  bool
+	Provider string
+	Model    string
 }
 
+// MatcherOptions converts the ignore section into matcher options.
+func (i Ignore) MatcherOptions() ignore.Options {
+	return ignore.Options{
+		Defaults:     i.Defaults,
+		TestPatterns: i.TestPatterns,
+		IncludeTests: i.Tests != TestsExclude,
+	}
+}
+
+// ScanOptions combines the scan and ignore sections into scanner options.
+func (c Config) ScanOptions() scan.Options {
+	maxSize := c.Scan.MaxFileSize
+	if maxSize == 0 {
+		maxSize = -1
+	}
+	return scan.Options{
+		Ignore:         c.Ignore.MatcherOptions(),
+		Git:            c.Scan.Git,
+		Untracked:      c.Scan.Untracked,
+		FollowSymlinks: c.Scan.FollowSymlinks,
+		Submodules:     c.Scan.Submodules,
+		MaxFileSize:    maxSize,
+	}
+}
+
+func Default() Config {
+	return Config{
+		Path: ".scry.yml",
+		Scan: Scan{
+			MaxFileSize: scan.DefaultMaxFileSize,
+		},
+		Ignore: Ignore{
+			Tests:        TestsExclude,
+			TestPatterns: append([]string{}, ignore.DefaultTestPatterns...),
+		},
+		Index: Index{
+			Location: workspace.LocationRepo,
+		},
+		Analyzer: Analyzer{
+			Normalization:    lexical.NFKC,
+			Lowercase:        true,
+			FoldDiacritics:   true,
+			SplitIdentifiers: true,
+			Stem:             true,
+			BuiltinStopwords: true,
+			MinLength:        lexical.DefaultMinLength,
+		},
+		Scoring: Scoring{
+			TestWeight: DefaultTestWeight,
+		},
+		Ask: Ask{
+			K:            6,
+			MaxEvidence:  2,
+			SnippetChars: 240,
+			MinScore:     1.0,
+		},
+	}
+}
+
+// Load reads the repo config at path (.scry.yml when empty) over the
+// defaults. A missing file is an error only if required is set.
 func Load(path string, required bool) (Config, error) {
-	cfgPath := path
-	if cfgPath == "" {
-		cfgPath = ".scry.yml"
+	return LoadSources(Sources{Repo: path, RepoRequired: required})
+}
+
+// field binds a dotted config key to the Config value it sets, with the
+// constraints its values must meet.
+type field struct {
+	key   string
+	value any
+	enum  []string
+	// min bounds int values; min and max bound float64 values.
+	min, max float64
+	doc      string
+}
+
+// fields lists every config key in display order. *int64 values are byte
+// sizes.
+func (c *Config) fields() []field {
+	return []field{
+		{key: "scan.git", value: &c.Scan.Git, doc: "list files from the git index"},
+		{key: "scan.untracked", value: &c.Scan.Untracked, doc: "with git: also list untracked, non-ignored files"},
+		{key: "scan.follow_symlinks", value: &c.Scan.FollowSymlinks, doc: "follow symlinks"},
+		{key: "scan.submodules", value: &c.Scan.Submodules, doc: "descend into submodules and nested repositories"},
+		{key: "scan.max_file_size", value: &c.Scan.MaxFileSize, doc: "skip larger files (KB, MB, GB); 0 disables the limit"},
+		{key: "ignore.tests", value: &c.Ignore.Tests, enum: []string{TestsExclude, TestsInclude, TestsSeparate}, doc: "exclude | include | separate"},
+		{key: "ignore.test_patterns", value: &c.Ignore.TestPatterns, doc: "patterns identifying test files"},
+		{key: "ignore.defaults", value: &c.Ignore.Defaults, doc: "extra lowest-precedence ignore patterns"},
+		{key: "index.location", value: &c.Index.Location, enum: []string{workspace.LocationRepo, workspace.LocationCache}, doc: "repo (.scry/) | cache (per-user cache dir)"},
+		{key: "index.cache_dir", value: &c.Index.CacheDir, doc: "cache location; empty uses ~/.cache/scry"},
+		{key: "analyzer.normalization", value: &c.Analyzer.Normalization, enum: []string{lexical.NFKC, lexical.NFC, lexical.NoNormalization}, doc: "Unicode normalization: nfkc | nfc | none"},
+		{key: "analyzer.lowercase", value: &c.Analyzer.Lowercase, doc: "match terms case-insensitively"},
+		{key: "analyzer.locale", value: &c.Analyzer.Locale, enum: []string{"", lexical.LocaleTurkish, lexical.LocaleAzerbaijani}, doc: "case rules: empty (Unicode) | tr | az"},
+		{key: "analyzer.fold_diacritics", value: &c.Analyzer.FoldDiacritics, doc: "match accented Latin letters unaccented (nasıl -> nasil)"},
+		{key: "analyzer.split_identifiers", value: &c.Analyzer.SplitIdentifiers, doc: "also index the words of camelCase and snake_case names"},
+		{key: "analyzer.stem", value: &c.Analyzer.Stem, doc: "also index English stems (indexing -> index)"},
+		{key: "analyzer.builtin_stopwords", value: &c.Analyzer.BuiltinStopwords, doc: "drop Go keywords and English function words"},
+		{key: "analyzer.min_length", value: &c.Analyzer.MinLength, min: 1, doc: "drop shorter terms (in characters)"},
+		{key: "analyzer.stopwords", value: &c.Analyzer.Stopwords, doc: "terms never indexed or searched"},
+		{key: "chunking.max_lines", value: &c.Chunking.MaxLines, doc: "split longer chunks; 0 keeps them whole"},
+		{key: "scoring.test_weight", value: &c.Scoring.TestWeight, min: 0, max: 1, doc: "score multiplier for down-ranked test chunks"},
+		{key: "ask.k", value: &c.Ask.K, min: 1, doc: "candidate chunks retrieved"},
+		{key: "ask.max_evidence", value: &c.Ask.MaxEvidence, min: 1, doc: "snippets shown"},
+		{key: "ask.snippet_chars", value: &c.Ask.SnippetChars, min: 1, doc: "snippet length"},
+		{key: "ask.min_score", value: &c.Ask.MinScore, min: 0, max: math.Inf(1), doc: "refuse answers scoring below this"},
+		{key: "ask.rules", value: &c.Ask.Rules, doc: "boost, penalty and whitelist rules (see README)"},
+		{key: "embeddings.enabled", value: &c.Embeddings.Enabled, doc: "reserved: no provider ships yet"},
+		{key: "embeddings.provider", value: &c.Embeddings.Provider},
+		{key: "embeddings.model", value: &c.Embeddings.Model},
 	}
+}
+
+func (c *Config) field(key string) (field, bool) {
+	for _, f := range c.fields() {
+		if f.key == key {
+			return f, true
+		}
+	}
+	return field{}, false
+}
+
+func (c *Config) isSection(name string) bool {
+	for _, f := range c.fields() {
+		if strings.HasPrefix(f.key, name+".") {
+			return true
+		}
+	}
+	return false
+}
 
-	data, err := os.ReadFile(cfgPath)
-	if err != nil {
-		if errors.Is(err, os.ErrNotExist) && !required {
-			return Config{Path: cfgPath, Found: false}, nil
+// decode applies the keys set in a parsed config file, recording where each
+// came from.
+func (c *Config) decode(root *node, origin Origin) error {
+	d := decoder{path: origin.Path}
+	if err := d.expect(root, "config", mappingNode); err != nil {
+		return err
+	}
+	for _, section := range root.keys {
+		sn := root.fields[section]
+		if !c.isSection(section) {
+			return d.unknown(sn, section)
+		}
+		if err := d.expect(sn, section, mappingNode); err != nil {
+			return err
+		}
+		for _, key := range sn.keys {
+			v := sn.fields[key]
+			f, ok := c.field(section + "." + key)
+			if !ok {
+				return d.unknown(v, section+"."+key)
+			}
+			if err := d.set(f, v); err != nil {
+				return err
+			}
+			o := origin
+			o.Line = v.keyLine
+			c.Origins[f.key] = o
 		}
-		return Config{}, err
 	}
+	return nil
+}
 
-	return Config{Path: cfgPath, Raw: string(data), Found: true}, nil
+// validate checks the merged result, once all layers are applied.
+func (c *Config) validate() error {
+	if c.Embeddings.Enabled {
+		o := c.Origins["embeddings.enabled"]
+		return &Error{Path: o.Path, Line: o.Line, Msg: "embeddings.enabled: no embedding provider is available yet"}
+	}
+	return nil
 }
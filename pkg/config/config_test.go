@@ -3,7 +3,13 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
 	"testing"
+
+	"scry/pkg/ask"
+	"scry/pkg/index/lexical"
 )
 
 func TestLoadDefaultMissingOptional(t *testing.T) {
@@ -43,7 +49,7 @@ func TestLoadExplicitRequiredMissing(t *testing.T) {
 func TestLoadReadsContents(t *testing.T) {
 	root := t.TempDir()
 	path := filepath.Join(root, ".scry.yml")
-	if err := os.WriteFile(path, []byte("foo: bar\n"), 0o644); err != nil {
+	if err := os.WriteFile(path, []byte("scan:\n  git: true\n"), 0o644); err != nil {
 		t.Fatalf("write: %v", err)
 	}
 	cfg, err := Load(path, true)
@@ -54,3 +60,267 @@ func TestLoadReadsContents(t *testing.T) {
 		t.Fatalf("expected found config with raw content")
 	}
 }
+
+func TestLoadIgnoreSection(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".scry.yml")
+	content := "ignore:\n  tests: separate\n  defaults: [dist/]\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	cfg, err := Load(path, true)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Ignore.Tests != TestsSeparate {
+		t.Fatalf("unexpected tests preset: %s", cfg.Ignore.Tests)
+	}
+	opts := cfg.Ignore.MatcherOptions()
+	if !opts.IncludeTests || len(opts.Defaults) != 1 || len(opts.TestPatterns) != 2 {
+		t.Fatalf("unexpected matcher options: %+v", opts)
+	}
+}
+
+func TestLoadIgnoreSectionInvalidPreset(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".scry.yml")
+	if err := os.WriteFile(path, []byte("ignore:\n  tests: maybe\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	_, err := Load(path, true)
+	if err == nil || !strings.Contains(err.Error(), ".scry.yml:2:") {
+		t.Fatalf("expected line-numbered error, got %v", err)
+	}
+}
+
+func TestLoadScanSection(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".scry.yml")
+	if err := os.WriteFile(path, []byte("scan:\n  git: true\n  untracked: no\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	cfg, err := Load(path, true)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if !cfg.Scan.Git || cfg.Scan.Untracked {
+		t.Fatalf("unexpected scan config: %+v", cfg.Scan)
+	}
+	if err := os.WriteFile(path, []byte("scan:\n  git: sometimes\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err := Load(path, true); err == nil || !strings.Contains(err.Error(), ":2:") {
+		t.Fatalf("expected line-numbered bool error, got %v", err)
+	}
+}
+
+func TestLoadScanPolicies(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".scry.yml")
+	body := "scan:\n  follow_symlinks: true\n  submodules: yes\n  max_file_size: 2MB\n"
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	cfg, err := Load(path, true)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if !cfg.Scan.FollowSymlinks || !cfg.Scan.Submodules || cfg.Scan.MaxFileSize != 2<<20 {
+		t.Fatalf("unexpected scan config: %+v", cfg.Scan)
+	}
+	if opts := cfg.ScanOptions(); opts.MaxFileSize != 2<<20 {
+		t.Fatalf("unexpected scan options: %+v", opts)
+	}
+
+	if err := os.WriteFile(path, []byte("scan:\n  max_file_size: 0\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	cfg, err = Load(path, true)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if opts := cfg.ScanOptions(); opts.MaxFileSize >= 0 {
+		t.Fatalf("expected zero to disable the limit, got %d", opts.MaxFileSize)
+	}
+
+	if err := os.WriteFile(path, []byte("scan:\n  git: true\n  max_file_size: lots\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err := Load(path, true); err == nil || !strings.Contains(err.Error(), ":3:") {
+		t.Fatalf("expected line-numbered size error, got %v", err)
+	}
+}
+
+func TestLoadTypedSections(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".scry.yml")
+	body := `chunking:
+  max_lines: 80
+scoring:
+  test_weight: 0.25
+ask:
+  k: 10
+  max_evidence: 3
+  snippet_chars: 400
+  min_score: 2.5
+embeddings:
+  provider: local
+  model: tiny
+`
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	cfg, err := Load(path, true)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Chunking.MaxLines != 80 || cfg.Scoring.TestWeight != 0.25 {
+		t.Fatalf("unexpected chunking/scoring: %+v %+v", cfg.Chunking, cfg.Scoring)
+	}
+	if want := (Ask{K: 10, MaxEvidence: 3, SnippetChars: 400, MinScore: 2.5}); !reflect.DeepEqual(cfg.Ask, want) {
+		t.Fatalf("unexpected ask config: %+v", cfg.Ask)
+	}
+	if want := (Embeddings{Provider: "local", Model: "tiny"}); cfg.Embeddings != want {
+		t.Fatalf("unexpected embeddings config: %+v", cfg.Embeddings)
+	}
+
+	// Sections left out keep their defaults.
+	if err := os.WriteFile(path, []byte("ask:\n  k: 3\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	cfg, err = Load(path, true)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if def := Default(); cfg.Ask.K != 3 || cfg.Ask.MaxEvidence != def.Ask.MaxEvidence || cfg.Scoring != def.Scoring {
+		t.Fatalf("expected defaults kept, got %+v %+v", cfg.Ask, cfg.Scoring)
+	}
+}
+
+func TestLoadValidation(t *testing.T) {
+	cases := []struct {
+		body string
+		want string
+	}{
+		{"scan:\n  git: true\nsearch:\n  limit: 3\n", ":3: search: unknown key"},
+		{"ask:\n  k: 2\n  top: 4\n", ":3: ask.top: unknown key"},
+		{"ignore:\n  tests: include\n  extra:\n    - a\n", ":3: ignore.extra: unknown key"},
+		{"ask:\n  k: 0\n", ":2: ask.k: must be at least 1"},
+		{"ask:\n  k: many\n", ":2: ask.k: expected an integer"},
+		{"scoring:\n  test_weight: 1.5\n", ":2: scoring.test_weight: must be between 0 and 1"},
+		{"chunking:\n  max_lines: -1\n", ":2: chunking.max_lines: must be at least 0"},
+		{"chunking: 40\n", ":1: chunking: expected mapping"},
+		{"embeddings:\n  provider: local\n  enabled: true\n", ":3: embeddings.enabled: no embedding provider is available yet"},
+		{"analyzer:\n  min_length: 0\n", ":2: analyzer.min_length: must be at least 1"},
+		{"analyzer:\n  locale: de\n", `:2: analyzer.locale: invalid value "de" (want |tr|az)`},
+		{"ask:\n  rules:\n    - kind: boost\n", ":3: ask.rules[0].paths: required"},
+		{"ask:\n  rules:\n    - paths: [cmd/]\n      kind: demote\n", ":4: ask.rules[0].kind: invalid value"},
+		{"ask:\n  rules:\n    - paths: [cmd/]\n      bonus: 2\n", ":4: ask.rules[0].bonus: unknown key"},
+	}
+	path := filepath.Join(t.TempDir(), ".scry.yml")
+	for _, tc := range cases {
+		if err := os.WriteFile(path, []byte(tc.body), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		_, err := Load(path, true)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("config %q: expected error containing %q, got %v", tc.body, tc.want, err)
+		}
+	}
+}
+
+func TestLoadAnalyzer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".scry.yml")
+	body := "analyzer:\n  normalization: nfc\n  lowercase: false\n  locale: tr\n  fold_diacritics: no\n  split_identifiers: no\n  stem: no\n  builtin_stopwords: no\n  min_length: 3\n  stopwords: [The, err, IŞIK]\n"
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	cfg, err := Load(path, true)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	a := cfg.Analyzer.Lexical()
+	want := lexical.Analyzer{
+		Normalization: lexical.NFC, KeepCase: true, Locale: lexical.LocaleTurkish, KeepDiacritics: true,
+		NoSplit: true, NoStem: true, NoBuiltinStopwords: true, MinLength: 3,
+		Stopwords: map[string]bool{"the": true, "err": true, "ışık": true},
+	}
+	if !reflect.DeepEqual(a, want) {
+		t.Fatalf("unexpected analyzer: %+v", a)
+	}
+	if def := Default().Analyzer.Lexical(); def.Fingerprint() != (lexical.Analyzer{}).Fingerprint() {
+		t.Fatalf("expected the default config to use the default analyzer, got %+v", def)
+	}
+}
+
+func TestLoadAskRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".scry.yml")
+	body := `ask:
+  rules:
+    - name: scanner
+      terms: [scan, ignore]
+      paths: ["pkg/scan/", "pkg/ignore/"]
+      weight: 2
+    - kind: penalty
+      paths:
+        - cmd/
+`
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	cfg, err := Load(path, true)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	want := []ask.Rule{
+		{Name: "scanner", Kind: ask.RuleBoost, Terms: []string{"scan", "ignore"}, Paths: []string{"pkg/scan/", "pkg/ignore/"}, Weight: 2},
+		{Name: "ask.rules[1]", Kind: ask.RulePenalty, Paths: []string{"cmd/"}, Weight: 1},
+	}
+	if !reflect.DeepEqual(cfg.Ask.Rules, want) {
+		t.Fatalf("unexpected rules:\n%+v\nwant\n%+v", cfg.Ask.Rules, want)
+	}
+	if s := cfg.Settings(); s[len(s)-4].Key != "ask.rules" || s[len(s)-4].Value != "[scanner, ask.rules[1]]" {
+		t.Fatalf("unexpected setting: %+v", s[len(s)-4])
+	}
+}
+
+func TestStarterMatchesDefaults(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".scry.yml")
+	if err := os.WriteFile(path, []byte(Starter()), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	cfg, err := Load(path, true)
+	if err != nil {
+		t.Fatalf("load starter: %v", err)
+	}
+	if len(cfg.Origins) != 0 {
+		t.Fatalf("expected starter to set nothing, got %v", cfg.Origins)
+	}
+
+	// Uncommenting every setting reproduces the defaults.
+	setting := regexp.MustCompile(`^# ( *[a-z_]+:( |$))`)
+	var lines []string
+	for _, l := range strings.Split(Starter(), "\n") {
+		lines = append(lines, setting.ReplaceAllString(l, "$1"))
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	cfg, err = Load(path, true)
+	if err != nil {
+		t.Fatalf("load uncommented starter: %v", err)
+	}
+	if len(cfg.Origins) != len(cfg.Settings()) {
+		t.Fatalf("expected every key set, got %d of %d", len(cfg.Origins), len(cfg.Settings()))
+	}
+	def := Default()
+	for i, s := range cfg.Settings() {
+		if want := def.Settings()[i]; s.Value != want.Value {
+			t.Fatalf("%s: starter value %s, default %s", s.Key, s.Value, want.Value)
+		}
+	}
+}
+
+func TestDecodeUnsupportedFieldType(t *testing.T) {
+	var v uint
+	err := decoder{path: ".scry.yml"}.set(field{key: "scan.workers", value: &v}, &node{kind: scalarNode, value: "2", line: 4})
+	if err == nil || !strings.Contains(err.Error(), ":4: scan.workers: unsupported field type *uint") {
+		t.Fatalf("expected unsupported type error, got %v", err)
+	}
+}
@@ -0,0 +1,217 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"scry/pkg/ask"
+)
+
+type decoder struct {
+	path string
+}
+
+func (d decoder) errorf(n *node, format string, args ...any) error {
+	return &Error{Path: d.path, Line: n.line, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (d decoder) expect(n *node, name string, kind nodeKind) error {
+	if n.kind == kind {
+		return nil
+	}
+	want := (&node{kind: kind}).kindName()
+	return d.errorf(n, "%s: expected %s, got %s", name, want, n.kindName())
+}
+
+func (d decoder) str(n *node, name string) (string, error) {
+	if err := d.expect(n, name, scalarNode); err != nil {
+		return "", err
+	}
+	return n.value, nil
+}
+
+func (d decoder) boolean(n *node, name string) (bool, error) {
+	v, err := d.str(n, name)
+	if err != nil {
+		return false, err
+	}
+	switch strings.ToLower(v) {
+	case "true", "yes", "on":
+		return true, nil
+	case "false", "no", "off":
+		return false, nil
+	default:
+		return false, d.errorf(n, "%s: expected true or false, got %q", name, v)
+	}
+}
+
+// size parses a byte count with an optional KB, MB or GB suffix (powers of 1024).
+func (d decoder) size(n *node, name string) (int64, error) {
+	v, err := d.str(n, name)
+	if err != nil {
+		return 0, err
+	}
+	num := strings.ToUpper(strings.TrimSpace(v))
+	mult := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		mult   int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(num, unit.suffix) {
+			num = strings.TrimSpace(strings.TrimSuffix(num, unit.suffix))
+			mult = unit.mult
+			break
+		}
+	}
+	parsed, err := strconv.ParseInt(num, 10, 64)
+	if err != nil || parsed < 0 {
+		return 0, d.errorf(n, "%s: invalid size %q", name, v)
+	}
+	return parsed * mult, nil
+}
+
+// set decodes n into the value f points to.
+func (d decoder) set(f field, n *node) error {
+	var err error
+	switch v := f.value.(type) {
+	case *bool:
+		*v, err = d.boolean(n, f.key)
+	case *int64:
+		*v, err = d.size(n, f.key)
+	case *int:
+		*v, err = d.integer(n, f.key, int(f.min))
+	case *float64:
+		*v, err = d.number(n, f.key, f.min, f.max)
+	case *string:
+		if f.enum != nil {
+			*v, err = d.enum(n, f.key, f.enum...)
+		} else {
+			*v, err = d.str(n, f.key)
+		}
+	case *[]string:
+		*v, err = d.stringList(n, f.key)
+	case *[]ask.Rule:
+		*v, err = d.rules(n, f.key)
+	default:
+		err = d.errorf(n, "%s: unsupported field type %T", f.key, f.value)
+	}
+	return err
+}
+
+// unknown reports a key the schema does not define, at the key's line.
+func (d decoder) unknown(n *node, name string) error {
+	return &Error{Path: d.path, Line: n.keyLine, Msg: fmt.Sprintf("%s: unknown key", name)}
+}
+
+// integer parses a whole number no smaller than min.
+func (d decoder) integer(n *node, name string, min int) (int, error) {
+	v, err := d.str(n, name)
+	if err != nil {
+		return 0, err
+	}
+	parsed, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil {
+		return 0, d.errorf(n, "%s: expected an integer, got %q", name, v)
+	}
+	if parsed < min {
+		return 0, d.errorf(n, "%s: must be at least %d, got %d", name, min, parsed)
+	}
+	return parsed, nil
+}
+
+// number parses a float within [min, max].
+func (d decoder) number(n *node, name string, min, max float64) (float64, error) {
+	v, err := d.str(n, name)
+	if err != nil {
+		return 0, err
+	}
+	parsed, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	if err != nil || math.IsNaN(parsed) {
+		return 0, d.errorf(n, "%s: expected a number, got %q", name, v)
+	}
+	if parsed < min || parsed > max {
+		if math.IsInf(max, 1) {
+			return 0, d.errorf(n, "%s: must be at least %g, got %g", name, min, parsed)
+		}
+		return 0, d.errorf(n, "%s: must be between %g and %g, got %g", name, min, max, parsed)
+	}
+	return parsed, nil
+}
+
+func (d decoder) enum(n *node, name string, allowed ...string) (string, error) {
+	v, err := d.str(n, name)
+	if err != nil {
+		return "", err
+	}
+	for _, a := range allowed {
+		if v == a {
+			return v, nil
+		}
+	}
+	return "", d.errorf(n, "%s: invalid value %q (want %s)", name, v, strings.Join(allowed, "|"))
+}
+
+func (d decoder) stringList(n *node, name string) ([]string, error) {
+	if n.kind == scalarNode && n.value == "" {
+		return nil, nil
+	}
+	if err := d.expect(n, name, sequenceNode); err != nil {
+		return nil, err
+	}
+	out := make([]string, 0, len(n.items))
+	for i, item := range n.items {
+		v, err := d.str(item, fmt.Sprintf("%s[%d]", name, i))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+// rules decodes a list of ask rules. Each rule needs paths; kind defaults to
+// boost, weight to 1 and name to the rule's position.
+func (d decoder) rules(n *node, name string) ([]ask.Rule, error) {
+	if n.kind == scalarNode && n.value == "" {
+		return nil, nil
+	}
+	if err := d.expect(n, name, sequenceNode); err != nil {
+		return nil, err
+	}
+	out := make([]ask.Rule, 0, len(n.items))
+	for i, item := range n.items {
+		prefix := fmt.Sprintf("%s[%d]", name, i)
+		if err := d.expect(item, prefix, mappingNode); err != nil {
+			return nil, err
+		}
+		rule := ask.Rule{Name: prefix, Kind: ask.RuleBoost, Weight: 1}
+		for _, key := range item.keys {
+			v := item.fields[key]
+			var err error
+			switch key {
+			case "name":
+				rule.Name, err = d.str(v, prefix+".name")
+			case "kind":
+				rule.Kind, err = d.enum(v, prefix+".kind", ask.RuleBoost, ask.RulePenalty, ask.RuleWhitelist)
+			case "terms":
+				rule.Terms, err = d.stringList(v, prefix+".terms")
+			case "paths":
+				rule.Paths, err = d.stringList(v, prefix+".paths")
+			case "weight":
+				rule.Weight, err = d.number(v, prefix+".weight", 0, math.Inf(1))
+			default:
+				err = d.unknown(v, prefix+"."+key)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		if len(rule.Paths) == 0 {
+			return nil, d.errorf(item, "%s.paths: required", prefix)
+		}
+		out = append(out, rule)
+	}
+	return out, nil
+}
@@ -0,0 +1,210 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"scry/pkg/ask"
+)
+
+// Configuration layers, lowest precedence first. Command-line flags are
+// applied by each command on top of the loaded Config.
+const (
+	LayerDefault = "default"
+	LayerUser    = "user"
+	LayerRepo    = "repo"
+	LayerEnv     = "env"
+)
+
+// Origin records where a key's effective value was set.
+type Origin struct {
+	Layer string
+	// Path is the config file, or the environment variable for LayerEnv.
+	Path string
+	Line int
+}
+
+func (o Origin) String() string {
+	switch {
+	case o.Layer == LayerDefault || o.Layer == "":
+		return LayerDefault
+	case o.Line > 0:
+		return fmt.Sprintf("%s %s:%d", o.Layer, o.Path, o.Line)
+	default:
+		return fmt.Sprintf("%s %s", o.Layer, o.Path)
+	}
+}
+
+// Sources names the layers LoadSources merges over the defaults.
+type Sources struct {
+	// User is the user-global config file; empty skips the layer. A missing
+	// file is not an error.
+	User string
+	// Repo is the repository config file, .scry.yml when empty. A missing
+	// file is an error only if RepoRequired is set.
+	Repo         string
+	RepoRequired bool
+	// Env looks up SCRY_* variables, usually os.LookupEnv; nil skips the
+	// layer.
+	Env func(string) (string, bool)
+}
+
+// UserPath returns the user-global config file:
+// $XDG_CONFIG_HOME/scry/config.yml, falling back to ~/.config. It is empty
+// when neither can be determined.
+func UserPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "scry", "config.yml")
+}
+
+// LoadSources merges the defaults, the user file, the repo file and the
+// environment, later layers overriding earlier ones key by key. Path, Raw
+// and Found describe the repo file.
+func LoadSources(src Sources) (Config, error) {
+	cfg := Default()
+	if src.Repo != "" {
+		cfg.Path = src.Repo
+	}
+	cfg.Origins = map[string]Origin{}
+
+	if src.User != "" {
+		if _, _, err := cfg.loadFile(src.User, LayerUser, false); err != nil {
+			return Config{}, err
+		}
+	}
+	raw, found, err := cfg.loadFile(cfg.Path, LayerRepo, src.RepoRequired)
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.Raw, cfg.Found = raw, found
+	if src.Env != nil {
+		if err := cfg.loadEnv(src.Env); err != nil {
+			return Config{}, err
+		}
+	}
+	if err := cfg.validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) loadFile(path, layer string, required bool) (string, bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) && !required {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	root, err := parseYAML(path, string(data))
+	if err != nil {
+		return "", false, err
+	}
+	if err := c.decode(root, Origin{Layer: layer, Path: path}); err != nil {
+		return "", false, err
+	}
+	return string(data), true, nil
+}
+
+// EnvName returns the environment variable that sets key, e.g.
+// SCRY_SCAN_MAX_FILE_SIZE for scan.max_file_size.
+func EnvName(key string) string {
+	return "SCRY_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// loadEnv applies SCRY_* variables. Values use the config file's scalar
+// syntax; lists may be written as [a, b] or a, b.
+func (c *Config) loadEnv(lookup func(string) (string, bool)) error {
+	for _, f := range c.fields() {
+		name := EnvName(f.key)
+		value, ok := lookup(name)
+		if !ok {
+			continue
+		}
+		origin := Origin{Layer: LayerEnv, Path: name}
+		if _, isRules := f.value.(*[]ask.Rule); isRules {
+			return &Error{Path: name, Msg: f.key + ": cannot be set from the environment"}
+		}
+		if _, isList := f.value.(*[]string); isList && !strings.HasPrefix(value, "[") {
+			value = "[" + value + "]"
+		}
+		p := &yamlParser{path: origin.Path}
+		n, err := p.parseScalar(0, strings.TrimSpace(value))
+		if err != nil {
+			return err
+		}
+		if err := (decoder{path: origin.Path}).set(f, n); err != nil {
+			return err
+		}
+		c.Origins[f.key] = origin
+	}
+	return nil
+}
+
+// Setting is one key's effective value.
+type Setting struct {
+	Key    string
+	Value  string
+	Origin Origin
+}
+
+// Settings lists every key with its effective value and origin, in schema
+// order.
+func (c Config) Settings() []Setting {
+	var out []Setting
+	for _, f := range c.fields() {
+		out = append(out, Setting{Key: f.key, Value: formatValue(f.value), Origin: c.Origins[f.key]})
+	}
+	return out
+}
+
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case *bool:
+		return strconv.FormatBool(*v)
+	case *int64:
+		return formatSize(*v)
+	case *int:
+		return strconv.Itoa(*v)
+	case *float64:
+		return strconv.FormatFloat(*v, 'g', -1, 64)
+	case *string:
+		if *v == "" {
+			return `""`
+		}
+		return *v
+	case *[]string:
+		return "[" + strings.Join(*v, ", ") + "]"
+	case *[]ask.Rule:
+		names := make([]string, len(*v))
+		for i, r := range *v {
+			names[i] = r.Name
+		}
+		return "[" + strings.Join(names, ", ") + "]"
+	}
+	return ""
+}
+
+// formatSize writes a byte count with the largest unit that divides it.
+func formatSize(n int64) string {
+	for _, unit := range []struct {
+		suffix string
+		size   int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}} {
+		if n != 0 && n%unit.size == 0 {
+			return strconv.FormatInt(n/unit.size, 10) + unit.suffix
+		}
+	}
+	return strconv.FormatInt(n, 10)
+}
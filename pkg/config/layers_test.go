@@ -0,0 +1,100 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadSourcesLayers(t *testing.T) {
+	dir := t.TempDir()
+	user := filepath.Join(dir, "user.yml")
+	repo := filepath.Join(dir, ".scry.yml")
+	if err := os.WriteFile(user, []byte("ask:\n  k: 9\n  min_score: 0.5\nscan:\n  git: true\n"), 0o644); err != nil {
+		t.Fatalf("write user: %v", err)
+	}
+	if err := os.WriteFile(repo, []byte("# team defaults\nask:\n  k: 4\n  max_evidence: 3\n"), 0o644); err != nil {
+		t.Fatalf("write repo: %v", err)
+	}
+	env := map[string]string{
+		"SCRY_ASK_MAX_EVIDENCE": "1",
+		"SCRY_IGNORE_DEFAULTS":  "dist/, out/",
+		"SCRY_UNRELATED":        "x",
+	}
+	cfg, err := LoadSources(Sources{
+		User: user,
+		Repo: repo,
+		Env: func(name string) (string, bool) {
+			v, ok := env[name]
+			return v, ok
+		},
+	})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Ask.K != 4 || cfg.Ask.MinScore != 0.5 || cfg.Ask.MaxEvidence != 1 || !cfg.Scan.Git {
+		t.Fatalf("unexpected merge: %+v %+v", cfg.Ask, cfg.Scan)
+	}
+	if strings.Join(cfg.Ignore.Defaults, ",") != "dist/,out/" {
+		t.Fatalf("unexpected env list: %v", cfg.Ignore.Defaults)
+	}
+	if !cfg.Found || cfg.Path != repo {
+		t.Fatalf("expected repo file recorded, got %+v", cfg)
+	}
+
+	origins := map[string]string{}
+	for _, s := range cfg.Settings() {
+		origins[s.Key] = s.Value + " " + s.Origin.String()
+	}
+	want := map[string]string{
+		"ask.k":             "4 repo " + repo + ":3",
+		"ask.min_score":     "0.5 user " + user + ":3",
+		"ask.max_evidence":  "1 env SCRY_ASK_MAX_EVIDENCE",
+		"ignore.defaults":   "[dist/, out/] env SCRY_IGNORE_DEFAULTS",
+		"ask.snippet_chars": "240 default",
+	}
+	for key, w := range want {
+		if origins[key] != w {
+			t.Fatalf("%s: expected %q, got %q", key, w, origins[key])
+		}
+	}
+}
+
+func TestLoadSourcesErrors(t *testing.T) {
+	dir := t.TempDir()
+	user := filepath.Join(dir, "user.yml")
+	if err := os.WriteFile(user, []byte("ask:\n  k: zero\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	repo := filepath.Join(dir, "missing.yml")
+	if _, err := LoadSources(Sources{User: user, Repo: repo}); err == nil || !strings.Contains(err.Error(), "user.yml:2:") {
+		t.Fatalf("expected user file error, got %v", err)
+	}
+
+	env := func(name string) (string, bool) {
+		if name == "SCRY_SCORING_TEST_WEIGHT" {
+			return "2", true
+		}
+		return "", false
+	}
+	_, err := LoadSources(Sources{User: filepath.Join(dir, "none.yml"), Repo: repo, Env: env})
+	if err == nil || !strings.Contains(err.Error(), "SCRY_SCORING_TEST_WEIGHT: scoring.test_weight") {
+		t.Fatalf("expected env error, got %v", err)
+	}
+	env = func(name string) (string, bool) { return "[]", name == "SCRY_ASK_RULES" }
+	_, err = LoadSources(Sources{Repo: repo, Env: env})
+	if err == nil || !strings.Contains(err.Error(), "ask.rules: cannot be set from the environment") {
+		t.Fatalf("expected rules env error, got %v", err)
+	}
+
+	// Validation sees the merged result.
+	if err := os.WriteFile(user, []byte("embeddings:\n  provider: local\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	env = func(name string) (string, bool) { return "true", name == "SCRY_EMBEDDINGS_ENABLED" }
+	_, err = LoadSources(Sources{User: user, Repo: repo, Env: env})
+	if err == nil || !strings.Contains(err.Error(), "SCRY_EMBEDDINGS_ENABLED: embeddings.enabled: no embedding provider") {
+		t.Fatalf("expected env enable rejected, got %v", err)
+	}
+}
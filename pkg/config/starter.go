@@ -0,0 +1,36 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+const starterHeader = `# scry configuration.
+#
+# Every key is listed at its default value. Uncomment a section and the
+# keys you want to change. Settings here apply to everyone working in the
+# repository; personal overrides belong in ~/.config/scry/config.yml and
+# SCRY_* environment variables (see ` + "`scry config show --origin`" + `).
+`
+
+// Starter returns a config file that lists every key, commented out, at its
+// default value.
+func Starter() string {
+	var b strings.Builder
+	b.WriteString(starterHeader)
+	cfg := Default()
+	section := ""
+	for _, f := range cfg.fields() {
+		sec, key, _ := strings.Cut(f.key, ".")
+		if sec != section {
+			section = sec
+			fmt.Fprintf(&b, "\n# %s:\n", sec)
+		}
+		line := fmt.Sprintf("#   %s: %s", key, formatValue(f.value))
+		if f.doc != "" {
+			line = fmt.Sprintf("%-40s # %s", line, f.doc)
+		}
+		b.WriteString(line + "\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,337 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The config format is a small YAML subset: block mappings and sequences,
+// plain or quoted scalars, flow sequences of scalars ([a, b]) and comments.
+// Anchors, multi-line scalars and flow mappings are not supported.
+
+type nodeKind int
+
+const (
+	scalarNode nodeKind = iota
+	mappingNode
+	sequenceNode
+)
+
+type node struct {
+	line    int
+	keyLine int // line of the mapping key holding the node, if any
+	kind    nodeKind
+	value   string
+	keys    []string
+	fields  map[string]*node
+	items   []*node
+}
+
+type yamlLine struct {
+	num    int
+	indent int
+	text   string
+}
+
+// Error reports a problem at a specific line of a config file.
+type Error struct {
+	Path string
+	Line int
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s", e.Path, e.Line, e.Msg)
+	}
+	return fmt.Sprintf("%s: %s", e.Path, e.Msg)
+}
+
+func parseYAML(path, src string) (*node, error) {
+	lines, err := splitYAMLLines(path, src)
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return &node{kind: mappingNode, fields: map[string]*node{}}, nil
+	}
+	p := &yamlParser{path: path, lines: lines}
+	n, err := p.parseBlock(lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(lines) {
+		return nil, p.errorf(lines[p.pos].num, "unexpected indentation")
+	}
+	return n, nil
+}
+
+func splitYAMLLines(path, src string) ([]yamlLine, error) {
+	var out []yamlLine
+	for i, raw := range strings.Split(src, "\n") {
+		raw = strings.TrimRight(raw, "\r")
+		text := stripComment(raw)
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		if text == "---" {
+			continue
+		}
+		indent := len(text) - len(strings.TrimLeft(text, " "))
+		if strings.HasPrefix(text[indent:], "\t") {
+			return nil, &Error{Path: path, Line: i + 1, Msg: "tabs are not allowed in indentation"}
+		}
+		out = append(out, yamlLine{num: i + 1, indent: indent, text: strings.TrimSpace(text)})
+	}
+	return out, nil
+}
+
+// stripComment removes a trailing "# comment" that is outside quotes.
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else if c == '\\' && quote == '"' {
+				i++
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return strings.TrimRight(line[:i], " \t")
+		}
+	}
+	return strings.TrimRight(line, " \t")
+}
+
+type yamlParser struct {
+	path  string
+	lines []yamlLine
+	pos   int
+}
+
+func (p *yamlParser) errorf(line int, format string, args ...any) error {
+	return &Error{Path: p.path, Line: line, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *yamlParser) parseBlock(indent int) (*node, error) {
+	if isSequenceItem(p.lines[p.pos].text) {
+		return p.parseSequence(indent)
+	}
+	return p.parseMapping(indent)
+}
+
+func (p *yamlParser) parseMapping(indent int) (*node, error) {
+	n := &node{line: p.lines[p.pos].num, kind: mappingNode, fields: map[string]*node{}}
+	for p.pos < len(p.lines) {
+		ln := p.lines[p.pos]
+		if ln.indent < indent {
+			break
+		}
+		if ln.indent > indent {
+			return nil, p.errorf(ln.num, "unexpected indentation")
+		}
+		if isSequenceItem(ln.text) {
+			return nil, p.errorf(ln.num, "unexpected sequence item in mapping")
+		}
+		key, rest, ok := splitKey(ln.text)
+		if !ok {
+			return nil, p.errorf(ln.num, "expected \"key: value\", got %q", ln.text)
+		}
+		if _, dup := n.fields[key]; dup {
+			return nil, p.errorf(ln.num, "duplicate key %q", key)
+		}
+		p.pos++
+		var child *node
+		if rest != "" {
+			v, err := p.parseScalar(ln.num, rest)
+			if err != nil {
+				return nil, err
+			}
+			child = v
+		} else if p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+			v, err := p.parseBlock(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			child = v
+		} else if p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isSequenceItem(p.lines[p.pos].text) {
+			v, err := p.parseSequence(indent)
+			if err != nil {
+				return nil, err
+			}
+			child = v
+		} else {
+			child = &node{line: ln.num, kind: scalarNode}
+		}
+		if child.line == 0 {
+			child.line = ln.num
+		}
+		child.keyLine = ln.num
+		n.keys = append(n.keys, key)
+		n.fields[key] = child
+	}
+	return n, nil
+}
+
+func (p *yamlParser) parseSequence(indent int) (*node, error) {
+	n := &node{line: p.lines[p.pos].num, kind: sequenceNode}
+	for p.pos < len(p.lines) {
+		ln := p.lines[p.pos]
+		if ln.indent < indent {
+			break
+		}
+		if ln.indent > indent {
+			return nil, p.errorf(ln.num, "unexpected indentation")
+		}
+		if !isSequenceItem(ln.text) {
+			break
+		}
+		rest := strings.TrimSpace(strings.TrimPrefix(ln.text, "-"))
+		switch {
+		case rest == "":
+			p.pos++
+			if p.pos >= len(p.lines) || p.lines[p.pos].indent <= indent {
+				n.items = append(n.items, &node{line: ln.num, kind: scalarNode})
+				continue
+			}
+			item, err := p.parseBlock(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			n.items = append(n.items, item)
+		case isMappingEntry(rest):
+			// "- key: value" starts a mapping indented past the dash.
+			itemIndent := ln.indent + (len(ln.text) - len(rest))
+			p.lines[p.pos] = yamlLine{num: ln.num, indent: itemIndent, text: rest}
+			item, err := p.parseMapping(itemIndent)
+			if err != nil {
+				return nil, err
+			}
+			n.items = append(n.items, item)
+		default:
+			p.pos++
+			item, err := p.parseScalar(ln.num, rest)
+			if err != nil {
+				return nil, err
+			}
+			n.items = append(n.items, item)
+		}
+	}
+	return n, nil
+}
+
+func (p *yamlParser) parseScalar(line int, text string) (*node, error) {
+	if strings.HasPrefix(text, "[") {
+		if !strings.HasSuffix(text, "]") {
+			return nil, p.errorf(line, "unterminated flow sequence")
+		}
+		n := &node{line: line, kind: sequenceNode}
+		inner := strings.TrimSpace(text[1 : len(text)-1])
+		if inner == "" {
+			return n, nil
+		}
+		for _, part := range splitFlow(inner) {
+			v, err := unquote(strings.TrimSpace(part))
+			if err != nil {
+				return nil, p.errorf(line, "%v", err)
+			}
+			n.items = append(n.items, &node{line: line, kind: scalarNode, value: v})
+		}
+		return n, nil
+	}
+	if text == "{}" {
+		return &node{line: line, kind: mappingNode, fields: map[string]*node{}}, nil
+	}
+	v, err := unquote(text)
+	if err != nil {
+		return nil, p.errorf(line, "%v", err)
+	}
+	return &node{line: line, kind: scalarNode, value: v}, nil
+}
+
+func isSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+func isMappingEntry(text string) bool {
+	if strings.HasPrefix(text, "\"") || strings.HasPrefix(text, "'") || strings.HasPrefix(text, "[") {
+		return false
+	}
+	_, _, ok := splitKey(text)
+	return ok
+}
+
+// splitKey splits "key: value" at the first colon followed by a space or end of line.
+func splitKey(text string) (string, string, bool) {
+	for i := 0; i < len(text); i++ {
+		if text[i] != ':' {
+			continue
+		}
+		if i+1 < len(text) && text[i+1] != ' ' {
+			continue
+		}
+		key := strings.TrimSpace(text[:i])
+		if key == "" {
+			return "", "", false
+		}
+		if k, err := unquote(key); err == nil {
+			key = k
+		}
+		return key, strings.TrimSpace(text[i+1:]), true
+	}
+	return "", "", false
+}
+
+func splitFlow(s string) []string {
+	var parts []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ',':
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func unquote(s string) (string, error) {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		v, err := strconv.Unquote(s)
+		if err != nil {
+			return "", fmt.Errorf("invalid quoted string %s", s)
+		}
+		return v, nil
+	}
+	if len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'' {
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	}
+	if strings.HasPrefix(s, "\"") || strings.HasPrefix(s, "'") {
+		return "", fmt.Errorf("unterminated quoted string %s", s)
+	}
+	return s, nil
+}
+
+func (n *node) kindName() string {
+	switch n.kind {
+	case mappingNode:
+		return "mapping"
+	case sequenceNode:
+		return "list"
+	default:
+		return "scalar"
+	}
+}
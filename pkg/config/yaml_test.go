@@ -0,0 +1,87 @@
+package config
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseYAMLSubset(t *testing.T) {
+	src := `# comment
+ignore:
+  tests: separate   # trailing comment
+  defaults:
+    - vendor/
+    - "dist/"
+  test_patterns: ['*_test.go', "spec/"]
+rules:
+  - paths: [pkg/]
+    bonus: 2
+  -
+    paths: [cmd/]
+list:
+- a
+- b
+`
+	root, err := parseYAML("cfg.yml", src)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	ign := root.fields["ignore"]
+	if ign == nil || ign.kind != mappingNode {
+		t.Fatalf("expected ignore mapping")
+	}
+	if got := ign.fields["tests"].value; got != "separate" {
+		t.Fatalf("unexpected tests value %q", got)
+	}
+	defaults := ign.fields["defaults"]
+	if len(defaults.items) != 2 || defaults.items[1].value != "dist/" {
+		t.Fatalf("unexpected defaults: %+v", defaults.items)
+	}
+	patterns := ign.fields["test_patterns"]
+	if len(patterns.items) != 2 || patterns.items[0].value != "*_test.go" || patterns.items[1].value != "spec/" {
+		t.Fatalf("unexpected flow list: %+v", patterns.items)
+	}
+	rules := root.fields["rules"]
+	if len(rules.items) != 2 || rules.items[0].fields["bonus"].value != "2" || rules.items[1].fields["paths"].items[0].value != "cmd/" {
+		t.Fatalf("unexpected rules: %+v", rules.items)
+	}
+	if list := root.fields["list"]; len(list.items) != 2 {
+		t.Fatalf("expected same-indent sequence, got %+v", list)
+	}
+	if ign.fields["tests"].line != 3 {
+		t.Fatalf("expected line 3, got %d", ign.fields["tests"].line)
+	}
+}
+
+func TestParseYAMLErrors(t *testing.T) {
+	cases := []struct {
+		src  string
+		line int
+	}{
+		{"a: 1\n  b: 2\n", 2},
+		{"a: 1\na: 2\n", 2},
+		{"a:\n\t- x\n", 2},
+		{"a: [x, y\n", 1},
+		{"a: \"open\n", 1},
+		{"just text\n", 1},
+	}
+	for _, c := range cases {
+		_, err := parseYAML("cfg.yml", c.src)
+		var cfgErr *Error
+		if !errors.As(err, &cfgErr) {
+			t.Fatalf("src %q: expected *Error, got %v", c.src, err)
+		}
+		if cfgErr.Line != c.line {
+			t.Fatalf("src %q: expected line %d, got %d (%v)", c.src, c.line, cfgErr.Line, err)
+		}
+	}
+}
+
+func TestStripComment(t *testing.T) {
+	if got := stripComment(`a: "x # y" # z`); got != `a: "x # y"` {
+		t.Fatalf("unexpected strip: %q", got)
+	}
+	if got := stripComment("a: b#c"); got != "a: b#c" {
+		t.Fatalf("unexpected strip: %q", got)
+	}
+}
@@ -0,0 +1,164 @@
+package gitrepo
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// File modes recorded in the index.
+const (
+	ModeRegular    = 0o100644
+	ModeExecutable = 0o100755
+	ModeSymlink    = 0o120000
+	ModeGitlink    = 0o160000
+	ModeDir        = 0o040000
+)
+
+// IndexEntry is one path recorded in the git index.
+type IndexEntry struct {
+	Path  string
+	Mode  uint32
+	Size  uint32
+	MTime time.Time
+	Stage int
+}
+
+// ErrSplitIndex is returned for split indexes, whose entries live partly in
+// a shared index file that is not read.
+var ErrSplitIndex = errors.New("git split index is not supported")
+
+// Index reads the repository's index. A repository without an index file
+// (nothing staged yet) has no entries.
+func (r Repo) Index() ([]IndexEntry, error) {
+	data, err := os.ReadFile(filepath.Join(r.GitDir, "index"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return ParseIndex(data, r.HashSize())
+}
+
+// ParseIndex decodes an index file ("DIRC" versions 2 to 4) whose object ids
+// are hashSize bytes long. Entries are returned in index order.
+func ParseIndex(data []byte, hashSize int) ([]IndexEntry, error) {
+	if len(data) < 12+hashSize {
+		return nil, fmt.Errorf("git index: file too short")
+	}
+	if !bytes.Equal(data[:4], []byte("DIRC")) {
+		return nil, fmt.Errorf("git index: bad signature")
+	}
+	var sum hash.Hash = sha1.New()
+	if hashSize == 32 {
+		sum = sha256.New()
+	}
+	body, trailer := data[:len(data)-hashSize], data[len(data)-hashSize:]
+	// With index.skipHash, the default under feature.manyFiles, git writes
+	// a zero trailer instead of the checksum.
+	if !bytes.Equal(trailer, make([]byte, hashSize)) {
+		sum.Write(body)
+		if !bytes.Equal(sum.Sum(nil), trailer) {
+			return nil, fmt.Errorf("git index: checksum mismatch")
+		}
+	}
+	version := binary.BigEndian.Uint32(data[4:8])
+	if version < 2 || version > 4 {
+		return nil, fmt.Errorf("git index: unsupported version %d", version)
+	}
+	count := int(binary.BigEndian.Uint32(data[8:12]))
+
+	entries := make([]IndexEntry, 0, count)
+	pos := 12
+	prev := ""
+	fixed := 40 + hashSize + 2
+	for i := 0; i < count; i++ {
+		start := pos
+		if pos+fixed > len(body) {
+			return nil, fmt.Errorf("git index: truncated entry %d", i)
+		}
+		mtime := time.Unix(int64(binary.BigEndian.Uint32(body[pos+8:])), int64(binary.BigEndian.Uint32(body[pos+12:])))
+		mode := binary.BigEndian.Uint32(body[pos+24:])
+		size := binary.BigEndian.Uint32(body[pos+36:])
+		flags := binary.BigEndian.Uint16(body[pos+40+hashSize:])
+		pos += fixed
+		if flags&0x4000 != 0 {
+			if version < 3 {
+				return nil, fmt.Errorf("git index: extended flags in version %d", version)
+			}
+			pos += 2
+			if pos > len(body) {
+				return nil, fmt.Errorf("git index: truncated entry %d", i)
+			}
+		}
+		var name string
+		if version == 4 {
+			strip, n := decodeVarint(body[pos:])
+			if n == 0 || int(strip) > len(prev) {
+				return nil, fmt.Errorf("git index: bad path prefix in entry %d", i)
+			}
+			pos += n
+			end := bytes.IndexByte(body[pos:], 0)
+			if end < 0 {
+				return nil, fmt.Errorf("git index: unterminated path in entry %d", i)
+			}
+			name = prev[:len(prev)-int(strip)] + string(body[pos:pos+end])
+			pos += end + 1
+		} else {
+			end := bytes.IndexByte(body[pos:], 0)
+			if end < 0 {
+				return nil, fmt.Errorf("git index: unterminated path in entry %d", i)
+			}
+			name = string(body[pos : pos+end])
+			// Entries are NUL-padded to a multiple of eight bytes.
+			pos = start + ((pos + end - start + 8) &^ 7)
+		}
+		prev = name
+		entries = append(entries, IndexEntry{
+			Path:  name,
+			Mode:  mode,
+			Size:  size,
+			MTime: mtime,
+			Stage: int(flags>>12) & 3,
+		})
+	}
+
+	for pos+8 <= len(body) {
+		sig := string(body[pos : pos+4])
+		size := int(binary.BigEndian.Uint32(body[pos+4:]))
+		if sig == "link" {
+			return nil, ErrSplitIndex
+		}
+		pos += 8 + size
+	}
+	return entries, nil
+}
+
+// decodeVarint decodes git's offset varint, returning the value and the
+// number of bytes consumed (0 on malformed input).
+func decodeVarint(buf []byte) (uint64, int) {
+	if len(buf) == 0 {
+		return 0, 0
+	}
+	c := buf[0]
+	val := uint64(c & 127)
+	n := 1
+	for c&128 != 0 {
+		if n >= len(buf) || n > 9 {
+			return 0, 0
+		}
+		val++
+		c = buf[n]
+		n++
+		val = (val << 7) + uint64(c&127)
+	}
+	return val, n
+}
@@ -0,0 +1,210 @@
+package gitrepo
+
+import (
+	"encoding/binary"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func requireGit(t *testing.T) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skipf("git not found: %v", err)
+	}
+}
+
+func git(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Env = append(os.Environ(), "GIT_CONFIG_GLOBAL=/dev/null", "GIT_CONFIG_NOSYSTEM=1")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v: %s", args, err, out)
+	}
+}
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+}
+
+func TestIndexVersions(t *testing.T) {
+	requireGit(t)
+	for _, version := range []string{"2", "3", "4"} {
+		t.Run("v"+version, func(t *testing.T) {
+			root := t.TempDir()
+			git(t, root, "init", "-q")
+			writeFiles(t, root, map[string]string{
+				"README.md":                   "readme",
+				"pkg/scan/scan.go":            "package scan",
+				"pkg/scan/scan_helpers.go":    "package scan",
+				"pkg/a-very-long-directory/x": "x",
+			})
+			git(t, root, "add", ".")
+			git(t, root, "update-index", "--index-version", version)
+			if version == "3" {
+				git(t, root, "update-index", "--skip-worktree", "README.md")
+			}
+
+			repo, ok := Find(filepath.Join(root, "pkg"))
+			if !ok || repo.WorkTree != root {
+				t.Fatalf("expected repo at %s, got %+v ok=%v", root, repo, ok)
+			}
+			entries, err := repo.Index()
+			if err != nil {
+				t.Fatalf("index: %v", err)
+			}
+			want := []string{"README.md", "pkg/a-very-long-directory/x", "pkg/scan/scan.go", "pkg/scan/scan_helpers.go"}
+			if len(entries) != len(want) {
+				t.Fatalf("expected %d entries, got %+v", len(want), entries)
+			}
+			for i, p := range want {
+				if entries[i].Path != p {
+					t.Fatalf("entry %d expected %s got %s", i, p, entries[i].Path)
+				}
+				if entries[i].Mode != ModeRegular {
+					t.Fatalf("entry %s unexpected mode %o", p, entries[i].Mode)
+				}
+			}
+			if entries[0].Size != uint32(len("readme")) {
+				t.Fatalf("unexpected size %d", entries[0].Size)
+			}
+		})
+	}
+}
+
+func TestIndexMissingIsEmpty(t *testing.T) {
+	requireGit(t)
+	root := t.TempDir()
+	git(t, root, "init", "-q")
+	entries, err := Repo{WorkTree: root, GitDir: Dir(root)}.Index()
+	if err != nil || entries != nil {
+		t.Fatalf("expected no entries, got %v err=%v", entries, err)
+	}
+}
+
+func TestParseIndexRejectsCorruption(t *testing.T) {
+	if _, err := ParseIndex([]byte("short"), 20); err == nil {
+		t.Fatalf("expected error for short index")
+	}
+	data := make([]byte, 40)
+	copy(data, "DIRC")
+	data[len(data)-1] = 1
+	if _, err := ParseIndex(data, 20); err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Fatalf("expected checksum error, got %v", err)
+	}
+
+	// A version 3 entry with extended flags that ends at its fixed fields,
+	// followed by a zero (skipHash) trailer.
+	data = make([]byte, 12+40+20+2+20)
+	copy(data, "DIRC")
+	binary.BigEndian.PutUint32(data[4:], 3)
+	binary.BigEndian.PutUint32(data[8:], 1)
+	binary.BigEndian.PutUint16(data[12+40+20:], 0x4000)
+	if _, err := ParseIndex(data, 20); err == nil || !strings.Contains(err.Error(), "truncated entry") {
+		t.Fatalf("expected truncated entry error, got %v", err)
+	}
+}
+
+func TestIndexSkipHash(t *testing.T) {
+	requireGit(t)
+	root := t.TempDir()
+	git(t, root, "init", "-q")
+	writeFiles(t, root, map[string]string{"main.go": "package main", "pkg/a.go": "package pkg"})
+	git(t, root, "-c", "index.skipHash=true", "add", ".")
+	path := filepath.Join(root, ".git", "index")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read index: %v", err)
+	}
+	// git before 2.40 ignores index.skipHash; write the trailer it would.
+	copy(data[len(data)-20:], make([]byte, 20))
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("write index: %v", err)
+	}
+	entries, err := Repo{WorkTree: root, GitDir: Dir(root)}.Index()
+	if err != nil {
+		t.Fatalf("index: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Path != "main.go" || entries[1].Path != "pkg/a.go" {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+}
+
+func TestDirFollowsGitdirFile(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, ".git"), []byte("gitdir: ../real.git\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if got := Dir(root); got != filepath.Join(root, "..", "real.git") {
+		t.Fatalf("unexpected git dir: %s", got)
+	}
+	if got := Dir(t.TempDir()); got != "" {
+		t.Fatalf("expected no git dir, got %s", got)
+	}
+}
+
+func TestDecodeVarint(t *testing.T) {
+	cases := []struct {
+		in   []byte
+		want uint64
+		n    int
+	}{
+		{[]byte{0x05}, 5, 1},
+		{[]byte{0x80, 0x00}, 128, 2},
+		{[]byte{0x81, 0x7f}, 383, 2},
+		{[]byte{0x80}, 0, 0},
+	}
+	for _, c := range cases {
+		got, n := decodeVarint(c.in)
+		if got != c.want || n != c.n {
+			t.Fatalf("decodeVarint(%x)=%d,%d want %d,%d", c.in, got, n, c.want, c.n)
+		}
+	}
+}
+
+func TestConfigValue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	content := "[core]\n\tbare = false\n[Extensions]\n\tobjectFormat = \"sha256\"\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if v, ok := ConfigValue(path, "extensions", "objectformat"); !ok || v != "sha256" {
+		t.Fatalf("unexpected value %q ok=%v", v, ok)
+	}
+	if _, ok := ConfigValue(path, "core", "excludesfile"); ok {
+		t.Fatalf("expected missing key")
+	}
+}
+
+func TestIndexSHA256Repo(t *testing.T) {
+	requireGit(t)
+	root := t.TempDir()
+	git(t, root, "init", "-q", "--object-format=sha256")
+	writeFiles(t, root, map[string]string{"main.go": "package main"})
+	git(t, root, "add", ".")
+	repo, ok := Find(root)
+	if !ok {
+		t.Fatalf("expected repo")
+	}
+	if repo.HashSize() != 32 {
+		t.Fatalf("expected sha256 hash size, got %d", repo.HashSize())
+	}
+	entries, err := repo.Index()
+	if err != nil {
+		t.Fatalf("index: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Path != "main.go" {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+}
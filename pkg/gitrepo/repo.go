@@ -0,0 +1,122 @@
+package gitrepo
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Repo locates a git work tree and its git directory.
+type Repo struct {
+	WorkTree string
+	GitDir   string
+}
+
+// Dir returns the git directory for a work tree root, following "gitdir:"
+// indirection files used by worktrees and submodules. It returns "" when
+// root has no .git entry.
+func Dir(root string) string {
+	dotGit := filepath.Join(root, ".git")
+	info, err := os.Stat(dotGit)
+	if err != nil {
+		return ""
+	}
+	if info.IsDir() {
+		return dotGit
+	}
+	data, err := os.ReadFile(dotGit)
+	if err != nil {
+		return ""
+	}
+	line := strings.TrimSpace(string(data))
+	if !strings.HasPrefix(line, "gitdir:") {
+		return ""
+	}
+	dir := strings.TrimSpace(strings.TrimPrefix(line, "gitdir:"))
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(root, dir)
+	}
+	return dir
+}
+
+// Find walks upward from start to the nearest enclosing git work tree.
+func Find(start string) (Repo, bool) {
+	dir, err := filepath.Abs(start)
+	if err != nil {
+		return Repo{}, false
+	}
+	for {
+		if gd := Dir(dir); gd != "" {
+			return Repo{WorkTree: dir, GitDir: gd}, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return Repo{}, false
+		}
+		dir = parent
+	}
+}
+
+// CommonDir returns the directory holding shared state such as config. It
+// differs from GitDir for linked worktrees.
+func (r Repo) CommonDir() string {
+	data, err := os.ReadFile(filepath.Join(r.GitDir, "commondir"))
+	if err != nil {
+		return r.GitDir
+	}
+	dir := strings.TrimSpace(string(data))
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(r.GitDir, dir)
+	}
+	return filepath.Clean(dir)
+}
+
+// HashSize is the object id length in bytes: 32 for sha256 repositories,
+// 20 otherwise.
+func (r Repo) HashSize() int {
+	if v, ok := ConfigValue(filepath.Join(r.CommonDir(), "config"), "extensions", "objectformat"); ok && strings.EqualFold(v, "sha256") {
+		return 32
+	}
+	return 20
+}
+
+// ConfigValue reads the last value of section.key from a git config file.
+// Only plain "[section]" headers and "key = value" lines are understood;
+// section and key must be given in lower case.
+func ConfigValue(path, section, key string) (string, bool) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", false
+	}
+	defer file.Close()
+
+	current := ""
+	value := ""
+	found := false
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			current = strings.ToLower(strings.TrimSpace(line[1 : len(line)-1]))
+			continue
+		}
+		if current != section {
+			continue
+		}
+		name, val, ok := strings.Cut(line, "=")
+		if !ok || strings.ToLower(strings.TrimSpace(name)) != key {
+			continue
+		}
+		val = strings.TrimSpace(val)
+		if len(val) >= 2 && strings.HasPrefix(val, `"`) && strings.HasSuffix(val, `"`) {
+			val = val[1 : len(val)-1]
+		}
+		value = val
+		found = true
+	}
+	return value, found
+}
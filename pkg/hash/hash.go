@@ -11,9 +11,17 @@ func FileHash(data []byte) string {
 	return hex.EncodeToString(sum[:])
 }
 
-func ChunkHash(fileHash string, startLine, endLine int, text string) string {
+// ChunkHash hashes a chunk's text.
+func ChunkHash(text string) string {
+	return FileHash([]byte(text))
+}
+
+// ChunkID identifies a chunk by its file, symbol path and text. It does not
+// depend on line numbers or the rest of the file, so a chunk keeps its ID
+// when other parts of the file are edited.
+func ChunkID(path, symbol, text string) string {
 	h := sha256.New()
-	fmt.Fprintf(h, "%s:%d:%d:\n", fileHash, startLine, endLine)
+	fmt.Fprintf(h, "%s\x00%s\x00", path, symbol)
 	h.Write([]byte(text))
 	return hex.EncodeToString(h.Sum(nil))
 }
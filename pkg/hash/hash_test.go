@@ -11,12 +11,22 @@ func TestFileHashDeterministic(t *testing.T) {
 	}
 }
 
-func TestChunkHashChangesWithRange(t *testing.T) {
-	fileHash := FileHash([]byte("file"))
-	text := "chunk content"
-	h1 := ChunkHash(fileHash, 1, 3, text)
-	h2 := ChunkHash(fileHash, 1, 4, text)
-	if h1 == h2 {
-		t.Fatalf("expected different hashes for different ranges")
+func TestChunkIDIsContentAddressed(t *testing.T) {
+	id := ChunkID("a.go", "Foo", "func Foo() {}")
+	if id != ChunkID("a.go", "Foo", "func Foo() {}") {
+		t.Fatalf("expected deterministic chunk id")
+	}
+	for _, other := range []string{
+		ChunkID("b.go", "Foo", "func Foo() {}"),
+		ChunkID("a.go", "Bar", "func Foo() {}"),
+		ChunkID("a.go", "Foo", "func Foo() { }"),
+		ChunkID("a.goFoo", "", "func Foo() {}"),
+	} {
+		if other == id {
+			t.Fatalf("expected path, symbol and text to change the id")
+		}
+	}
+	if ChunkHash("x") != FileHash([]byte("x")) {
+		t.Fatalf("expected chunk hash to hash the text")
 	}
 }
@@ -0,0 +1,143 @@
+package ignore
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// conformanceCases were checked against `git check-ignore` (git 2.39) using
+// the same files; paths ending in "/" are directories.
+var conformanceCases = []struct {
+	name    string
+	files   map[string]string
+	path    string
+	ignored bool
+}{
+	{"basename any depth", map[string]string{".gitignore": "*.log\n"}, "a/b/debug.log", true},
+	{"basename root", map[string]string{".gitignore": "*.log\n"}, "debug.log", true},
+	{"no match", map[string]string{".gitignore": "*.log\n"}, "debug.txt", false},
+	{"star does not cross slash", map[string]string{".gitignore": "a/*.txt\n"}, "a/b/c.txt", false},
+	{"star within dir", map[string]string{".gitignore": "a/*.txt\n"}, "a/c.txt", true},
+	{"leading slash anchors", map[string]string{".gitignore": "/build\n"}, "src/build", false},
+	{"leading slash root", map[string]string{".gitignore": "/build\n"}, "build", true},
+	{"middle slash anchors", map[string]string{".gitignore": "doc/frotz\n"}, "a/doc/frotz", false},
+	{"middle slash root", map[string]string{".gitignore": "doc/frotz\n"}, "doc/frotz", true},
+	{"unanchored name", map[string]string{".gitignore": "frotz\n"}, "a/frotz", true},
+	{"dir only matches dir", map[string]string{".gitignore": "out/\n"}, "src/out/", true},
+	{"dir only skips file", map[string]string{".gitignore": "out/\n"}, "src/out", false},
+	{"dir only contents", map[string]string{".gitignore": "out/\n"}, "src/out/x.go", true},
+	{"leading double star", map[string]string{".gitignore": "**/foo\n"}, "a/b/foo", true},
+	{"leading double star root", map[string]string{".gitignore": "**/foo\n"}, "foo", true},
+	{"leading double star nested path", map[string]string{".gitignore": "**/foo/bar\n"}, "x/foo/bar", true},
+	{"trailing double star contents", map[string]string{".gitignore": "abc/**\n"}, "abc/x/y", true},
+	{"trailing double star not dir itself", map[string]string{".gitignore": "abc/**\n"}, "abc", false},
+	{"middle double star zero dirs", map[string]string{".gitignore": "a/**/b\n"}, "a/b", true},
+	{"middle double star many dirs", map[string]string{".gitignore": "a/**/b\n"}, "a/x/y/b", true},
+	{"double star in name is star", map[string]string{".gitignore": "foo**bar\n"}, "fooxbar", true},
+	{"negation re-includes", map[string]string{".gitignore": "*.log\n!keep.log\n"}, "keep.log", false},
+	{"negation order matters", map[string]string{".gitignore": "!keep.log\n*.log\n"}, "keep.log", true},
+	{"negation cannot escape ignored dir", map[string]string{".gitignore": "logs/\n!logs/keep.log\n"}, "logs/keep.log", true},
+	{"negation inside star dir", map[string]string{".gitignore": "logs/*\n!logs/keep.log\n"}, "logs/keep.log", false},
+	{"escaped bang", map[string]string{".gitignore": "\\!important\n"}, "!important", true},
+	{"escaped hash", map[string]string{".gitignore": "\\#notes\n"}, "#notes", true},
+	{"comment line", map[string]string{".gitignore": "#notes\n"}, "#notes", false},
+	{"trailing spaces trimmed", map[string]string{".gitignore": "trail   \n"}, "trail", true},
+	{"escaped trailing space", map[string]string{".gitignore": "space\\ \n"}, "space ", true},
+	{"question mark", map[string]string{".gitignore": "file?.txt\n"}, "file1.txt", true},
+	{"question mark no slash", map[string]string{".gitignore": "a?b\n"}, "a/b", false},
+	{"bracket range", map[string]string{".gitignore": "file[0-9].txt\n"}, "file7.txt", true},
+	{"bracket negated", map[string]string{".gitignore": "file[!0-9].txt\n"}, "file7.txt", false},
+	{"bracket class", map[string]string{".gitignore": "v[[:digit:]]\n"}, "v3", true},
+	{"nested gitignore relative", map[string]string{"sub/.gitignore": "/gen\n"}, "sub/gen", true},
+	{"nested gitignore scoped", map[string]string{"sub/.gitignore": "gen\n"}, "other/gen", false},
+	{"nested overrides parent", map[string]string{".gitignore": "*.tmp\n", "sub/.gitignore": "!keep.tmp\n"}, "sub/keep.tmp", false},
+	{"nested does not affect sibling", map[string]string{".gitignore": "*.tmp\n", "sub/.gitignore": "!keep.tmp\n"}, "keep.tmp", true},
+	{"info exclude", map[string]string{".git/info/exclude": "secret.txt\n"}, "secret.txt", true},
+	{"gitignore overrides info exclude", map[string]string{".git/info/exclude": "*.bak\n", ".gitignore": "!a.bak\n"}, "a.bak", false},
+	{"global excludes", map[string]string{"xdg/git/ignore": "*.swp\n"}, "main.go.swp", true},
+	{"gitconfig excludesfile", map[string]string{"home/.gitconfig": "[core]\n\texcludesFile = ~/ignores\n", "home/ignores": "*.orig\n"}, "a.orig", true},
+}
+
+func TestConformance(t *testing.T) {
+	for _, c := range conformanceCases {
+		t.Run(c.name, func(t *testing.T) {
+			root := t.TempDir()
+			home := filepath.Join(root, "home")
+			t.Setenv("HOME", home)
+			t.Setenv("XDG_CONFIG_HOME", filepath.Join(root, "xdg"))
+			repo := filepath.Join(root, "repo")
+			if err := os.MkdirAll(filepath.Join(repo, ".git"), 0o755); err != nil {
+				t.Fatalf("mkdir: %v", err)
+			}
+			for name, content := range c.files {
+				dest := filepath.Join(repo, filepath.FromSlash(name))
+				if strings.HasPrefix(name, "home/") || strings.HasPrefix(name, "xdg/") {
+					dest = filepath.Join(root, filepath.FromSlash(name))
+				}
+				if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+					t.Fatalf("mkdir: %v", err)
+				}
+				if err := os.WriteFile(dest, []byte(content), 0o644); err != nil {
+					t.Fatalf("write %s: %v", name, err)
+				}
+			}
+			m, err := Load(repo)
+			if err != nil {
+				t.Fatalf("load: %v", err)
+			}
+			if got := m.Ignored(c.path); got != c.ignored {
+				t.Fatalf("path %q ignored=%v want %v", c.path, got, c.ignored)
+			}
+		})
+	}
+}
+
+func TestWildmatch(t *testing.T) {
+	cases := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"*", "", true},
+		{"*.go", "main.go", true},
+		{"*.go", "main.gox", false},
+		{"a*b*c", "axxbyyc", true},
+		{"[a-c]x", "bx", true},
+		{"[^a-c]x", "bx", false},
+		{"[]]", "]", true},
+		{"[", "[", true},
+		{`\*`, "*", true},
+		{`\*`, "a", false},
+		{"[[:upper:]]*", "Readme", true},
+	}
+	for _, c := range cases {
+		if got := wildmatch(c.pattern, c.name); got != c.want {
+			t.Fatalf("wildmatch(%q, %q)=%v want %v", c.pattern, c.name, got, c.want)
+		}
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	cases := []struct {
+		pattern string
+		rel     string
+		want    bool
+	}{
+		{"pkg/scan/", "pkg/scan/scan.go", true},
+		{"pkg/scan/", "pkg/scan/sub/walk.go", true},
+		{"pkg/scan/", "pkg/scanner/scan.go", false},
+		{"*_test.go", "pkg/scan/scan_test.go", true},
+		{"/docs/*.md", "docs/intro.md", true},
+		{"docs/*.md", "docs/sub/intro.md", false},
+		{"**/api/*.go", "svc/v1/api/h.go", true},
+		{"cmd/**/*.go", "cmd/scry/main.go", true},
+		{"cmd/**/*.go", "pkg/main.go", false},
+	}
+	for _, c := range cases {
+		if got := MatchGlob(c.pattern, c.rel); got != c.want {
+			t.Fatalf("MatchGlob(%q, %q)=%v want %v", c.pattern, c.rel, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package ignore
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"scry/pkg/gitrepo"
+)
+
+// globalExcludesFile resolves core.excludesFile from the repo and user git
+// configs, falling back to $XDG_CONFIG_HOME/git/ignore as git does.
+func globalExcludesFile(root string) string {
+	home, _ := os.UserHomeDir()
+	xdg := os.Getenv("XDG_CONFIG_HOME")
+	if xdg == "" && home != "" {
+		xdg = filepath.Join(home, ".config")
+	}
+	var configs []string
+	if xdg != "" {
+		configs = append(configs, filepath.Join(xdg, "git", "config"))
+	}
+	if home != "" {
+		configs = append(configs, filepath.Join(home, ".gitconfig"))
+	}
+	if dir := gitrepo.Dir(root); dir != "" {
+		configs = append(configs, filepath.Join(dir, "config"))
+	}
+	value := ""
+	for _, cfg := range configs {
+		if v, ok := gitrepo.ConfigValue(cfg, "core", "excludesfile"); ok {
+			value = v
+		}
+	}
+	if value == "" {
+		if xdg == "" {
+			return ""
+		}
+		return filepath.Join(xdg, "git", "ignore")
+	}
+	if strings.HasPrefix(value, "~/") && home != "" {
+		value = filepath.Join(home, value[2:])
+	}
+	return value
+}
@@ -3,84 +3,317 @@ package ignore
 import (
 	"bufio"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
+	"sync"
+
+	"scry/pkg/gitrepo"
 )
 
+// DefaultSource labels built-in patterns that do not come from a file.
+const DefaultSource = "default"
+
+// ignoreFiles are read from every directory, in increasing precedence.
+var ignoreFiles = []string{".gitignore", ".scryignore"}
+
+// IsIgnoreFile reports whether name is the base name of a per-directory
+// ignore file.
+func IsIgnoreFile(name string) bool {
+	for _, f := range ignoreFiles {
+		if name == f {
+			return true
+		}
+	}
+	return false
+}
+
+// Matcher applies gitignore semantics to paths relative to root. Patterns are
+// consulted in increasing precedence: defaults, the global excludes file,
+// .git/info/exclude, then per-directory ignore files from the root downwards.
+// The last matching pattern wins, and nothing below an ignored directory can
+// be re-included.
 type Matcher struct {
-	root  string
-	globs []string
+	root   string
+	global []Pattern
+	tests  []Pattern
+
+	mu      sync.Mutex
+	dirs    map[string][]Pattern
+	ignored map[dirKey]bool
+}
+
+// dirKey caches whether a directory is ignored, for Match or MatchTracked.
+type dirKey struct {
+	dir     string
+	tracked bool
+}
+
+// DefaultTestPatterns identify test files when Options.TestPatterns is nil.
+var DefaultTestPatterns = []string{"*_test.go", "testdata/"}
+
+// Options controls the built-in patterns a Matcher starts from. The zero
+// value ignores files matching DefaultTestPatterns.
+type Options struct {
+	// Defaults are applied with the lowest precedence, before any ignore file.
+	Defaults []string
+	// TestPatterns identify test files; see Matcher.IsTest.
+	TestPatterns []string
+	// IncludeTests keeps test files instead of ignoring them.
+	IncludeTests bool
 }
 
 func Load(root string) (*Matcher, error) {
-	globs := defaultPatterns()
-	gitIgnore, err := loadPatterns(filepath.Join(root, ".gitignore"))
-	if err != nil {
-		return nil, err
+	return LoadOptions(root, Options{})
+}
+
+func LoadOptions(root string, opts Options) (*Matcher, error) {
+	testPatterns := opts.TestPatterns
+	if testPatterns == nil {
+		testPatterns = DefaultTestPatterns
+	}
+	defaults := append([]string{}, opts.Defaults...)
+	if !opts.IncludeTests {
+		defaults = append(defaults, testPatterns...)
 	}
-	scryIgnore, err := loadPatterns(filepath.Join(root, ".scryignore"))
+	m := newMatcher(root, defaults)
+	m.tests = parsePatterns(testPatterns, "", DefaultSource)
+	sources := []string{globalExcludesFile(root)}
+	if dir := gitrepo.Dir(root); dir != "" {
+		sources = append(sources, filepath.Join(dir, "info", "exclude"))
+	}
+	for _, src := range sources {
+		if src == "" {
+			continue
+		}
+		patterns, err := loadPatterns(src, sourceName(root, src), "")
+		if err != nil {
+			return nil, err
+		}
+		m.global = append(m.global, patterns...)
+	}
+	rootPatterns, err := readDirPatterns(root, "")
 	if err != nil {
 		return nil, err
 	}
-	globs = append(globs, gitIgnore...)
-	globs = append(globs, scryIgnore...)
-	return &Matcher{root: root, globs: globs}, nil
+	m.dirs[""] = rootPatterns
+	return m, nil
+}
+
+// newMatcher builds a matcher whose lowest-precedence patterns are globs,
+// without reading anything from disk until directories are queried.
+func newMatcher(root string, globs []string) *Matcher {
+	return &Matcher{
+		root:    root,
+		global:  parsePatterns(globs, "", DefaultSource),
+		dirs:    map[string][]Pattern{},
+		ignored: map[dirKey]bool{},
+	}
 }
 
+// Ignored reports whether relPath is ignored. A trailing slash marks a directory.
 func (m *Matcher) Ignored(relPath string) bool {
 	rel := filepath.ToSlash(relPath)
-	for _, g := range m.globs {
-		if g == "" {
-			continue
-		}
-		if strings.HasSuffix(g, "/") {
-			if strings.HasPrefix(rel, g) {
-				return true
-			}
-			continue
+	return m.Match(rel, strings.HasSuffix(rel, "/"))
+}
+
+// Match reports whether relPath, which is a directory if isDir is set, is ignored.
+func (m *Matcher) Match(relPath string, isDir bool) bool {
+	return m.match(relPath, isDir, false)
+}
+
+// MatchTracked is Match for a path git tracks. As in git, .gitignore files,
+// .git/info/exclude and the global excludes file do not apply to it; the
+// defaults and .scryignore files do.
+func (m *Matcher) MatchTracked(relPath string, isDir bool) bool {
+	return m.match(relPath, isDir, true)
+}
+
+func (m *Matcher) match(relPath string, isDir, tracked bool) bool {
+	rel := cleanRel(relPath)
+	if rel == "" {
+		return false
+	}
+	parts := strings.Split(rel, "/")
+	for i := 1; i < len(parts); i++ {
+		if m.dirIgnored(strings.Join(parts[:i], "/"), tracked) {
+			return true
 		}
-		if ok, _ := filepath.Match(g, rel); ok {
+	}
+	return m.matchSelf(rel, isDir, tracked)
+}
+
+func (m *Matcher) dirIgnored(dir string, tracked bool) bool {
+	key := dirKey{dir, tracked}
+	m.mu.Lock()
+	v, ok := m.ignored[key]
+	m.mu.Unlock()
+	if ok {
+		return v
+	}
+	v = m.matchSelf(dir, true, tracked)
+	m.mu.Lock()
+	m.ignored[key] = v
+	m.mu.Unlock()
+	return v
+}
+
+// IsTest reports whether relPath is a test file or lies under a test directory.
+func (m *Matcher) IsTest(relPath string) bool {
+	rel := cleanRel(relPath)
+	if rel == "" || len(m.tests) == 0 {
+		return false
+	}
+	parts := strings.Split(rel, "/")
+	for i := 1; i < len(parts); i++ {
+		if _, ok := matchList(m.tests, strings.Join(parts[:i], "/"), true); ok {
 			return true
 		}
-		if strings.Contains(g, "/") {
-			if ok, _ := filepath.Match(g, rel); ok {
-				return true
+	}
+	_, ok := matchList(m.tests, rel, strings.HasSuffix(filepath.ToSlash(relPath), "/"))
+	return ok
+}
+
+// Explain reports the pattern that decides whether relPath is ignored. When
+// a parent directory is excluded, the pattern excluding it is returned. The
+// pattern is nil if nothing matched; a matching negated pattern yields
+// Ignored false with that pattern.
+func (m *Matcher) Explain(relPath string, isDir bool) (*Pattern, bool) {
+	rel := cleanRel(relPath)
+	if rel == "" {
+		return nil, false
+	}
+	parts := strings.Split(rel, "/")
+	for i := 1; i < len(parts); i++ {
+		if p, ok := matchList(m.applicable(strings.Join(parts[:i], "/")), strings.Join(parts[:i], "/"), true); ok {
+			return p, true
+		}
+	}
+	return matchList(m.applicable(rel), rel, isDir)
+}
+
+// matchSelf evaluates the patterns that apply to rel without considering
+// whether a parent directory is excluded. With tracked, only the defaults
+// and .scryignore patterns are considered.
+func (m *Matcher) matchSelf(rel string, isDir, tracked bool) bool {
+	patterns := m.applicable(rel)
+	if tracked {
+		kept := patterns[:0]
+		for _, p := range patterns {
+			if p.Source == DefaultSource || path.Base(p.Source) == ".scryignore" {
+				kept = append(kept, p)
 			}
-			continue
 		}
-		base := filepath.Base(rel)
-		if ok, _ := filepath.Match(g, base); ok {
-			return true
+		patterns = kept
+	}
+	_, ok := matchList(patterns, rel, isDir)
+	return ok
+}
+
+// applicable returns the patterns that may apply to rel, in precedence order.
+func (m *Matcher) applicable(rel string) []Pattern {
+	patterns := append([]Pattern{}, m.global...)
+	patterns = append(patterns, m.patternsFor("")...)
+	parts := strings.Split(rel, "/")
+	for i := 1; i < len(parts); i++ {
+		patterns = append(patterns, m.patternsFor(strings.Join(parts[:i], "/"))...)
+	}
+	return patterns
+}
+
+// matchList applies patterns in order. It returns the last pattern matching
+// rel and whether that pattern ignores it.
+func matchList(patterns []Pattern, rel string, isDir bool) (*Pattern, bool) {
+	var last *Pattern
+	for i := range patterns {
+		if patterns[i].matches(rel, isDir) {
+			last = &patterns[i]
 		}
 	}
-	return false
+	if last == nil {
+		return nil, false
+	}
+	return last, !last.Negate
+}
+
+// patternsFor returns the patterns declared by ignore files in dir, loading
+// them on first use. Unreadable nested ignore files are skipped, as git does.
+func (m *Matcher) patternsFor(dir string) []Pattern {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if patterns, ok := m.dirs[dir]; ok {
+		return patterns
+	}
+	patterns, _ := readDirPatterns(m.root, dir)
+	m.dirs[dir] = patterns
+	return patterns
 }
 
-func loadPatterns(path string) ([]string, error) {
-	file, err := os.Open(path)
+func readDirPatterns(root, dir string) ([]Pattern, error) {
+	var patterns []Pattern
+	for _, name := range ignoreFiles {
+		source := path.Join(dir, name)
+		loaded, err := loadPatterns(filepath.Join(root, filepath.FromSlash(source)), source, dir)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, loaded...)
+	}
+	return patterns, nil
+}
+
+// parsePatterns parses built-in globs; Line is the position in the list.
+func parsePatterns(globs []string, base, source string) []Pattern {
+	var patterns []Pattern
+	for i, glob := range globs {
+		if p, ok := ParsePattern(glob, base); ok {
+			p.Source = source
+			p.Line = i + 1
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+// sourceName labels an ignore file relative to root when it lives inside it.
+func sourceName(root, file string) string {
+	if rel, err := filepath.Rel(root, file); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return file
+}
+
+func cleanRel(relPath string) string {
+	rel := filepath.ToSlash(relPath)
+	rel = strings.TrimPrefix(rel, "./")
+	return strings.Trim(rel, "/")
+}
+
+// loadPatterns reads an ignore file, labelling each pattern with source and
+// its line number. A missing file yields no patterns.
+func loadPatterns(file, source, base string) ([]Pattern, error) {
+	f, err := os.Open(file)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil, nil
 		}
 		return nil, err
 	}
-	defer file.Close()
+	defer f.Close()
 
-	var patterns []string
-	scanner := bufio.NewScanner(file)
+	var patterns []Pattern
+	scanner := bufio.NewScanner(f)
+	line := 0
 	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" || strings.HasPrefix(line, "#") {
+		line++
+		text := strings.TrimPrefix(scanner.Text(), "\ufeff")
+		p, ok := ParsePattern(text, base)
+		if !ok {
 			continue
 		}
-		patterns = append(patterns, filepath.ToSlash(line))
+		p.Source = source
+		p.Line = line
+		patterns = append(patterns, p)
 	}
 	return patterns, scanner.Err()
 }
-
-func defaultPatterns() []string {
-	return []string{
-		"*_test.go",
-		"testdata/",
-	}
-}
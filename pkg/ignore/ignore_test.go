@@ -41,14 +41,18 @@ func TestLoadPatternsAndMatcherPaths(t *testing.T) {
 	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
 		t.Fatalf("write: %v", err)
 	}
-	patterns, err := loadPatterns(path)
+	patterns, err := loadPatterns(path, ".gitignore", "")
 	if err != nil {
 		t.Fatalf("load patterns: %v", err)
 	}
 	if len(patterns) != 3 {
 		t.Fatalf("expected 3 patterns, got %d", len(patterns))
 	}
-	m := &Matcher{root: root, globs: patterns}
+	if patterns[0].Location() != ".gitignore:2" {
+		t.Fatalf("unexpected location: %s", patterns[0].Location())
+	}
+	m := newMatcher(root, nil)
+	m.global = patterns
 	cases := []struct {
 		path    string
 		ignored bool
@@ -82,13 +86,13 @@ func TestLoadPatternsScannerError(t *testing.T) {
 	if err := os.WriteFile(path, []byte(longLine), 0o644); err != nil {
 		t.Fatalf("write: %v", err)
 	}
-	if _, err := loadPatterns(path); err == nil {
+	if _, err := loadPatterns(path, ".gitignore", ""); err == nil {
 		t.Fatalf("expected scanner error")
 	}
 }
 
 func TestLoadPatternsMissingFile(t *testing.T) {
-	patterns, err := loadPatterns(filepath.Join(t.TempDir(), "missing"))
+	patterns, err := loadPatterns(filepath.Join(t.TempDir(), "missing"), "missing", "")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -98,7 +102,7 @@ func TestLoadPatternsMissingFile(t *testing.T) {
 }
 
 func TestMatcherPathWithSlashNoMatch(t *testing.T) {
-	m := &Matcher{root: t.TempDir(), globs: []string{"dir/file.txt"}}
+	m := newMatcher(t.TempDir(), []string{"dir/file.txt"})
 	if m.Ignored("dir/other.txt") {
 		t.Fatalf("expected no match for non-matching slash pattern")
 	}
@@ -115,8 +119,135 @@ func TestLoadWithScryIgnoreError(t *testing.T) {
 }
 
 func TestMatcherSkipsEmptyGlob(t *testing.T) {
-	m := &Matcher{root: t.TempDir(), globs: []string{""}}
+	m := newMatcher(t.TempDir(), []string{""})
 	if m.Ignored("file.txt") {
 		t.Fatalf("expected empty glob to be skipped")
 	}
 }
+
+func TestLoadOptionsIncludeTests(t *testing.T) {
+	root := t.TempDir()
+	m, err := LoadOptions(root, Options{IncludeTests: true, Defaults: []string{"dist/"}})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	cases := []struct {
+		path    string
+		ignored bool
+		test    bool
+	}{
+		{"pkg/scan/scan_test.go", false, true},
+		{"testdata/fixtures/input.txt", false, true},
+		{"pkg/scan/scan.go", false, false},
+		{"dist/app.js", true, false},
+	}
+	for _, c := range cases {
+		if got := m.Ignored(c.path); got != c.ignored {
+			t.Fatalf("path %s ignored=%v want %v", c.path, got, c.ignored)
+		}
+		if got := m.IsTest(c.path); got != c.test {
+			t.Fatalf("path %s test=%v want %v", c.path, got, c.test)
+		}
+	}
+}
+
+func TestLoadOptionsIgnoreFileOverridesDefaults(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, ".gitignore"), []byte("!keep_test.go\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	m, err := Load(root)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if m.Ignored("keep_test.go") {
+		t.Fatalf("expected .gitignore negation to re-include default-ignored file")
+	}
+	if !m.Ignored("drop_test.go") {
+		t.Fatalf("expected default test pattern to apply")
+	}
+}
+
+func TestExplainReportsSource(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, ".gitignore"), []byte("# build output\nbuild/\n*.log\n!keep.log\n"), 0o644); err != nil {
+		t.Fatalf("write gitignore: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "docs"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "docs", ".scryignore"), []byte("drafts/\n"), 0o644); err != nil {
+		t.Fatalf("write scryignore: %v", err)
+	}
+	m, err := Load(root)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	cases := []struct {
+		path     string
+		isDir    bool
+		location string
+		text     string
+		ignored  bool
+	}{
+		{"build/out.o", false, ".gitignore:2", "build/", true},
+		{"debug.log", false, ".gitignore:3", "*.log", true},
+		{"keep.log", false, ".gitignore:4", "!keep.log", false},
+		{"docs/drafts/a.md", false, "docs/.scryignore:1", "drafts/", true},
+		{"pkg/a_test.go", false, "default:1", "*_test.go", true},
+	}
+	for _, c := range cases {
+		p, ignored := m.Explain(c.path, c.isDir)
+		if p == nil {
+			t.Fatalf("path %s: expected a pattern", c.path)
+		}
+		if p.Location() != c.location || p.Text != c.text || ignored != c.ignored {
+			t.Fatalf("path %s: got %s %q ignored=%v, want %s %q ignored=%v", c.path, p.Location(), p.Text, ignored, c.location, c.text, c.ignored)
+		}
+	}
+	if p, ignored := m.Explain("main.go", false); p != nil || ignored {
+		t.Fatalf("expected no pattern for main.go, got %+v", p)
+	}
+}
+
+func TestMatchTrackedSkipsGitignore(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		".gitignore":      "build/\n*.log\n",
+		"pkg/.scryignore": "gen.go\n",
+		"pkg/.gitignore":  "local.go\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	m, err := LoadOptions(root, Options{Defaults: []string{"*.min.js"}})
+	if err != nil {
+		t.Fatalf("load matcher: %v", err)
+	}
+	cases := []struct {
+		path             string
+		ignored, tracked bool
+	}{
+		{"build/out.go", true, false},
+		{"app.log", true, false},
+		{"pkg/local.go", true, false},
+		{"pkg/gen.go", true, true},
+		{"site/app.min.js", true, true},
+		{"pkg/scan_test.go", true, true},
+		{"pkg/main.go", false, false},
+	}
+	for _, c := range cases {
+		if got := m.Match(c.path, false); got != c.ignored {
+			t.Fatalf("path %s ignored=%v want %v", c.path, got, c.ignored)
+		}
+		if got := m.MatchTracked(c.path, false); got != c.tracked {
+			t.Fatalf("path %s tracked ignored=%v want %v", c.path, got, c.tracked)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package ignore
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Pattern is a single parsed gitignore rule. Base is the slash-separated
+// directory (relative to the matcher root) of the file that declared it;
+// Source and Line locate the declaration for diagnostics.
+type Pattern struct {
+	Text     string
+	Base     string
+	Source   string
+	Line     int
+	Negate   bool
+	DirOnly  bool
+	Anchored bool
+	segments []string
+}
+
+// ParsePattern parses one gitignore line. It reports false for blank lines and comments.
+func ParsePattern(line, base string) (Pattern, bool) {
+	line = strings.TrimSuffix(line, "\r")
+	line = trimTrailingSpaces(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return Pattern{}, false
+	}
+	p := Pattern{Text: line, Base: strings.Trim(base, "/")}
+	body := line
+	switch {
+	case strings.HasPrefix(body, "!"):
+		p.Negate = true
+		body = body[1:]
+	case strings.HasPrefix(body, `\!`), strings.HasPrefix(body, `\#`):
+		body = body[1:]
+	}
+	if strings.HasSuffix(body, "/") {
+		p.DirOnly = true
+		body = strings.TrimRight(body, "/")
+	}
+	if body == "" {
+		return Pattern{}, false
+	}
+	if strings.Contains(body, "/") {
+		p.Anchored = true
+		body = strings.TrimPrefix(body, "/")
+	}
+	p.segments = strings.Split(body, "/")
+	return p, true
+}
+
+// trimTrailingSpaces drops trailing spaces unless they are escaped with a backslash.
+func trimTrailingSpaces(line string) string {
+	for strings.HasSuffix(line, " ") {
+		trimmed := line[:len(line)-1]
+		if strings.HasSuffix(trimmed, `\`) && !strings.HasSuffix(trimmed, `\\`) {
+			return trimmed[:len(trimmed)-1] + " "
+		}
+		line = trimmed
+	}
+	return line
+}
+
+// Location formats the declaration site as "source:line".
+func (p Pattern) Location() string {
+	return fmt.Sprintf("%s:%d", p.Source, p.Line)
+}
+
+// matches reports whether the pattern applies to rel, a slash-separated path
+// relative to the matcher root. Negation is left to the caller.
+func (p Pattern) matches(rel string, isDir bool) bool {
+	if p.DirOnly && !isDir {
+		return false
+	}
+	sub := rel
+	if p.Base != "" {
+		if !strings.HasPrefix(rel, p.Base+"/") {
+			return false
+		}
+		sub = rel[len(p.Base)+1:]
+	}
+	if !p.Anchored {
+		return wildmatch(p.segments[0], path.Base(sub))
+	}
+	return matchSegments(p.segments, strings.Split(sub, "/"))
+}
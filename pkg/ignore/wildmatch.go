@@ -0,0 +1,204 @@
+package ignore
+
+import (
+	"path"
+	"strings"
+	"unicode"
+)
+
+// matchSegments matches slash-separated pattern segments against path segments.
+// A "**" segment matches zero or more path segments, except in trailing position
+// where it must match at least one (so "dir/**" matches the contents of dir, not dir).
+func matchSegments(pattern []string, path []string) bool {
+	if len(pattern) == 0 {
+		return len(path) == 0
+	}
+	if pattern[0] == "**" {
+		if len(pattern) == 1 {
+			return len(path) > 0
+		}
+		for i := 0; i <= len(path); i++ {
+			if matchSegments(pattern[1:], path[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(path) == 0 {
+		return false
+	}
+	if !wildmatch(pattern[0], path[0]) {
+		return false
+	}
+	return matchSegments(pattern[1:], path[1:])
+}
+
+// wildmatch matches a single path segment against a glob supporting *, ?, [...]
+// classes (with ! or ^ negation, ranges and [:class:] names) and backslash escapes.
+func wildmatch(pattern, name string) bool {
+	p := []rune(pattern)
+	s := []rune(name)
+	return wildmatchRunes(p, s)
+}
+
+func wildmatchRunes(p, s []rune) bool {
+	for len(p) > 0 {
+		switch p[0] {
+		case '*':
+			for len(p) > 0 && p[0] == '*' {
+				p = p[1:]
+			}
+			if len(p) == 0 {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if wildmatchRunes(p, s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+			p, s = p[1:], s[1:]
+		case '[':
+			if len(s) == 0 {
+				return false
+			}
+			ok, rest, valid := matchClass(p, s[0])
+			if !valid {
+				if s[0] != '[' {
+					return false
+				}
+				p, s = p[1:], s[1:]
+				continue
+			}
+			if !ok {
+				return false
+			}
+			p, s = rest, s[1:]
+		case '\\':
+			if len(p) > 1 {
+				p = p[1:]
+			}
+			fallthrough
+		default:
+			if len(s) == 0 || p[0] != s[0] {
+				return false
+			}
+			p, s = p[1:], s[1:]
+		}
+	}
+	return len(s) == 0
+}
+
+// matchClass evaluates the bracket expression at the start of p against r.
+// It reports whether r matched, the pattern remaining after the closing
+// bracket, and whether the expression was well formed.
+func matchClass(p []rune, r rune) (bool, []rune, bool) {
+	i := 1
+	negate := false
+	if i < len(p) && (p[i] == '!' || p[i] == '^') {
+		negate = true
+		i++
+	}
+	matched := false
+	first := true
+	for i < len(p) {
+		c := p[i]
+		if c == ']' && !first {
+			return matched != negate, p[i+1:], true
+		}
+		first = false
+		if c == '[' && i+1 < len(p) && p[i+1] == ':' {
+			end := indexRunes(p[i+2:], ":]")
+			if end >= 0 {
+				name := string(p[i+2 : i+2+end])
+				if classMatches(name, r) {
+					matched = true
+				}
+				i += 2 + end + 2
+				continue
+			}
+		}
+		if c == '\\' && i+1 < len(p) {
+			i++
+			c = p[i]
+		}
+		lo := c
+		i++
+		if i+1 < len(p) && p[i] == '-' && p[i+1] != ']' {
+			hi := p[i+1]
+			i += 2
+			if hi == '\\' && i < len(p) {
+				hi = p[i]
+				i++
+			}
+			if lo <= r && r <= hi {
+				matched = true
+			}
+			continue
+		}
+		if r == lo {
+			matched = true
+		}
+	}
+	return false, nil, false
+}
+
+func classMatches(name string, r rune) bool {
+	switch name {
+	case "alnum":
+		return unicode.IsLetter(r) || unicode.IsDigit(r)
+	case "alpha":
+		return unicode.IsLetter(r)
+	case "blank":
+		return r == ' ' || r == '\t'
+	case "cntrl":
+		return unicode.IsControl(r)
+	case "digit":
+		return r >= '0' && r <= '9'
+	case "graph":
+		return unicode.IsGraphic(r) && !unicode.IsSpace(r)
+	case "lower":
+		return unicode.IsLower(r)
+	case "print":
+		return unicode.IsPrint(r)
+	case "punct":
+		return unicode.IsPunct(r) || unicode.IsSymbol(r)
+	case "space":
+		return unicode.IsSpace(r)
+	case "upper":
+		return unicode.IsUpper(r)
+	case "xdigit":
+		return strings.ContainsRune("0123456789abcdefABCDEF", r)
+	default:
+		return false
+	}
+}
+
+func indexRunes(s []rune, sub string) int {
+	target := []rune(sub)
+	for i := 0; i+len(target) <= len(s); i++ {
+		if string(s[i:i+len(target)]) == sub {
+			return i
+		}
+	}
+	return -1
+}
+
+// MatchGlob reports whether rel, a slash-separated relative path, matches a
+// glob with the same syntax as ignore patterns: a pattern without a slash
+// matches the base name at any depth, "**" matches any number of
+// directories, and a trailing slash matches everything under a directory.
+func MatchGlob(pattern, rel string) bool {
+	if strings.HasSuffix(pattern, "/") {
+		pattern += "**"
+	}
+	pattern = strings.TrimPrefix(pattern, "/")
+	if !strings.Contains(pattern, "/") {
+		return wildmatch(pattern, path.Base(rel))
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(rel, "/"))
+}
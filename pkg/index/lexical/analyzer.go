@@ -0,0 +1,338 @@
+package lexical
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// DefaultMinLength is the shortest term kept, in runes, when
+// Analyzer.MinLength is zero.
+const DefaultMinLength = 2
+
+// analyzerVersion changes whenever Analyzer output changes for the same
+// settings, so indexes built by older versions are detected as stale.
+const analyzerVersion = 6
+
+// Analyzer turns text into terms. Indexing, search and ask evidence
+// selection must use the same Analyzer: terms it produces for a query only
+// match terms it produced for indexed text.
+//
+// The zero Analyzer normalizes text to NFKC, lowercases it and removes the
+// diacritics of Latin letters, so that "nasıl" matches "nasil". It keeps
+// terms of at least DefaultMinLength runes and indexes identifiers such as
+// FileHash or index_db_path both whole and split into words. It drops the
+// built-in stopwords of its Lang and adds the English stem of each term that
+// has one.
+type Analyzer struct {
+	// Lang is the language of the analyzed text, as in chunk.Chunk.Lang. It
+	// selects the built-in stopwords: Go keywords and English function
+	// words for "go", English function words otherwise, including queries.
+	Lang string
+	// Normalization is the Unicode normalization form applied first: NFKC
+	// when empty, NFC or NoNormalization.
+	Normalization string
+	// KeepCase disables lowercasing.
+	KeepCase bool
+	// Locale selects locale-specific lowercasing, LocaleTurkish or
+	// LocaleAzerbaijani; the default follows Unicode.
+	Locale string
+	// KeepDiacritics disables diacritic folding.
+	KeepDiacritics bool
+	// NoSplit keeps identifiers whole.
+	NoSplit bool
+	// NoStem disables stemming.
+	NoStem bool
+	// NoBuiltinStopwords keeps the built-in stopwords; Stopwords still
+	// apply.
+	NoBuiltinStopwords bool
+	// MinLength drops shorter terms, counted in runes.
+	MinLength int
+	// Stopwords are terms that are never indexed or searched, compared in
+	// the form Fold returns.
+	Stopwords map[string]bool
+}
+
+// Token is a term and the byte offsets of the text it was read from.
+type Token struct {
+	Term       string
+	Start, End int
+}
+
+// Tokens splits text into identifiers, runs of letters, digits and
+// underscores, and returns those that survive the analyzer's filters, in
+// order. Unless NoSplit is set, each identifier made of several words is
+// followed by its words.
+func (a Analyzer) Tokens(text string) []Token {
+	var tokens []Token
+	start := -1
+	for i, r := range text {
+		if isIdentRune(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			tokens = a.appendIdent(tokens, text, start, i)
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = a.appendIdent(tokens, text, start, len(text))
+	}
+	return tokens
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.IsMark(r)
+}
+
+func (a Analyzer) appendIdent(tokens []Token, text string, start, end int) []Token {
+	for start < end && text[start] == '_' {
+		start++
+	}
+	for end > start && text[end-1] == '_' {
+		end--
+	}
+	if start == end {
+		return tokens
+	}
+	tokens = a.appendToken(tokens, text, start, end)
+	if a.NoSplit {
+		return tokens
+	}
+	if parts := identParts(text[start:end]); len(parts) > 1 {
+		for _, p := range parts {
+			tokens = a.appendToken(tokens, text, start+p[0], start+p[1])
+		}
+	}
+	return tokens
+}
+
+// identParts returns the byte ranges of the words of an identifier. Words
+// are separated by underscores and by case changes: a lower-case letter or
+// digit followed by an upper-case letter (fileHash, Int64Value), and the last
+// capital of an acronym followed by a lower-case letter (DBPath), unless that
+// letter is an s ending the word, which makes the acronym plural (chunkIDs,
+// URLs). Digits stay with the letters before them (sha256, Base64).
+func identParts(id string) [][2]int {
+	var parts [][2]int
+	start := -1
+	var prev rune
+	for i, r := range id {
+		if r == '_' {
+			if start >= 0 {
+				parts = append(parts, [2]int{start, i})
+				start = -1
+			}
+			continue
+		}
+		if start >= 0 && unicode.IsUpper(r) {
+			rest := id[i+utf8.RuneLen(r):]
+			next, size := utf8.DecodeRuneInString(rest)
+			after, _ := utf8.DecodeRuneInString(rest[size:])
+			plural := next == 's' && !unicode.IsLower(after)
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || unicode.IsUpper(prev) && unicode.IsLower(next) && !plural {
+				parts = append(parts, [2]int{start, i})
+				start = -1
+			}
+		}
+		if start < 0 {
+			start = i
+		}
+		prev = r
+	}
+	if start >= 0 {
+		parts = append(parts, [2]int{start, len(id)})
+	}
+	return parts
+}
+
+// appendToken appends the term of text[start:end], then its stem when that
+// differs, unless they are filtered out.
+func (a Analyzer) appendToken(tokens []Token, text string, start, end int) []Token {
+	term := a.normalize(text[start:end])
+	lower := toLower(term, a.Locale)
+	if !a.KeepCase {
+		term = lower
+	}
+	if !a.KeepDiacritics {
+		term, lower = foldDiacritics(term), foldDiacritics(lower)
+	}
+	if a.drop(term, lower) {
+		return tokens
+	}
+	tokens = append(tokens, Token{Term: term, Start: start, End: end})
+	if a.NoStem {
+		return tokens
+	}
+	if stem := Stem(term); stem != term && !a.drop(stem, stem) {
+		tokens = append(tokens, Token{Term: stem, Start: start, End: end})
+	}
+	return tokens
+}
+
+func (a Analyzer) drop(term, lower string) bool {
+	if utf8.RuneCountInString(term) < a.minLength() || a.Stopwords[lower] {
+		return true
+	}
+	return !a.NoBuiltinStopwords && builtinStopword(a.Lang, lower)
+}
+
+func (a Analyzer) normalize(s string) string {
+	switch a.Normalization {
+	case NoNormalization:
+		return s
+	case NFC:
+		return norm.NFC.String(s)
+	default:
+		return norm.NFKC.String(s)
+	}
+}
+
+// Fold returns s normalized, lowercased and without diacritics as the
+// analyzer's settings ask, the form in which Stopwords are compared.
+func (a Analyzer) Fold(s string) string {
+	s = toLower(a.normalize(s), a.Locale)
+	if !a.KeepDiacritics {
+		s = foldDiacritics(s)
+	}
+	return s
+}
+
+// ForLang returns a copy of a for text in lang.
+func (a Analyzer) ForLang(lang string) Analyzer {
+	a.Lang = lang
+	return a
+}
+
+func (a Analyzer) minLength() int {
+	if a.MinLength <= 0 {
+		return DefaultMinLength
+	}
+	return a.MinLength
+}
+
+// Terms returns the terms of text in order, with repeats, as they are
+// indexed.
+func (a Analyzer) Terms(text string) []string {
+	tokens := a.Tokens(text)
+	terms := make([]string, len(tokens))
+	for i, t := range tokens {
+		terms[i] = t.Term
+	}
+	return terms
+}
+
+// QueryTerms returns the distinct terms of a query in order of first use.
+func (a Analyzer) QueryTerms(query string) []string {
+	seen := map[string]bool{}
+	var terms []string
+	for _, t := range a.Tokens(query) {
+		if !seen[t.Term] {
+			seen[t.Term] = true
+			terms = append(terms, t.Term)
+		}
+	}
+	return terms
+}
+
+// QueryWords groups the distinct terms of a query by the word they came
+// from, so that an identifier, its parts and their stems count as one word.
+// Each term is listed under the first word that produced it; words with no
+// new terms are left out.
+func (a Analyzer) QueryWords(query string) [][]string {
+	seen := map[string]bool{}
+	var words [][]string
+	var word []string
+	end := -1
+	for _, t := range a.Tokens(query) {
+		if t.Start >= end {
+			if len(word) > 0 {
+				words = append(words, word)
+			}
+			word, end = nil, t.End
+		}
+		if !seen[t.Term] {
+			seen[t.Term] = true
+			word = append(word, t.Term)
+		}
+	}
+	if len(word) > 0 {
+		words = append(words, word)
+	}
+	return words
+}
+
+// Contains reports whether text has a token whose term is one of terms.
+func (a Analyzer) Contains(text string, terms ...string) bool {
+	return a.Count(text, terms) > 0
+}
+
+// Count returns how many of the distinct terms occur in text.
+func (a Analyzer) Count(text string, terms []string) int {
+	want := map[string]bool{}
+	for _, t := range terms {
+		if t != "" {
+			want[t] = true
+		}
+	}
+	if len(want) == 0 {
+		return 0
+	}
+	count := 0
+	for _, t := range a.Tokens(text) {
+		if want[t.Term] {
+			delete(want, t.Term)
+			count++
+		}
+	}
+	return count
+}
+
+// Fingerprint identifies the analyzer's settings other than Lang. An index
+// records the fingerprint of the analyzer that built it; searching it with an
+// analyzer whose fingerprint differs gives wrong results until it is rebuilt.
+func (a Analyzer) Fingerprint() string {
+	fp := fmt.Sprintf("v%d min=%d", analyzerVersion, a.minLength())
+	if a.Normalization != "" && a.Normalization != NFKC {
+		fp += " form=" + a.Normalization
+	}
+	if a.Locale != "" {
+		fp += " locale=" + a.Locale
+	}
+	if a.KeepDiacritics {
+		fp += " keepdiacritics"
+	}
+	if a.KeepCase {
+		fp += " keepcase"
+	}
+	if a.NoSplit {
+		fp += " nosplit"
+	}
+	if a.NoStem {
+		fp += " nostem"
+	}
+	if a.NoBuiltinStopwords {
+		fp += " nobuiltinstopwords"
+	}
+	if len(a.Stopwords) > 0 {
+		words := make([]string, 0, len(a.Stopwords))
+		for w, ok := range a.Stopwords {
+			if ok {
+				words = append(words, w)
+			}
+		}
+		sort.Strings(words)
+		sum := sha256.Sum256([]byte(strings.Join(words, "\n")))
+		fp += fmt.Sprintf(" stopwords=%d:%s", len(words), hex.EncodeToString(sum[:6]))
+	}
+	return fp
+}
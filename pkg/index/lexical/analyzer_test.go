@@ -0,0 +1,208 @@
+package lexical
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// plain analyzers neither stem nor drop built-in stopwords, to test the
+// other settings in isolation.
+func plain(a Analyzer) Analyzer {
+	a.NoStem, a.NoBuiltinStopwords = true, true
+	return a
+}
+
+func TestAnalyzerTokens(t *testing.T) {
+	text := "Open the DB: go.Open(db)"
+	got := plain(Analyzer{}).Tokens(text)
+	want := []Token{{"open", 0, 4}, {"the", 5, 8}, {"db", 9, 11}, {"go", 13, 15}, {"open", 16, 20}, {"db", 21, 23}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected tokens:\n%v\nwant\n%v", got, want)
+	}
+	for _, tok := range got {
+		if text[tok.Start:tok.End] == "" {
+			t.Fatalf("empty range for %v", tok)
+		}
+	}
+}
+
+func TestAnalyzerSettings(t *testing.T) {
+	cases := []struct {
+		a    Analyzer
+		text string
+		want []string
+	}{
+		{Analyzer{KeepDiacritics: true}, "a Go ünü x1", []string{"go", "ünü", "x1"}},
+		{Analyzer{KeepDiacritics: true, MinLength: 3}, "go db scan ünü", []string{"scan", "ünü"}},
+		{Analyzer{MinLength: 1}, "a b", []string{"a", "b"}},
+		{Analyzer{KeepCase: true}, "Scan scan", []string{"Scan", "scan"}},
+		{Analyzer{Stopwords: map[string]bool{"the": true}}, "The scan of the tree", []string{"scan", "of", "tree"}},
+		{Analyzer{KeepCase: true, Stopwords: map[string]bool{"the": true}}, "The Tree", []string{"Tree"}},
+	}
+	for _, tc := range cases {
+		if got := plain(tc.a).Terms(tc.text); !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("%+v terms of %q: got %v, want %v", tc.a, tc.text, got, tc.want)
+		}
+	}
+}
+
+func TestAnalyzerNormalizes(t *testing.T) {
+	cases := []struct {
+		a    Analyzer
+		text string
+		want []string
+	}{
+		// Turkish: dotted and dotless i fold to i, so queries typed on
+		// any keyboard match.
+		{Analyzer{}, "nasıl çalışıyor", []string{"nasil", "calisiyor"}},
+		{Analyzer{}, "NASIL ÇALIŞIYOR İSTANBUL", []string{"nasil", "calisiyor", "istanbul"}},
+		{Analyzer{KeepDiacritics: true}, "IŞIK", []string{"işik"}},
+		{Analyzer{KeepDiacritics: true, Locale: LocaleTurkish}, "IŞIK İzmir", []string{"ışık", "izmir"}},
+		{Analyzer{KeepDiacritics: true, Locale: LocaleAzerbaijani}, "BAKI", []string{"bakı"}},
+		// German: ß is spelled ss by lowercasing, umlauts fold.
+		{Analyzer{}, "Straße STRASSE Müller", []string{"strasse", "strasse", "muller"}},
+		{Analyzer{KeepDiacritics: true}, "Straße Müller", []string{"strasse", "müller"}},
+		{Analyzer{KeepCase: true}, "Größe", []string{"Grosse"}},
+		// Accented text: precomposed and decomposed forms are equivalent.
+		{Analyzer{KeepDiacritics: true}, "café cafe\u0301 CAFÉ", []string{"café", "café", "café"}},
+		{Analyzer{KeepDiacritics: true, Normalization: NFC}, "Ångström \u212Bngstro\u0308m", []string{"ångström", "ångström"}},
+		{Analyzer{}, "Crème brûlée, naïve Señor Łódź Øre", []string{"creme", "brulee", "naive", "senor", "lodz", "ore"}},
+		{Analyzer{KeepDiacritics: true, Normalization: NoNormalization}, "cafe\u0301 café", []string{"cafe\u0301", "café"}},
+		// NFKC unfolds ligatures and fullwidth forms; NFC keeps them.
+		{Analyzer{}, "ﬁle ＳＣＡＮ x²", []string{"file", "scan", "x2"}},
+		{Analyzer{Normalization: NFC}, "ﬁle ＳＣＡＮ", []string{"ﬁle", "ｓｃａｎ"}},
+		// Other scripts: precomposed and decomposed letters are equivalent,
+		// and folding leaves their marks alone.
+		{Analyzer{}, "йогурт и\u0306огурт ЙОГУРТ", []string{"йогурт", "йогурт", "йогурт"}},
+		{Analyzer{}, "άλφα α\u0301λφα \u1F71λφα", []string{"άλφα", "άλφα", "άλφα"}},
+		{Analyzer{}, "ΟΔΟΣ οδος", []string{"οδοσ", "οδοσ"}},
+		{Analyzer{}, "がっこう か\u3099っこう", []string{"がっこう", "がっこう"}},
+		// Devanagari vowel signs are spacing marks inside the word.
+		{Analyzer{}, "हिन्दी भाषा", []string{"हिन्दी", "भाषा"}},
+	}
+	for _, tc := range cases {
+		if got := plain(tc.a).Terms(tc.text); !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("%+v terms of %q: got %q, want %q", tc.a, tc.text, got, tc.want)
+		}
+	}
+
+	// Stopwords match whatever form they are written in.
+	a := plain(Analyzer{})
+	a.Stopwords = map[string]bool{a.Fold("Über"): true}
+	if got := a.Terms("uber ÜBER alles"); !reflect.DeepEqual(got, []string{"alles"}) {
+		t.Fatalf("unexpected terms: %q", got)
+	}
+}
+
+func TestAnalyzerSplitsIdentifiers(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "..", "testdata", "identifiers.txt"))
+	if err != nil {
+		t.Fatalf("read corpus: %v", err)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if got := plain(Analyzer{}).Terms(fields[0]); !reflect.DeepEqual(got, fields[1:]) {
+			t.Fatalf("%s: got %v, want %v", fields[0], got, fields[1:])
+		}
+	}
+
+	cases := []struct {
+		a    Analyzer
+		text string
+		want []string
+	}{
+		{Analyzer{}, "max_file_size", []string{"max_file_size", "max", "file", "size"}},
+		{Analyzer{}, "os.O_RDONLY", []string{"os", "o_rdonly", "rdonly"}},
+		{Analyzer{}, "__init__ _x", []string{"init"}},
+		{Analyzer{}, "fileHash2Go", []string{"filehash2go", "file", "hash2", "go"}},
+		{Analyzer{KeepCase: true}, "IndexDB", []string{"IndexDB", "Index", "DB"}},
+		{Analyzer{NoSplit: true}, "FileHash file_hash", []string{"filehash", "file_hash"}},
+	}
+	for _, tc := range cases {
+		if got := plain(tc.a).Terms(tc.text); !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("%+v terms of %q: got %v, want %v", tc.a, tc.text, got, tc.want)
+		}
+	}
+
+	// Words point at their own bytes, for snippets.
+	text := "x := IndexDBPath"
+	for _, tok := range (Analyzer{}).Tokens(text) {
+		if !strings.EqualFold(text[tok.Start:tok.End], tok.Term) {
+			t.Fatalf("token %q covers %q", tok.Term, text[tok.Start:tok.End])
+		}
+	}
+}
+
+func TestAnalyzerQueryTermsAndCount(t *testing.T) {
+	a := plain(Analyzer{})
+	if got := a.QueryTerms("scan Scan ignore scan"); !reflect.DeepEqual(got, []string{"scan", "ignore"}) {
+		t.Fatalf("unexpected query terms: %v", got)
+	}
+	if got := a.Count("scanner ignores scan", []string{"scan", "ignore", "scan", ""}); got != 1 {
+		t.Fatalf("expected only whole terms to count, got %d", got)
+	}
+	if a.Contains("gitignore", "ignore") || !a.Contains("git ignore", "ignore") {
+		t.Fatalf("unexpected Contains result")
+	}
+}
+
+func TestAnalyzerQueryWords(t *testing.T) {
+	got := Analyzer{}.QueryWords("indexing FileHash index files")
+	want := [][]string{{"indexing", "index"}, {"filehash", "file", "hash"}, {"files"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected query words: %v", got)
+	}
+}
+
+func TestAnalyzerStemsAndStopwords(t *testing.T) {
+	cases := []struct {
+		a    Analyzer
+		text string
+		want []string
+	}{
+		// Stems are added after the words they come from.
+		{Analyzer{}, "indexing the files", []string{"indexing", "index", "files", "file"}},
+		{Analyzer{}, "Indexed index", []string{"indexed", "index", "index"}},
+		// Go chunks drop keywords and common identifiers as well as English
+		// function words; prose and queries keep the keywords.
+		{Analyzer{Lang: "go"}, "func Open() error { return err } // the DB", []string{"open", "error", "db"}},
+		{Analyzer{Lang: "md"}, "return the error", []string{"return", "error"}},
+		{Analyzer{}, "how does func return", []string{"func", "return"}},
+		// A stem that is a stopword is dropped; the word is kept.
+		{Analyzer{Lang: "go"}, "returns", []string{"returns"}},
+		{Analyzer{NoStem: true}, "indexing files", []string{"indexing", "files"}},
+		{Analyzer{NoBuiltinStopwords: true, NoStem: true}, "the func", []string{"the", "func"}},
+		{Analyzer{KeepCase: true}, "Indexing indexing", []string{"Indexing", "indexing", "index"}},
+	}
+	for _, tc := range cases {
+		if got := tc.a.Terms(tc.text); !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("%+v terms of %q: got %v, want %v", tc.a, tc.text, got, tc.want)
+		}
+	}
+	if got := (Analyzer{}).ForLang("go"); got.Lang != "go" || got.Fingerprint() != (Analyzer{}).Fingerprint() {
+		t.Fatalf("expected ForLang to change only the language, got %+v", got)
+	}
+}
+
+func TestAnalyzerFingerprint(t *testing.T) {
+	base := Analyzer{}.Fingerprint()
+	if (Analyzer{MinLength: DefaultMinLength}).Fingerprint() != base {
+		t.Fatalf("expected the default min length to match the zero analyzer")
+	}
+	a := Analyzer{Stopwords: map[string]bool{"the": true, "and": true}}
+	b := Analyzer{Stopwords: map[string]bool{"and": true, "the": true}}
+	if a.Fingerprint() != b.Fingerprint() {
+		t.Fatalf("expected stopword order not to matter")
+	}
+	for _, other := range []Analyzer{{MinLength: 3}, {KeepCase: true}, {NoSplit: true}, {NoStem: true}, {NoBuiltinStopwords: true}, a} {
+		if other.Fingerprint() == base {
+			t.Fatalf("expected %+v to change the fingerprint", other)
+		}
+	}
+}
@@ -8,6 +8,8 @@ type Posting struct {
 
 type InvertedIndex struct {
 	Postings map[string][]Posting
+	// Analyzer splits added text into terms.
+	Analyzer Analyzer
 }
 
 func New() *InvertedIndex {
@@ -15,7 +17,7 @@ func New() *InvertedIndex {
 }
 
 func (idx *InvertedIndex) Add(chunkID string, text string) []Posting {
-	terms := tokenize(text)
+	terms := idx.Analyzer.Terms(text)
 	counts := map[string]int{}
 	for _, term := range terms {
 		counts[term]++
@@ -28,7 +30,3 @@ func (idx *InvertedIndex) Add(chunkID string, text string) []Posting {
 	}
 	return postings
 }
-
-func tokenize(s string) []string {
-	return Tokenize(s)
-}
@@ -0,0 +1,89 @@
+package lexical
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Unicode normalization forms for Analyzer.Normalization.
+const (
+	NFKC            = "nfkc"
+	NFC             = "nfc"
+	NoNormalization = "none"
+)
+
+// Locales with their own case rules for Analyzer.Locale. Turkish and
+// Azerbaijani lowercase I to dotless ı and İ to i.
+const (
+	LocaleTurkish     = "tr"
+	LocaleAzerbaijani = "az"
+)
+
+// foldedLetters spells letters that have no decomposition but are commonly
+// written without their stroke, dot or ligature, such as Turkish ı or
+// German ß, in plain ASCII.
+var foldedLetters = map[rune]string{
+	'ı': "i", 'ß': "ss", 'ẞ': "SS", 'ø': "o", 'Ø': "O", 'ł': "l", 'Ł': "L",
+	'đ': "d", 'Đ': "D", 'ħ': "h", 'Ħ': "H", 'ŧ': "t", 'Ŧ': "T", 'ð': "d",
+	'Ð': "D", 'þ': "th", 'Þ': "TH", 'æ': "ae", 'Æ': "AE", 'œ': "oe", 'Œ': "OE",
+}
+
+// foldDiacritics removes the accents of Latin letters: é to e, ç to c and ı
+// to i. Marks on letters of other scripts, such as the breve of Cyrillic й,
+// the tonos of Greek ά or the dakuten of Japanese が, are part of the letter
+// and are kept.
+func foldDiacritics(s string) string {
+	if isASCII(s) {
+		return s
+	}
+	var b strings.Builder
+	latin := false
+	for _, r := range norm.NFD.String(s) {
+		if unicode.Is(unicode.Mn, r) {
+			if !latin {
+				b.WriteRune(r)
+			}
+			continue
+		}
+		latin = unicode.Is(unicode.Latin, r)
+		if f, ok := foldedLetters[r]; ok {
+			b.WriteString(f)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return norm.NFC.String(b.String())
+}
+
+// toLower lowercases s by the case rules of locale, the Unicode ones when it
+// is empty. Like Unicode case folding, it spells ß as ss so that Straße
+// matches STRASSE, and Greek final ς as σ.
+func toLower(s, locale string) string {
+	if locale == "" && isASCII(s) {
+		return strings.ToLower(s)
+	}
+	tag := language.Und
+	switch locale {
+	case LocaleTurkish:
+		tag = language.Turkish
+	case LocaleAzerbaijani:
+		tag = language.Azerbaijani
+	}
+	return caseFolds.Replace(cases.Lower(tag).String(s))
+}
+
+var caseFolds = strings.NewReplacer("ß", "ss", "ς", "σ")
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,63 @@
+package lexical
+
+import "testing"
+
+func TestStem(t *testing.T) {
+	// From the vocabulary and output published with the algorithm.
+	cases := map[string]string{
+		"caresses":        "caress",
+		"ponies":          "poni",
+		"ties":            "ti",
+		"cats":            "cat",
+		"feed":            "feed",
+		"agreed":          "agre",
+		"plastered":       "plaster",
+		"bled":            "bled",
+		"motoring":        "motor",
+		"sing":            "sing",
+		"conflated":       "conflat",
+		"troubled":        "troubl",
+		"sized":           "size",
+		"hopping":         "hop",
+		"falling":         "fall",
+		"hissing":         "hiss",
+		"fizzed":          "fizz",
+		"failing":         "fail",
+		"filing":          "file",
+		"happy":           "happi",
+		"sky":             "sky",
+		"relational":      "relat",
+		"conditional":     "condit",
+		"rational":        "ration",
+		"generalizations": "gener",
+		"oscillators":     "oscil",
+		"hopeful":         "hope",
+		"goodness":        "good",
+		"allowance":       "allow",
+		"adjustable":      "adjust",
+		"adjustment":      "adjust",
+		"electrical":      "electr",
+		"communism":       "commun",
+		"effective":       "effect",
+		"adoption":        "adopt",
+		"controlling":     "control",
+		"roll":            "roll",
+		// Words from code and docs.
+		"indexing": "index",
+		"indexes":  "index",
+		"files":    "file",
+		"searches": "search",
+		"parsed":   "pars",
+		"parsing":  "pars",
+	}
+	for word, want := range cases {
+		if got := Stem(word); got != want {
+			t.Fatalf("Stem(%q) = %q, want %q", word, got, want)
+		}
+	}
+	for _, word := range []string{"", "go", "sha256", "Files", "ünü"} {
+		if got := Stem(word); got != word {
+			t.Fatalf("expected %q unchanged, got %q", word, got)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package lexical
+
+import "strings"
+
+// englishStopwords are function words too common in prose to rank by.
+var englishStopwords = wordSet(`
+a about above after again all also am an and any are as at be because been
+before being below between both but by can could did do does doing down
+during each few for from further had has have having he her here hers him
+his how i if in into is it its itself just me more most my no nor not now
+of off on once only or other our ours out over own same she should so some
+such than that the their theirs them then there these they this those
+through to too under until up very was we were what when where which while
+who whom why will with would you your yours`)
+
+// goStopwords are Go keywords and identifiers that appear in most Go code.
+// Go chunks also drop englishStopwords, for their comments.
+var goStopwords = wordSet(`
+break case chan const continue default defer else fallthrough for func go
+goto if import interface map package range return select struct switch type
+var err nil true false`)
+
+func wordSet(words string) map[string]bool {
+	set := map[string]bool{}
+	for _, w := range strings.Fields(words) {
+		set[w] = true
+	}
+	return set
+}
+
+// builtinStopword reports whether term is a built-in stopword for lang.
+func builtinStopword(lang, term string) bool {
+	if lang == "go" && goStopwords[term] {
+		return true
+	}
+	return englishStopwords[term]
+}
@@ -1,10 +1,18 @@
 package indexer
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"runtime"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"scry/pkg/hash"
 	"scry/pkg/index/lexical"
@@ -19,135 +27,620 @@ type Options struct {
 	Clean        bool
 	NoEmbeddings bool
 	JSON         bool
+	Scan         scan.Options
+	// Storage selects where the index lives.
+	Storage workspace.Storage
+	// MaxChunkLines splits longer chunks into pieces; zero keeps them whole.
+	// When it differs from the value the index was built with, the run
+	// rebuilds the index.
+	MaxChunkLines int
+	// Jobs bounds the files read and parsed concurrently; zero uses one
+	// worker per CPU.
+	Jobs int
+	// BatchSize is the number of files passed to the database at a time;
+	// zero uses DefaultBatchSize.
+	BatchSize int
+	// Verify hashes every file instead of trusting matching size and mtime.
+	Verify bool
+	// Wait blocks until another run's index lock is released instead of
+	// failing with workspace.ErrLocked.
+	Wait bool
+	// Analyzer splits chunk text into terms. When it differs from the
+	// analyzer the index was built with, the run rebuilds the index.
+	Analyzer lexical.Analyzer
+	// Paths limits the run to these files and directories, relative to Root.
+	// Indexed files under them that no longer exist are removed; the rest of
+	// the index is left alone. Clean runs ignore it.
+	Paths []string
 }
 
+// AnalyzerSetting is the index setting holding the fingerprint of the
+// analyzer that built it.
+const AnalyzerSetting = "analyzer"
+
+// ChunkingSetting is the index setting holding the MaxChunkLines the index
+// was built with. Indexes that lack it were built with zero.
+const ChunkingSetting = "chunking"
+
+// DefaultBatchSize is the number of files passed to the database at a time
+// when Options.BatchSize is zero.
+const DefaultBatchSize = 256
+
 type Progress struct {
 	Type       string `json:"type"`
 	Stage      string `json:"stage,omitempty"`
 	FilesTotal int    `json:"files_total,omitempty"`
 	File       string `json:"file,omitempty"`
 	Chunks     int    `json:"chunks,omitempty"`
+	Reused     int    `json:"reused,omitempty"`
+	Reason     string `json:"reason,omitempty"`
 	Message    string `json:"message,omitempty"`
 }
 
 type Summary struct {
-	FilesIndexed  int
+	FilesIndexed int
+	// ChunksIndexed counts new chunks; ChunksReused counts chunks of
+	// reindexed files that were already stored and kept their derived data.
 	ChunksIndexed int
+	ChunksReused  int
+	FilesDeleted  int
+	// FilesRacy counts files whose content changed while their size and
+	// mtime did not; only Verify runs detect them.
+	FilesRacy int
 }
 
-func Run(opts Options, emit func(Progress)) (Summary, error) {
-	paths := workspace.Resolve(opts.Root)
+// Run indexes opts.Root while holding the workspace index lock. Files
+// are read, hashed, parsed and tokenized on opts.Jobs workers while a single
+// writer stores results in path order. Changes are written in batches within
+// one transaction that commits only when the run completes, so a run that is
+// cancelled or crashes leaves the previous index in place.
+func Run(ctx context.Context, opts Options, emit func(Progress)) (Summary, error) {
+	paths := workspace.ResolveStorage(opts.Root, opts.Storage)
 	if err := workspace.Ensure(paths); err != nil {
 		return Summary{}, err
 	}
-	if opts.Clean {
-		_ = os.Remove(paths.IndexDBPath)
+	var lock *workspace.Lock
+	var err error
+	if opts.Wait {
+		lock, err = workspace.WaitLock(ctx, paths)
+	} else {
+		lock, err = workspace.AcquireLock(paths)
 	}
-	store, err := metadata.Open(paths.IndexDBPath)
 	if err != nil {
 		return Summary{}, err
 	}
+	defer lock.Release()
+
+	start := time.Now()
+	var (
+		store   *metadata.DB
+		indexed []metadata.FileRecord
+		tests   map[string]bool
+	)
+	clean := opts.Clean
+	if !clean {
+		if sibling := seedIndex(paths); sibling != "" {
+			// A new worktree starts from a copy of another worktree's index,
+			// so only files that differ between them are rechunked.
+			if err := seed(ctx, sibling, paths.IndexDBPath); err != nil {
+				return Summary{}, err
+			}
+			emit(Progress{Type: "progress", Stage: "seed", Message: sibling})
+		}
+		store, err = metadata.Open(ctx, paths.IndexDBPath)
+		if err != nil {
+			return Summary{}, err
+		}
+		indexed, err = store.ListFileRecords(ctx)
+		if err != nil {
+			return Summary{}, err
+		}
+		// Test patterns may have changed since files were flagged.
+		tests, err = store.TestFiles(ctx)
+		if err != nil {
+			return Summary{}, err
+		}
+		reason, err := rebuildReason(ctx, store, opts)
+		if err != nil {
+			return Summary{}, err
+		}
+		if len(indexed) > 0 && reason != "" {
+			emit(Progress{Type: "progress", Stage: "rebuild", Reason: reason})
+			store, indexed, tests = nil, nil, nil
+			clean = true
+		}
+	}
+	w := newWriter(paths.IndexDBPath, clean, opts)
+	defer w.abort()
 
-	scanner, err := scan.New(opts.Root)
+	scanner, err := scan.NewWithOptions(opts.Root, opts.Scan)
 	if err != nil {
 		return Summary{}, err
 	}
-	files, err := scanner.ListFiles()
+	partial := len(opts.Paths) > 0 && !clean
+	var files []scan.File
+	if partial {
+		files, err = scanner.ListPaths(ctx, opts.Paths)
+	} else {
+		files, err = scanner.ListFiles(ctx)
+	}
 	if err != nil {
 		return Summary{}, err
 	}
 	files = filterSupported(files)
 	emit(Progress{Type: "progress", Stage: "scan", FilesTotal: len(files)})
+	for _, sk := range scanner.Skipped {
+		if reportSkip(sk) {
+			emit(Progress{Type: "progress", Stage: "skip", File: sk.Path, Reason: sk.Reason})
+		}
+	}
 
 	// Remove deleted files
-	indexed, err := store.ListFiles()
-	if err != nil {
-		return Summary{}, err
-	}
-	indexedSet := map[string]struct{}{}
-	for _, p := range indexed {
-		indexedSet[p] = struct{}{}
+	summary := Summary{}
+	known := map[string]metadata.FileRecord{}
+	for _, rec := range indexed {
+		known[rec.Path] = rec
 	}
 	currentSet := map[string]struct{}{}
 	for _, f := range files {
-		rel, _ := filepath.Rel(opts.Root, f.Path)
-		rel = filepath.ToSlash(rel)
-		currentSet[rel] = struct{}{}
+		currentSet[relPath(opts.Root, f.Path)] = struct{}{}
 	}
-	for p := range indexedSet {
-		if _, ok := currentSet[p]; !ok {
-			if err := store.DeleteFile(p); err != nil {
+	for _, rec := range indexed {
+		if partial && !underAny(rec.Path, opts.Paths) {
+			continue
+		}
+		if _, ok := currentSet[rec.Path]; !ok {
+			if err := w.deleteFile(ctx, rec.Path); err != nil {
 				return Summary{}, err
 			}
+			summary.FilesDeleted++
+			emit(Progress{Type: "progress", Stage: "delete", File: rec.Path})
 		}
 	}
 
-	lex := lexical.New()
-	summary := Summary{}
-	for _, f := range files {
-		rel, _ := filepath.Rel(opts.Root, f.Path)
-		rel = filepath.ToSlash(rel)
-		data, err := os.ReadFile(f.Path)
-		if err != nil {
-			return Summary{}, err
+	ctx, cancel := context.WithCancel(ctx)
+	p := pass{root: opts.Root, store: store, known: known, tests: tests, verify: opts.Verify, start: start, maxLines: opts.MaxChunkLines, analyzer: opts.Analyzer}
+	results, wait := prepareAll(ctx, opts.Jobs, files, p)
+	defer func() {
+		cancel()
+		wait()
+	}()
+
+	for res := range results {
+		r := <-res
+		if r.err != nil {
+			return Summary{}, r.err
 		}
-		fileHash := hash.FileHash(data)
-		rec, ok, err := store.GetFile(rel)
-		if err != nil {
+		if err := ctx.Err(); err != nil {
 			return Summary{}, err
 		}
-		if ok && rec.Hash == fileHash {
-			continue
+		if r.retest {
+			if err := w.setFileTest(ctx, r.file.Path, r.test); err != nil {
+				return Summary{}, err
+			}
 		}
-
-		chunks := parse.ChunksForFile(rel, string(data))
-		if len(chunks) == 0 {
+		if r.unchanged {
 			continue
 		}
-		var chunkRecords []metadata.ChunkRecord
-		var termRecords []metadata.TermRecord
-		for _, c := range chunks {
-			ch := c
-			ch.FilePath = rel
-			chunkHash := hash.ChunkHash(fileHash, ch.StartLine, ch.EndLine, ch.Text)
-			chunkID := chunkHash
-			chunkRecords = append(chunkRecords, metadata.ChunkRecord{
-				ID:        chunkID,
-				FilePath:  rel,
-				StartLine: ch.StartLine,
-				EndLine:   ch.EndLine,
-				Hash:      chunkHash,
-				Content:   ch.Text,
-			})
-			postings := lex.Add(chunkID, ch.Text)
-			for _, p := range postings {
-				termRecords = append(termRecords, metadata.TermRecord{Term: p.Term, ChunkID: p.ChunkID, TF: p.TF})
+		if r.touch {
+			if err := w.touchFile(ctx, r.file); err != nil {
+				return Summary{}, err
 			}
+			continue
 		}
-
-		fr := metadata.FileRecord{
-			Path:  rel,
-			Hash:  fileHash,
-			MTime: f.Info.ModTime().Unix(),
-			Size:  f.Info.Size(),
+		if r.racy {
+			summary.FilesRacy++
+			emit(Progress{Type: "progress", Stage: "verify", File: r.file.Path, Reason: "changed_same_stat"})
+		}
+		// New files without chunks are left out; one that no longer has any
+		// is still replaced so its old chunks stop matching.
+		if _, ok := known[r.file.Path]; !ok && len(r.kept)+len(r.chunks) == 0 {
+			continue
 		}
-		if err := store.ReplaceFileData(fr, chunkRecords, termRecords); err != nil {
+		if err := w.replaceFile(ctx, r.file, r.kept, r.chunks, r.terms); err != nil {
 			return Summary{}, err
 		}
 		summary.FilesIndexed++
-		summary.ChunksIndexed += len(chunkRecords)
-		emit(Progress{Type: "progress", Stage: "index", File: rel, Chunks: len(chunkRecords)})
+		summary.ChunksIndexed += len(r.chunks)
+		summary.ChunksReused += len(r.kept)
+		emit(Progress{Type: "progress", Stage: "index", File: r.file.Path, Chunks: len(r.chunks), Reused: len(r.kept)})
+	}
+	if err := ctx.Err(); err != nil {
+		return Summary{}, err
+	}
+	if err := w.commit(ctx); err != nil {
+		return Summary{}, err
 	}
-
 	return summary, nil
 }
 
+// seedIndex returns the most recently updated index of another worktree of
+// the same repository when paths has no index yet.
+func seedIndex(paths workspace.Paths) string {
+	if _, err := os.Stat(paths.IndexDBPath); !errors.Is(err, fs.ErrNotExist) {
+		return ""
+	}
+	if siblings := workspace.SiblingIndexes(paths); len(siblings) > 0 {
+		return siblings[0]
+	}
+	return ""
+}
+
+// seed creates the index at path as a copy of sibling. Stat data recorded
+// in another worktree says nothing about this one, so every file is
+// re-hashed, by this run or, if it does not finish, the next.
+func seed(ctx context.Context, sibling, path string) error {
+	tmp := path + ".seed"
+	_ = os.Remove(tmp)
+	defer os.Remove(tmp)
+	if err := metadata.Copy(ctx, sibling, tmp); err != nil {
+		return err
+	}
+	db, err := metadata.Open(ctx, tmp)
+	if err != nil {
+		return err
+	}
+	if err := db.ForgetStat(ctx); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// rebuildReason reports why the index must be rebuilt from scratch for opts,
+// or "" when it can be updated in place. Terms from two analyzers cannot be
+// searched together, and files whose size and mtime are unchanged are not
+// rechunked, so either setting changing needs every file read again.
+func rebuildReason(ctx context.Context, store *metadata.DB, opts Options) (string, error) {
+	built, _, err := store.Setting(ctx, AnalyzerSetting)
+	if err != nil {
+		return "", err
+	}
+	if built != opts.Analyzer.Fingerprint() {
+		return "analyzer_changed", nil
+	}
+	chunking, ok, err := store.Setting(ctx, ChunkingSetting)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		chunking = chunkingSetting(0)
+	}
+	if chunking != chunkingSetting(opts.MaxChunkLines) {
+		return "chunking_changed", nil
+	}
+	return "", nil
+}
+
+func chunkingSetting(maxLines int) string {
+	return "max_lines=" + strconv.Itoa(maxLines)
+}
+
+// writer applies index changes to the live database in a single
+// transaction, passing them on in batches of opts.BatchSize files. The
+// transaction starts with the first change, so runs without changes do not
+// write.
+type writer struct {
+	path  string
+	clean bool
+	size  int
+	// analyzer and chunking are the settings recorded in the index on
+	// commit.
+	analyzer string
+	chunking string
+	tx       *metadata.Tx
+	batch    *metadata.Batch
+}
+
+func newWriter(path string, clean bool, opts Options) *writer {
+	size := opts.BatchSize
+	if size <= 0 {
+		size = DefaultBatchSize
+	}
+	return &writer{path: path, clean: clean, size: size, analyzer: opts.Analyzer.Fingerprint(), chunking: chunkingSetting(opts.MaxChunkLines)}
+}
+
+func (w *writer) open(ctx context.Context) error {
+	if w.tx != nil {
+		return nil
+	}
+	db, err := metadata.Open(ctx, w.path)
+	if err != nil && w.clean {
+		// A clean run replaces an index it cannot read.
+		if rmErr := removeIndex(w.path); rmErr != nil {
+			return rmErr
+		}
+		db, err = metadata.Open(ctx, w.path)
+	}
+	if err != nil {
+		return err
+	}
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	w.tx = tx
+	w.batch = tx.Batch()
+	if w.clean {
+		w.batch.Clear()
+	}
+	return nil
+}
+
+func removeIndex(path string) error {
+	for _, p := range []string{path, path + "-wal", path + "-shm", path + "-journal"} {
+		if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *writer) deleteFile(ctx context.Context, path string) error {
+	if err := w.open(ctx); err != nil {
+		return err
+	}
+	w.batch.DeleteFile(path)
+	return w.flushFull(ctx)
+}
+
+func (w *writer) replaceFile(ctx context.Context, fr metadata.FileRecord, kept, chunks []metadata.ChunkRecord, terms []metadata.TermRecord) error {
+	if err := w.open(ctx); err != nil {
+		return err
+	}
+	w.batch.ReplaceFileChunks(fr, kept, chunks, terms)
+	return w.flushFull(ctx)
+}
+
+func (w *writer) touchFile(ctx context.Context, fr metadata.FileRecord) error {
+	if err := w.open(ctx); err != nil {
+		return err
+	}
+	w.batch.TouchFile(fr)
+	return w.flushFull(ctx)
+}
+
+func (w *writer) setFileTest(ctx context.Context, path string, test bool) error {
+	if err := w.open(ctx); err != nil {
+		return err
+	}
+	w.batch.SetFileTest(path, test)
+	return w.flushFull(ctx)
+}
+
+func (w *writer) flushFull(ctx context.Context) error {
+	if w.batch.Len() < w.size {
+		return nil
+	}
+	return w.batch.Commit(ctx)
+}
+
+// commit flushes the last batch and commits the transaction. A clean run
+// always commits, even when nothing was written, to clear the index.
+func (w *writer) commit(ctx context.Context) error {
+	if w.clean {
+		if err := w.open(ctx); err != nil {
+			return err
+		}
+	}
+	if w.tx == nil {
+		return nil
+	}
+	w.batch.SetSetting(AnalyzerSetting, w.analyzer)
+	w.batch.SetSetting(ChunkingSetting, w.chunking)
+	if err := w.batch.Commit(ctx); err != nil {
+		return err
+	}
+	if err := w.tx.Commit(ctx); err != nil {
+		return err
+	}
+	w.tx = nil
+	return nil
+}
+
+// abort rolls back an uncommitted transaction, if any.
+func (w *writer) abort() {
+	if w.tx != nil {
+		w.tx.Rollback()
+	}
+}
+
+// prepared is a file ready to be written, or the reason it is not.
+type prepared struct {
+	file metadata.FileRecord
+	// kept are chunks already stored; chunks are new and tokenized.
+	kept   []metadata.ChunkRecord
+	chunks []metadata.ChunkRecord
+	terms  []metadata.TermRecord
+	// touch marks unchanged content whose stat data needs updating.
+	touch     bool
+	unchanged bool
+	// retest marks unchanged content whose chunks need their test flag set
+	// to test.
+	retest bool
+	test   bool
+	// racy marks content that changed although size and mtime did not.
+	racy bool
+	err  error
+}
+
+type task struct {
+	file scan.File
+	out  chan prepared
+}
+
+// prepareAll fans files out to opts.Jobs workers. Results arrive on the
+// returned channel in file order, one single-use channel per file, and at
+// most a few per worker are in flight. wait blocks until every worker has
+// exited.
+func prepareAll(ctx context.Context, jobs int, files []scan.File, p pass) (<-chan chan prepared, func()) {
+	if jobs <= 0 {
+		jobs = runtime.NumCPU()
+	}
+	tasks := make(chan task)
+	ordered := make(chan chan prepared, 2*jobs)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(tasks)
+		defer close(ordered)
+		for _, f := range files {
+			t := task{file: f, out: make(chan prepared, 1)}
+			select {
+			case ordered <- t.out:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case tasks <- t:
+			case <-ctx.Done():
+				t.out <- prepared{err: ctx.Err()}
+				return
+			}
+		}
+	}()
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range tasks {
+				if err := ctx.Err(); err != nil {
+					t.out <- prepared{err: err}
+					continue
+				}
+				t.out <- p.prepare(ctx, t.file)
+			}
+		}()
+	}
+	return ordered, wg.Wait
+}
+
+// pass is the state shared by the workers of one run.
+type pass struct {
+	root string
+	// store is the live index, nil for clean runs.
+	store *metadata.DB
+	known map[string]metadata.FileRecord
+	// tests are the indexed files flagged as tests.
+	tests  map[string]bool
+	verify bool
+	// start is when the run began; files modified since may change again
+	// without their stat data changing.
+	start    time.Time
+	maxLines int
+	analyzer lexical.Analyzer
+}
+
+// prepare reads, hashes, parses and tokenizes one file. Files whose size
+// and modification time match the index are not read unless p.verify is
+// set.
+func (p pass) prepare(ctx context.Context, f scan.File) prepared {
+	rel := relPath(p.root, f.Path)
+	rec, ok := p.known[rel]
+	statMatch := ok && rec.MTime == f.Info.ModTime().UnixNano() && rec.Size == f.Info.Size()
+	retest := ok && p.tests[rel] != f.Test
+	if statMatch && !p.verify {
+		return prepared{file: metadata.FileRecord{Path: rel}, unchanged: true, retest: retest, test: f.Test}
+	}
+	data, err := os.ReadFile(f.Path)
+	if err != nil {
+		return prepared{err: err}
+	}
+	fileHash := hash.FileHash(data)
+	fr := metadata.FileRecord{
+		Path:  rel,
+		Hash:  fileHash,
+		MTime: p.stamp(f.Info),
+		Size:  f.Info.Size(),
+	}
+	if ok && rec.Hash == fileHash {
+		if rec.MTime == fr.MTime && rec.Size == fr.Size {
+			return prepared{file: fr, unchanged: true, retest: retest, test: f.Test}
+		}
+		return prepared{file: fr, touch: true, retest: retest, test: f.Test}
+	}
+
+	stored := map[string]bool{}
+	if ok && p.store != nil {
+		ids, err := p.store.FileChunkIDs(ctx, rel)
+		if err != nil {
+			return prepared{err: err}
+		}
+		for _, id := range ids {
+			stored[id] = true
+		}
+	}
+
+	chunks := parse.SplitLong(parse.ChunksForFile(rel, string(data)), p.maxLines)
+	lex := lexical.New()
+	seen := map[string]int{}
+	var kept, chunkRecords []metadata.ChunkRecord
+	var termRecords []metadata.TermRecord
+	for _, ch := range chunks {
+		// Repeated identical chunks are told apart by occurrence.
+		key := ch.Symbol + "\x00" + ch.Text
+		symbol := ch.Symbol
+		if n := seen[key]; n > 0 {
+			symbol = fmt.Sprintf("%s#%d", ch.Symbol, n+1)
+		}
+		seen[key]++
+		record := metadata.ChunkRecord{
+			ID:        hash.ChunkID(rel, symbol, ch.Text),
+			FilePath:  rel,
+			StartLine: ch.StartLine,
+			EndLine:   ch.EndLine,
+			Hash:      hash.ChunkHash(ch.Text),
+			Content:   ch.Text,
+			Test:      f.Test,
+			Lang:      ch.Lang,
+		}
+		if stored[record.ID] {
+			kept = append(kept, record)
+			continue
+		}
+		chunkRecords = append(chunkRecords, record)
+		lex.Analyzer = p.analyzer.ForLang(ch.Lang)
+		postings := lex.Add(record.ID, ch.Text)
+		sort.Slice(postings, func(i, j int) bool { return postings[i].Term < postings[j].Term })
+		for _, p := range postings {
+			termRecords = append(termRecords, metadata.TermRecord{Term: p.Term, ChunkID: p.ChunkID, TF: p.TF})
+		}
+	}
+
+	return prepared{file: fr, kept: kept, chunks: chunkRecords, terms: termRecords, racy: statMatch}
+}
+
+// stamp is the modification time recorded for a file. Like git, files
+// modified in the same second the run started are recorded with a zero
+// time so the next run re-hashes them: a later write in that second could
+// leave both size and mtime unchanged.
+func (p pass) stamp(info os.FileInfo) int64 {
+	if !info.ModTime().Before(p.start.Truncate(time.Second)) {
+		return 0
+	}
+	return info.ModTime().UnixNano()
+}
+
+// underAny reports whether rel is one of paths or lies below one of them.
+func underAny(rel string, paths []string) bool {
+	for _, p := range paths {
+		p = strings.Trim(filepath.ToSlash(filepath.Clean(p)), "/")
+		if p == "" || p == "." || rel == p || strings.HasPrefix(rel, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func relPath(root, path string) string {
+	rel, _ := filepath.Rel(root, path)
+	return filepath.ToSlash(rel)
+}
+
 func filterSupported(files []scan.File) []scan.File {
 	var out []scan.File
 	for _, f := range files {
-		ext := strings.ToLower(filepath.Ext(f.Path))
-		switch ext {
-		case ".go", ".md", ".markdown":
+		if supported(f.Path) {
 			out = append(out, f)
 		}
 	}
@@ -155,4 +648,20 @@ func filterSupported(files []scan.File) []scan.File {
 	return out
 }
 
-// Placeholder to avoid unused import for time in future progress timestamps.
+func supported(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".go", ".md", ".markdown":
+		return true
+	}
+	return false
+}
+
+// reportSkip limits skip progress to directories and files that would
+// otherwise have been indexed.
+func reportSkip(sk scan.Skipped) bool {
+	switch sk.Reason {
+	case scan.SkipSubmodule, scan.SkipNestedRepo, scan.SkipSymlinkCycle:
+		return true
+	}
+	return supported(sk.Path)
+}
@@ -1,12 +1,21 @@
 package indexer
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
+	"scry/pkg/ignore"
+	"scry/pkg/index/lexical"
 	"scry/pkg/metadata"
+	"scry/pkg/scan"
 	"scry/pkg/workspace"
 )
 
@@ -24,7 +33,7 @@ func TestRunErrorsOnMissingRoot(t *testing.T) {
 	if err := os.WriteFile(rootFile, []byte("not a dir"), 0o644); err != nil {
 		t.Fatalf("write root file: %v", err)
 	}
-	_, err := Run(Options{Root: rootFile}, func(Progress) {})
+	_, err := Run(context.Background(), Options{Root: rootFile}, func(Progress) {})
 	if err == nil {
 		t.Fatalf("expected error for missing root")
 	}
@@ -34,7 +43,7 @@ func TestRunEmptyRepo(t *testing.T) {
 	requireSQLite(t)
 	root := t.TempDir()
 	var stages []string
-	summary, err := Run(Options{Root: root}, func(p Progress) {
+	summary, err := Run(context.Background(), Options{Root: root}, func(p Progress) {
 		if p.Type == "progress" {
 			stages = append(stages, p.Stage)
 		}
@@ -64,7 +73,7 @@ func TestRunIncrementalAndDelete(t *testing.T) {
 		t.Fatalf("write b.md: %v", err)
 	}
 
-	summary, err := Run(Options{Root: root}, func(Progress) {})
+	summary, err := Run(context.Background(), Options{Root: root}, func(Progress) {})
 	if err != nil {
 		t.Fatalf("run: %v", err)
 	}
@@ -72,7 +81,7 @@ func TestRunIncrementalAndDelete(t *testing.T) {
 		t.Fatalf("unexpected summary: %+v", summary)
 	}
 
-	summary, err = Run(Options{Root: root}, func(Progress) {})
+	summary, err = Run(context.Background(), Options{Root: root}, func(Progress) {})
 	if err != nil {
 		t.Fatalf("run again: %v", err)
 	}
@@ -83,7 +92,7 @@ func TestRunIncrementalAndDelete(t *testing.T) {
 	if err := os.Remove(filepath.Join(root, "a.go")); err != nil {
 		t.Fatalf("remove: %v", err)
 	}
-	summary, err = Run(Options{Root: root}, func(Progress) {})
+	summary, err = Run(context.Background(), Options{Root: root}, func(Progress) {})
 	if err != nil {
 		t.Fatalf("run after delete: %v", err)
 	}
@@ -91,11 +100,11 @@ func TestRunIncrementalAndDelete(t *testing.T) {
 		t.Fatalf("expected no new files indexed after delete, got %+v", summary)
 	}
 
-	store, err := metadata.Open(filepath.Join(root, ".scry", "index.db"))
+	store, err := metadata.Open(context.Background(), filepath.Join(root, ".scry", "index.db"))
 	if err != nil {
 		t.Fatalf("open db: %v", err)
 	}
-	files, err := store.ListFiles()
+	files, err := store.ListFiles(context.Background())
 	if err != nil {
 		t.Fatalf("list files: %v", err)
 	}
@@ -104,6 +113,42 @@ func TestRunIncrementalAndDelete(t *testing.T) {
 	}
 }
 
+func TestRunEmptiedFileDropsChunks(t *testing.T) {
+	requireSQLite(t)
+	root := t.TempDir()
+	path := filepath.Join(root, "a.md")
+	if err := os.WriteFile(path, []byte("# Title\nUniqueword here\n"), 0o644); err != nil {
+		t.Fatalf("write a.md: %v", err)
+	}
+	if _, err := Run(context.Background(), Options{Root: root}, func(Progress) {}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	store, err := metadata.Open(context.Background(), workspace.Resolve(root).IndexDBPath)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if hits, err := store.TermHits(context.Background(), "uniqueword"); err != nil || len(hits) != 1 {
+		t.Fatalf("expected the chunk indexed, got %v err=%v", hits, err)
+	}
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatalf("empty a.md: %v", err)
+	}
+	summary, err := Run(context.Background(), Options{Root: root}, func(Progress) {})
+	if err != nil {
+		t.Fatalf("run after emptying: %v", err)
+	}
+	if summary.FilesIndexed != 1 || summary.ChunksIndexed != 0 {
+		t.Fatalf("expected the emptied file reindexed, got %+v", summary)
+	}
+	if hits, err := store.TermHits(context.Background(), "uniqueword"); err != nil || len(hits) != 0 {
+		t.Fatalf("expected old chunks removed, got %v err=%v", hits, err)
+	}
+	summary, err = Run(context.Background(), Options{Root: root}, func(Progress) {})
+	if err != nil || summary.FilesIndexed != 0 {
+		t.Fatalf("expected the empty file left alone, got %+v err=%v", summary, err)
+	}
+}
+
 func TestRunCleanAndSkipEmptyChunks(t *testing.T) {
 	requireSQLite(t)
 	root := t.TempDir()
@@ -121,7 +166,7 @@ func TestRunCleanAndSkipEmptyChunks(t *testing.T) {
 		t.Fatalf("write ok.go: %v", err)
 	}
 
-	summary, err := Run(Options{Root: root, Clean: true}, func(Progress) {})
+	summary, err := Run(context.Background(), Options{Root: root, Clean: true}, func(Progress) {})
 	if err != nil {
 		t.Fatalf("run: %v", err)
 	}
@@ -141,7 +186,7 @@ func TestRunReadFileError(t *testing.T) {
 		_ = os.Chmod(path, 0o644)
 	})
 
-	_, err := Run(Options{Root: root}, func(Progress) {})
+	_, err := Run(context.Background(), Options{Root: root}, func(Progress) {})
 	if err == nil {
 		t.Fatalf("expected read error")
 	}
@@ -160,8 +205,707 @@ func TestRunMissingSQLite(t *testing.T) {
 		_ = os.Setenv("PATH", orig)
 	})
 
-	_, err := Run(Options{Root: root}, func(Progress) {})
+	_, err := Run(context.Background(), Options{Root: root}, func(Progress) {})
 	if err == nil {
 		t.Fatalf("expected sqlite3 missing error")
 	}
 }
+
+func TestRunFlagsTestChunksWhenIncluded(t *testing.T) {
+	requireSQLite(t)
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "a.go"), []byte("package main\n\nfunc A() {}\n"), 0o644); err != nil {
+		t.Fatalf("write a.go: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "a_test.go"), []byte("package main\n\nfunc TestA() {}\n"), 0o644); err != nil {
+		t.Fatalf("write a_test.go: %v", err)
+	}
+
+	summary, err := Run(context.Background(), Options{Root: root}, func(Progress) {})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if summary.FilesIndexed != 1 {
+		t.Fatalf("expected tests excluded by default, got %+v", summary)
+	}
+
+	opts := Options{Root: root, Scan: scan.Options{Ignore: ignore.Options{IncludeTests: true}}}
+	summary, err = Run(context.Background(), opts, func(Progress) {})
+	if err != nil {
+		t.Fatalf("run with tests: %v", err)
+	}
+	if summary.FilesIndexed != 1 {
+		t.Fatalf("expected only the test file to be newly indexed, got %+v", summary)
+	}
+	store, err := metadata.Open(context.Background(), workspace.Resolve(root).IndexDBPath)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	hits, err := store.TermHits(context.Background(), "testa")
+	if err != nil || len(hits) != 1 {
+		t.Fatalf("expected test chunk term hit, got %v err=%v", hits, err)
+	}
+	view, ok, err := store.GetChunk(context.Background(), hits[0].ChunkID)
+	if err != nil || !ok || !view.Test {
+		t.Fatalf("expected flagged test chunk, got %+v ok=%v err=%v", view, ok, err)
+	}
+}
+
+func TestRunReflagsTestsWhenPatternsChange(t *testing.T) {
+	requireSQLite(t)
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "spec"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "spec", "a.go"), []byte("package spec\n\nfunc CheckA() {}\n"), 0o644); err != nil {
+		t.Fatalf("write spec/a.go: %v", err)
+	}
+	isTest := func() bool {
+		t.Helper()
+		store, err := metadata.Open(context.Background(), workspace.Resolve(root).IndexDBPath)
+		if err != nil {
+			t.Fatalf("open db: %v", err)
+		}
+		hits, err := store.TermHits(context.Background(), "checka")
+		if err != nil || len(hits) != 1 {
+			t.Fatalf("expected one term hit, got %v err=%v", hits, err)
+		}
+		view, _, err := store.GetChunk(context.Background(), hits[0].ChunkID)
+		if err != nil {
+			t.Fatalf("get chunk: %v", err)
+		}
+		return view.Test
+	}
+
+	opts := Options{Root: root, Scan: scan.Options{Ignore: ignore.Options{IncludeTests: true}}}
+	if _, err := Run(context.Background(), opts, func(Progress) {}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if isTest() {
+		t.Fatalf("expected spec/a.go not flagged by the default patterns")
+	}
+
+	// The file is unchanged, so only its flag is updated.
+	opts.Scan.Ignore.TestPatterns = []string{"spec/"}
+	summary, err := Run(context.Background(), opts, func(Progress) {})
+	if err != nil {
+		t.Fatalf("run with patterns: %v", err)
+	}
+	if summary.FilesIndexed != 0 || !isTest() {
+		t.Fatalf("expected spec/a.go flagged without reindexing, got %+v test=%v", summary, isTest())
+	}
+
+	opts.Scan.Ignore.TestPatterns = nil
+	if _, err := Run(context.Background(), opts, func(Progress) {}); err != nil {
+		t.Fatalf("run without patterns: %v", err)
+	}
+	if isTest() {
+		t.Fatalf("expected spec/a.go unflagged")
+	}
+}
+
+func TestRunReportsSkippedFiles(t *testing.T) {
+	requireSQLite(t)
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "big.go"), make([]byte, 4096), 0o644); err != nil {
+		t.Fatalf("write big.go: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "big.bin"), make([]byte, 4096), 0o644); err != nil {
+		t.Fatalf("write big.bin: %v", err)
+	}
+	var skips []Progress
+	opts := Options{Root: root, Scan: scan.Options{MaxFileSize: 1024}}
+	if _, err := Run(context.Background(), opts, func(p Progress) {
+		if p.Stage == "skip" {
+			skips = append(skips, p)
+		}
+	}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if len(skips) != 1 || skips[0].File != "big.go" || skips[0].Reason != scan.SkipTooLarge {
+		t.Fatalf("expected one too_large skip for big.go, got %+v", skips)
+	}
+}
+
+func writeGoFiles(t *testing.T, root string, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		dir := filepath.Join(root, fmt.Sprintf("pkg%d", i%4))
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		src := fmt.Sprintf("package p\n\nfunc F%d() int { return %d }\n\nfunc G%d() {}\n", i, i, i)
+		path := filepath.Join(dir, fmt.Sprintf("f%d.go", i))
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		// A fixed mtime keeps recorded stat data independent of timing.
+		if err := os.Chtimes(path, fixedTime, fixedTime); err != nil {
+			t.Fatalf("chtimes: %v", err)
+		}
+	}
+}
+
+var fixedTime = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func dumpIndex(t *testing.T, root string) string {
+	t.Helper()
+	out, err := exec.Command("sqlite3", workspace.Resolve(root).IndexDBPath, ".dump").CombinedOutput()
+	if err != nil {
+		t.Fatalf("dump: %v: %s", err, out)
+	}
+	return string(out)
+}
+
+func TestRunDeterministicAcrossJobs(t *testing.T) {
+	requireSQLite(t)
+	root := t.TempDir()
+	writeGoFiles(t, root, 24)
+
+	var runs [][]string
+	var dumps []string
+	for _, jobs := range []int{1, 8} {
+		var order []string
+		opts := Options{Root: root, Clean: true, Jobs: jobs}
+		summary, err := Run(context.Background(), opts, func(p Progress) {
+			if p.Stage == "index" {
+				order = append(order, p.File)
+			}
+		})
+		if err != nil {
+			t.Fatalf("run with %d jobs: %v", jobs, err)
+		}
+		if summary.FilesIndexed != 24 {
+			t.Fatalf("unexpected summary with %d jobs: %+v", jobs, summary)
+		}
+		runs = append(runs, order)
+		dumps = append(dumps, dumpIndex(t, root))
+	}
+	if strings.Join(runs[0], ",") != strings.Join(runs[1], ",") {
+		t.Fatalf("progress order differs:\n%v\n%v", runs[0], runs[1])
+	}
+	if !sort.StringsAreSorted(runs[0]) {
+		t.Fatalf("expected files indexed in path order, got %v", runs[0])
+	}
+	if dumps[0] != dumps[1] {
+		t.Fatalf("index contents differ between job counts")
+	}
+}
+
+func TestRunCancelKeepsPreviousIndex(t *testing.T) {
+	requireSQLite(t)
+	root := t.TempDir()
+	writeGoFiles(t, root, 12)
+	if _, err := Run(context.Background(), Options{Root: root}, func(Progress) {}); err != nil {
+		t.Fatalf("initial run: %v", err)
+	}
+	before := dumpIndex(t, root)
+
+	if err := os.RemoveAll(filepath.Join(root, "pkg0")); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	for i := 1; i < 12; i += 4 {
+		path := filepath.Join(root, "pkg1", fmt.Sprintf("f%d.go", i))
+		src := fmt.Sprintf("package p\n\nfunc Changed%d() {}\n", i)
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	indexed := 0
+	_, err := Run(ctx, Options{Root: root, Jobs: 4, BatchSize: 1}, func(p Progress) {
+		if p.Stage == "index" {
+			indexed++
+			cancel()
+		}
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if indexed != 1 {
+		t.Fatalf("expected indexing to stop after cancel, indexed %d", indexed)
+	}
+	if after := dumpIndex(t, root); after != before {
+		t.Fatalf("expected cancelled run to leave the previous index")
+	}
+
+	summary, err := Run(context.Background(), Options{Root: root, BatchSize: 2}, func(Progress) {})
+	if err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	if summary.FilesIndexed != 3 {
+		t.Fatalf("expected changed files indexed on resume, got %+v", summary)
+	}
+	store, err := metadata.Open(context.Background(), workspace.Resolve(root).IndexDBPath)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	files, err := store.ListFiles(context.Background())
+	if err != nil || len(files) != 9 {
+		t.Fatalf("expected deleted files removed, got %v err=%v", files, err)
+	}
+}
+
+func TestRunUpdatesIndexInPlace(t *testing.T) {
+	requireSQLite(t)
+	root := t.TempDir()
+	writeGoFiles(t, root, 4)
+	if _, err := Run(context.Background(), Options{Root: root}, func(Progress) {}); err != nil {
+		t.Fatalf("initial run: %v", err)
+	}
+	dbPath := workspace.Resolve(root).IndexDBPath
+	before, err := os.Stat(dbPath)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "pkg0", "f0.go"), []byte("package p\n\nfunc Edited() {}\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	summary, err := Run(context.Background(), Options{Root: root}, func(Progress) {})
+	if err != nil || summary.FilesIndexed != 1 {
+		t.Fatalf("expected one file reindexed, got %+v err=%v", summary, err)
+	}
+	after, err := os.Stat(dbPath)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if !os.SameFile(before, after) {
+		t.Fatalf("expected the index updated in place, not replaced")
+	}
+}
+
+func TestRunBatchSizeDoesNotChangeIndex(t *testing.T) {
+	requireSQLite(t)
+	root := t.TempDir()
+	writeGoFiles(t, root, 10)
+	var dumps []string
+	for _, size := range []int{1, 3, 0} {
+		if _, err := Run(context.Background(), Options{Root: root, Clean: true, BatchSize: size}, func(Progress) {}); err != nil {
+			t.Fatalf("run with batch size %d: %v", size, err)
+		}
+		dumps = append(dumps, dumpIndex(t, root))
+	}
+	if dumps[0] != dumps[1] || dumps[1] != dumps[2] {
+		t.Fatalf("index contents differ between batch sizes")
+	}
+}
+
+func TestRunStatFastPathAndVerify(t *testing.T) {
+	requireSQLite(t)
+	root := t.TempDir()
+	path := filepath.Join(root, "a.go")
+	old := time.Now().Add(-time.Hour)
+	write := func(src string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		if err := os.Chtimes(path, old, old); err != nil {
+			t.Fatalf("chtimes: %v", err)
+		}
+	}
+	write("package p\n\nfunc Alpha() {}\n")
+	if _, err := Run(context.Background(), Options{Root: root}, func(Progress) {}); err != nil {
+		t.Fatalf("initial run: %v", err)
+	}
+
+	// Same size and mtime: the fast path does not notice the edit.
+	write("package p\n\nfunc Omega() {}\n")
+	summary, err := Run(context.Background(), Options{Root: root}, func(Progress) {})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if summary.FilesIndexed != 0 {
+		t.Fatalf("expected stat fast path to skip the file, got %+v", summary)
+	}
+
+	var reasons []string
+	summary, err = Run(context.Background(), Options{Root: root, Verify: true}, func(p Progress) {
+		if p.Stage == "verify" {
+			reasons = append(reasons, p.File+":"+p.Reason)
+		}
+	})
+	if err != nil {
+		t.Fatalf("verify run: %v", err)
+	}
+	if summary.FilesIndexed != 1 || summary.FilesRacy != 1 {
+		t.Fatalf("expected verify to reindex the racy file, got %+v", summary)
+	}
+	if strings.Join(reasons, ",") != "a.go:changed_same_stat" {
+		t.Fatalf("unexpected verify events: %v", reasons)
+	}
+}
+
+func TestRunRehashesRacilyCleanFiles(t *testing.T) {
+	requireSQLite(t)
+	root := t.TempDir()
+	path := filepath.Join(root, "a.go")
+	if err := os.WriteFile(path, []byte("package p\n\nfunc Alpha() {}\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if _, err := Run(context.Background(), Options{Root: root}, func(Progress) {}); err != nil {
+		t.Fatalf("initial run: %v", err)
+	}
+	store, err := metadata.Open(context.Background(), workspace.Resolve(root).IndexDBPath)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	rec, ok, err := store.GetFile(context.Background(), "a.go")
+	if err != nil || !ok || rec.MTime != 0 {
+		t.Fatalf("expected file modified during the run's second to be smudged, got %+v ok=%v err=%v", rec, ok, err)
+	}
+
+	// A same-size edit that keeps the mtime is still caught.
+	if err := os.WriteFile(path, []byte("package p\n\nfunc Omega() {}\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := os.Chtimes(path, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	summary, err := Run(context.Background(), Options{Root: root}, func(Progress) {})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if summary.FilesIndexed != 1 {
+		t.Fatalf("expected racily clean file reindexed, got %+v", summary)
+	}
+
+	// Once the mtime is old enough, the stat data is recorded without
+	// reindexing.
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	summary, err = Run(context.Background(), Options{Root: root}, func(Progress) {})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if summary.FilesIndexed != 0 {
+		t.Fatalf("expected unchanged content not reindexed, got %+v", summary)
+	}
+	rec, _, err = store.GetFile(context.Background(), "a.go")
+	if err != nil || rec.MTime != old.UnixNano() {
+		t.Fatalf("expected stat data refreshed, got %+v err=%v", rec, err)
+	}
+}
+
+func TestRunReusesUnchangedChunks(t *testing.T) {
+	requireSQLite(t)
+	root := t.TempDir()
+	path := filepath.Join(root, "a.go")
+	src := "package p\n\nfunc Alpha() {}\n\nfunc Beta() { gamma() }\n\nfunc Delta() {}\n"
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err := Run(context.Background(), Options{Root: root}, func(Progress) {}); err != nil {
+		t.Fatalf("initial run: %v", err)
+	}
+	store, err := metadata.Open(context.Background(), workspace.Resolve(root).IndexDBPath)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	alpha, err := store.TermHits(context.Background(), "alpha")
+	if err != nil || len(alpha) != 1 {
+		t.Fatalf("expected alpha hit, got %v err=%v", alpha, err)
+	}
+
+	// Shift everything down a line and rewrite Beta.
+	src = "package p\n\n// doc\nfunc Alpha() {}\n\nfunc Beta() { epsilon() }\n\nfunc Delta() {}\n"
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	var events []Progress
+	summary, err := Run(context.Background(), Options{Root: root, Verify: true}, func(p Progress) {
+		if p.Stage == "index" {
+			events = append(events, p)
+		}
+	})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if summary.FilesIndexed != 1 || summary.ChunksIndexed != 1 || summary.ChunksReused != 2 {
+		t.Fatalf("expected Beta rebuilt and the other chunks reused, got %+v", summary)
+	}
+	if len(events) != 1 || events[0].Chunks != 1 || events[0].Reused != 2 {
+		t.Fatalf("unexpected progress: %+v", events)
+	}
+	for term, want := range map[string]int{"gamma": 0, "epsilon": 1, "delta": 1, "alpha": 1} {
+		hits, err := store.TermHits(context.Background(), term)
+		if err != nil || len(hits) != want {
+			t.Fatalf("expected %d hits for %s, got %v err=%v", want, term, hits, err)
+		}
+	}
+	delta, err := store.TermHits(context.Background(), "delta")
+	if err != nil {
+		t.Fatalf("term hits: %v", err)
+	}
+	view, ok, err := store.GetChunk(context.Background(), delta[0].ChunkID)
+	if err != nil || !ok || view.StartLine != 8 || view.EndLine != 8 {
+		t.Fatalf("expected reused chunk moved to line 8, got %+v ok=%v err=%v", view, ok, err)
+	}
+	stats, err := store.Stats(context.Background())
+	if err != nil || stats.Chunks != 3 {
+		t.Fatalf("expected stale chunks removed, got %+v err=%v", stats, err)
+	}
+}
+
+func TestRunIndexesIdenticalChunks(t *testing.T) {
+	requireSQLite(t)
+	root := t.TempDir()
+	src := "# Notes\n\nsame\n\n# Notes\n\nsame\n"
+	for _, name := range []string{"a.md", "b.md"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(src), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	summary, err := Run(context.Background(), Options{Root: root}, func(Progress) {})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if summary.FilesIndexed != 2 || summary.ChunksIndexed != 4 {
+		t.Fatalf("expected identical chunks indexed separately, got %+v", summary)
+	}
+}
+
+func TestRunPaths(t *testing.T) {
+	requireSQLite(t)
+	root := t.TempDir()
+	writeGoFiles(t, root, 8)
+	if _, err := Run(context.Background(), Options{Root: root}, func(Progress) {}); err != nil {
+		t.Fatalf("initial run: %v", err)
+	}
+
+	// pkg0 is edited and pkg1 loses a file, but only pkg1 is listed.
+	if err := os.WriteFile(filepath.Join(root, "pkg0", "f0.go"), []byte("package p\n\nfunc Zeta() {}\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := os.Remove(filepath.Join(root, "pkg1", "f1.go")); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "pkg1", "new.go"), []byte("package p\n\nfunc Eta() {}\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	var indexed []string
+	summary, err := Run(context.Background(), Options{Root: root, Paths: []string{"pkg1"}}, func(p Progress) {
+		if p.Stage == "index" {
+			indexed = append(indexed, p.File)
+		}
+	})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if summary.FilesIndexed != 1 || strings.Join(indexed, ",") != "pkg1/new.go" {
+		t.Fatalf("expected only pkg1 changes indexed, got %+v %v", summary, indexed)
+	}
+	store, err := metadata.Open(context.Background(), workspace.Resolve(root).IndexDBPath)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	files, err := store.ListFiles(context.Background())
+	if err != nil {
+		t.Fatalf("list files: %v", err)
+	}
+	sort.Strings(files)
+	if got := strings.Join(files, ","); got != "pkg0/f0.go,pkg0/f4.go,pkg1/f5.go,pkg1/new.go,pkg2/f2.go,pkg2/f6.go,pkg3/f3.go,pkg3/f7.go" {
+		t.Fatalf("unexpected indexed files: %s", got)
+	}
+	if hits, _ := store.TermHits(context.Background(), "zeta"); len(hits) != 0 {
+		t.Fatalf("expected files outside Paths left alone")
+	}
+}
+
+func TestRunRebuildsWhenAnalyzerChanges(t *testing.T) {
+	requireSQLite(t)
+	root := t.TempDir()
+	writeGoFiles(t, root, 4)
+	if _, err := Run(context.Background(), Options{Root: root}, func(Progress) {}); err != nil {
+		t.Fatalf("initial run: %v", err)
+	}
+
+	// Even a partial run covers every file once the analyzer changes.
+	analyzer := lexical.Analyzer{Stopwords: map[string]bool{"int": true}}
+	rebuilt := false
+	summary, err := Run(context.Background(), Options{Root: root, Analyzer: analyzer, Paths: []string{"pkg0"}}, func(p Progress) {
+		rebuilt = rebuilt || p.Stage == "rebuild"
+	})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if !rebuilt || summary.FilesIndexed != 4 {
+		t.Fatalf("expected a full rebuild, got rebuilt=%v %+v", rebuilt, summary)
+	}
+	store, err := metadata.Open(context.Background(), workspace.Resolve(root).IndexDBPath)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if hits, _ := store.TermHits(context.Background(), "int"); len(hits) != 0 {
+		t.Fatalf("expected stopword dropped from every file, got %v", hits)
+	}
+	if fp, _, err := store.Setting(context.Background(), AnalyzerSetting); err != nil || fp != analyzer.Fingerprint() {
+		t.Fatalf("expected fingerprint %q recorded, got %q (%v)", analyzer.Fingerprint(), fp, err)
+	}
+
+	rebuilt = false
+	summary, err = Run(context.Background(), Options{Root: root, Analyzer: analyzer}, func(p Progress) {
+		rebuilt = rebuilt || p.Stage == "rebuild"
+	})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if rebuilt || summary.FilesIndexed != 0 {
+		t.Fatalf("expected no rebuild with the same analyzer, got rebuilt=%v %+v", rebuilt, summary)
+	}
+}
+
+func TestRunRebuildsWhenMaxChunkLinesChanges(t *testing.T) {
+	requireSQLite(t)
+	root := t.TempDir()
+	src := "package p\n\nfunc F() int {\n\ta := 1\n\tb := 2\n\tc := 3\n\treturn a + b + c\n}\n"
+	if err := os.WriteFile(filepath.Join(root, "f.go"), []byte(src), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	chunks := func() int {
+		t.Helper()
+		store, err := metadata.Open(context.Background(), workspace.Resolve(root).IndexDBPath)
+		if err != nil {
+			t.Fatalf("open db: %v", err)
+		}
+		defer store.Close()
+		ids, err := store.FileChunkIDs(context.Background(), "f.go")
+		if err != nil {
+			t.Fatalf("chunk ids: %v", err)
+		}
+		return len(ids)
+	}
+	if _, err := Run(context.Background(), Options{Root: root}, func(Progress) {}); err != nil {
+		t.Fatalf("initial run: %v", err)
+	}
+	whole := chunks()
+
+	reason := ""
+	summary, err := Run(context.Background(), Options{Root: root, MaxChunkLines: 2}, func(p Progress) {
+		if p.Stage == "rebuild" {
+			reason = p.Reason
+		}
+	})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if reason != "chunking_changed" || summary.FilesIndexed != 1 {
+		t.Fatalf("expected a rebuild for the new max lines, got reason=%q %+v", reason, summary)
+	}
+	if split := chunks(); split <= whole {
+		t.Fatalf("expected the file rechunked into more than %d chunks, got %d", whole, split)
+	}
+
+	reason = ""
+	if summary, err = Run(context.Background(), Options{Root: root, MaxChunkLines: 2}, func(p Progress) {
+		if p.Stage == "rebuild" {
+			reason = p.Reason
+		}
+	}); err != nil || reason != "" || summary.FilesIndexed != 0 {
+		t.Fatalf("expected no rebuild with the same max lines, got reason=%q %+v, %v", reason, summary, err)
+	}
+}
+
+func TestRunHonoursIndexLock(t *testing.T) {
+	requireSQLite(t)
+	root := t.TempDir()
+	writeGoFiles(t, root, 2)
+	held, err := workspace.AcquireLock(workspace.Resolve(root))
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	if _, err := Run(context.Background(), Options{Root: root}, func(Progress) {}); !errors.Is(err, workspace.ErrLocked) {
+		t.Fatalf("expected locked error, got %v", err)
+	}
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		_ = held.Release()
+	}()
+	summary, err := Run(context.Background(), Options{Root: root, Wait: true}, func(Progress) {})
+	if err != nil {
+		t.Fatalf("run with wait: %v", err)
+	}
+	if summary.FilesIndexed != 2 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+	if _, err := os.Stat(filepath.Join(root, ".scry", "index.lock")); !os.IsNotExist(err) {
+		t.Fatalf("expected lock released after the run, got %v", err)
+	}
+}
+
+func TestRunSeedsNewWorktreeFromCache(t *testing.T) {
+	requireSQLite(t)
+	top := t.TempDir()
+	main, wt := filepath.Join(top, "proj"), filepath.Join(top, "proj-feature")
+	gitWT := filepath.Join(main, ".git", "worktrees", "feature")
+	if err := os.MkdirAll(gitWT, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(gitWT, "commondir"), []byte("../..\n"), 0o644); err != nil {
+		t.Fatalf("write commondir: %v", err)
+	}
+	writeGoFiles(t, main, 8)
+	writeGoFiles(t, wt, 8)
+	if err := os.WriteFile(filepath.Join(wt, ".git"), []byte("gitdir: "+gitWT+"\n"), 0o644); err != nil {
+		t.Fatalf("write .git: %v", err)
+	}
+	// The worktree differs in one file and lacks another.
+	if err := os.WriteFile(filepath.Join(wt, "pkg0", "f0.go"), []byte("package p\n\nfunc Feature() {}\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := os.Remove(filepath.Join(wt, "pkg1", "f1.go")); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+
+	st := workspace.Storage{Location: workspace.LocationCache, CacheDir: t.TempDir()}
+	if _, err := Run(context.Background(), Options{Root: main, Storage: st}, func(Progress) {}); err != nil {
+		t.Fatalf("index main: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(main, ".scry")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected no in-repo workspace, got %v", err)
+	}
+
+	var seed string
+	summary, err := Run(context.Background(), Options{Root: wt, Storage: st}, func(p Progress) {
+		if p.Stage == "seed" {
+			seed = p.Message
+		}
+	})
+	if err != nil {
+		t.Fatalf("index worktree: %v", err)
+	}
+	if seed != workspace.ResolveStorage(main, st).IndexDBPath {
+		t.Fatalf("expected seed from main index, got %q", seed)
+	}
+	if summary.FilesIndexed != 1 || summary.FilesDeleted != 1 {
+		t.Fatalf("expected only differences reindexed, got %+v", summary)
+	}
+
+	paths := workspace.ResolveStorage(wt, st)
+	store, err := metadata.Open(context.Background(), paths.IndexDBPath)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	files, err := store.ListFiles(context.Background())
+	if err != nil {
+		t.Fatalf("list files: %v", err)
+	}
+	if len(files) != 7 {
+		t.Fatalf("expected 7 files, got %v", files)
+	}
+	if hits, _ := store.TermHits(context.Background(), "feature"); len(hits) == 0 {
+		t.Fatalf("expected worktree content indexed")
+	}
+	if _, err := os.Stat(paths.IndexDBPath + ".seed"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected seed copy removed, got %v", err)
+	}
+}
@@ -3,6 +3,7 @@ package metadata
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -14,11 +15,15 @@ import (
 
 type DB struct {
 	Path string
+	// session is set for snapshots.
+	session *session
 }
 
 type FileRecord struct {
-	Path  string
-	Hash  string
+	Path string
+	Hash string
+	// MTime is the modification time in Unix nanoseconds. Zero marks a
+	// record that may be racily clean and must be re-hashed.
 	MTime int64
 	Size  int64
 }
@@ -30,6 +35,8 @@ type ChunkRecord struct {
 	EndLine   int
 	Hash      string
 	Content   string
+	Test      bool
+	Lang      string
 }
 
 type TermRecord struct {
@@ -44,6 +51,8 @@ type ChunkView struct {
 	StartLine int
 	EndLine   int
 	Content   string
+	Test      bool
+	Lang      string
 }
 
 type TermHit struct {
@@ -57,7 +66,7 @@ type Stats struct {
 	Terms  int
 }
 
-func Open(path string) (*DB, error) {
+func Open(ctx context.Context, path string) (*DB, error) {
 	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
 		return nil, err
 	}
@@ -65,14 +74,16 @@ func Open(path string) (*DB, error) {
 		return nil, fmt.Errorf("sqlite3 not found: %w", err)
 	}
 	db := &DB{Path: path}
-	if err := db.init(); err != nil {
+	if err := db.init(ctx); err != nil {
 		return nil, err
 	}
 	return db, nil
 }
 
-func (d *DB) init() error {
+func (d *DB) init(ctx context.Context) error {
+	// In WAL mode readers keep their snapshot while a run writes.
 	schema := `
+PRAGMA journal_mode = WAL;
 CREATE TABLE IF NOT EXISTS files (
   path TEXT PRIMARY KEY,
   hash TEXT NOT NULL,
@@ -95,12 +106,37 @@ CREATE TABLE IF NOT EXISTS terms (
 CREATE INDEX IF NOT EXISTS terms_term_idx ON terms(term);
 CREATE INDEX IF NOT EXISTS chunks_file_idx ON chunks(file_path);
 `
-	return d.runScript(schema)
+	if err := d.runScript(ctx, schema); err != nil {
+		return err
+	}
+	return d.migrate(ctx)
+}
+
+// migrations upgrade the base schema in order; PRAGMA user_version records
+// how many have been applied.
+var migrations = []string{
+	"ALTER TABLE chunks ADD COLUMN is_test INTEGER NOT NULL DEFAULT 0;",
+	"CREATE TABLE IF NOT EXISTS settings (key TEXT PRIMARY KEY, value TEXT NOT NULL);",
+	"ALTER TABLE chunks ADD COLUMN lang TEXT NOT NULL DEFAULT '';",
+}
+
+func (d *DB) migrate(ctx context.Context) error {
+	version, err := d.scalarInt(ctx, "PRAGMA user_version;")
+	if err != nil {
+		return err
+	}
+	for i := version; i < len(migrations); i++ {
+		script := fmt.Sprintf("BEGIN;\n%s\nPRAGMA user_version = %d;\nCOMMIT;\n", migrations[i], i+1)
+		if err := d.runScript(ctx, script); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (d *DB) GetFile(path string) (FileRecord, bool, error) {
+func (d *DB) GetFile(ctx context.Context, path string) (FileRecord, bool, error) {
 	query := fmt.Sprintf("SELECT path, hash, mtime, size FROM files WHERE path = %s;", sqlQuote(path))
-	out, err := d.runQuery(query)
+	out, err := d.runQuery(ctx, query)
 	if err != nil {
 		return FileRecord{}, false, err
 	}
@@ -120,8 +156,8 @@ func (d *DB) GetFile(path string) (FileRecord, bool, error) {
 	}, true, nil
 }
 
-func (d *DB) ListFiles() ([]string, error) {
-	out, err := d.runQuery("SELECT path FROM files;")
+func (d *DB) ListFiles(ctx context.Context) ([]string, error) {
+	out, err := d.runQuery(ctx, "SELECT path FROM files;")
 	if err != nil {
 		return nil, err
 	}
@@ -129,9 +165,40 @@ func (d *DB) ListFiles() ([]string, error) {
 	return lines, nil
 }
 
-func (d *DB) GetChunk(id string) (ChunkView, bool, error) {
-	query := fmt.Sprintf("SELECT id, file_path, start_line, end_line, hex(content) FROM chunks WHERE id = %s;", sqlQuote(id))
-	out, err := d.runQuery(query)
+// ListFileRecords returns every indexed file in path order.
+func (d *DB) ListFileRecords(ctx context.Context) ([]FileRecord, error) {
+	out, err := d.runQuery(ctx, "SELECT path, hash, mtime, size FROM files ORDER BY path;")
+	if err != nil {
+		return nil, err
+	}
+	var records []FileRecord
+	for _, line := range splitLines(out) {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("unexpected columns for files")
+		}
+		records = append(records, FileRecord{
+			Path:  fields[0],
+			Hash:  fields[1],
+			MTime: parseInt64(fields[2]),
+			Size:  parseInt64(fields[3]),
+		})
+	}
+	return records, nil
+}
+
+// FileChunkIDs returns the IDs of the chunks stored for path.
+func (d *DB) FileChunkIDs(ctx context.Context, path string) ([]string, error) {
+	out, err := d.runQuery(ctx, fmt.Sprintf("SELECT id FROM chunks WHERE file_path = %s;", sqlQuote(path)))
+	if err != nil {
+		return nil, err
+	}
+	return splitLines(out), nil
+}
+
+func (d *DB) GetChunk(ctx context.Context, id string) (ChunkView, bool, error) {
+	query := fmt.Sprintf("SELECT id, file_path, start_line, end_line, is_test, lang, hex(content) FROM chunks WHERE id = %s;", sqlQuote(id))
+	out, err := d.runQuery(ctx, query)
 	if err != nil {
 		return ChunkView{}, false, err
 	}
@@ -140,23 +207,14 @@ func (d *DB) GetChunk(id string) (ChunkView, bool, error) {
 		return ChunkView{}, false, nil
 	}
 	fields := strings.Split(lines[0], "\t")
-	if len(fields) != 5 {
-		return ChunkView{}, false, fmt.Errorf("unexpected columns for chunks")
-	}
-	content, err := decodeHexString(fields[4])
+	view, err := parseChunkView(fields)
 	if err != nil {
 		return ChunkView{}, false, err
 	}
-	return ChunkView{
-		ID:        fields[0],
-		FilePath:  fields[1],
-		StartLine: int(parseInt64(fields[2])),
-		EndLine:   int(parseInt64(fields[3])),
-		Content:   content,
-	}, true, nil
+	return view, true, nil
 }
 
-func (d *DB) GetChunksByIDs(ids []string) ([]ChunkView, error) {
+func (d *DB) GetChunksByIDs(ctx context.Context, ids []string) ([]ChunkView, error) {
 	if len(ids) == 0 {
 		return nil, nil
 	}
@@ -164,36 +222,45 @@ func (d *DB) GetChunksByIDs(ids []string) ([]ChunkView, error) {
 	for _, id := range ids {
 		quoted = append(quoted, sqlQuote(id))
 	}
-	query := fmt.Sprintf("SELECT id, file_path, start_line, end_line, hex(content) FROM chunks WHERE id IN (%s);", strings.Join(quoted, ","))
-	out, err := d.runQuery(query)
+	query := fmt.Sprintf("SELECT id, file_path, start_line, end_line, is_test, lang, hex(content) FROM chunks WHERE id IN (%s);", strings.Join(quoted, ","))
+	out, err := d.runQuery(ctx, query)
 	if err != nil {
 		return nil, err
 	}
 	lines := splitLines(out)
 	var chunks []ChunkView
 	for _, line := range lines {
-		fields := strings.Split(line, "\t")
-		if len(fields) != 5 {
-			return nil, fmt.Errorf("unexpected columns for chunks")
-		}
-		content, err := decodeHexString(fields[4])
+		view, err := parseChunkView(strings.Split(line, "\t"))
 		if err != nil {
 			return nil, err
 		}
-		chunks = append(chunks, ChunkView{
-			ID:        fields[0],
-			FilePath:  fields[1],
-			StartLine: int(parseInt64(fields[2])),
-			EndLine:   int(parseInt64(fields[3])),
-			Content:   content,
-		})
+		chunks = append(chunks, view)
 	}
 	return chunks, nil
 }
 
-func (d *DB) TermHits(term string) ([]TermHit, error) {
+func parseChunkView(fields []string) (ChunkView, error) {
+	if len(fields) != 7 {
+		return ChunkView{}, fmt.Errorf("unexpected columns for chunks")
+	}
+	content, err := decodeHexString(fields[6])
+	if err != nil {
+		return ChunkView{}, err
+	}
+	return ChunkView{
+		ID:        fields[0],
+		FilePath:  fields[1],
+		StartLine: int(parseInt64(fields[2])),
+		EndLine:   int(parseInt64(fields[3])),
+		Test:      parseInt64(fields[4]) != 0,
+		Lang:      fields[5],
+		Content:   content,
+	}, nil
+}
+
+func (d *DB) TermHits(ctx context.Context, term string) ([]TermHit, error) {
 	query := fmt.Sprintf("SELECT chunk_id, tf FROM terms WHERE term = %s;", sqlQuote(term))
-	out, err := d.runQuery(query)
+	out, err := d.runQuery(ctx, query)
 	if err != nil {
 		return nil, err
 	}
@@ -212,63 +279,216 @@ func (d *DB) TermHits(term string) ([]TermHit, error) {
 	return hits, nil
 }
 
-func (d *DB) Stats() (Stats, error) {
-	files, err := d.scalarInt("SELECT COUNT(*) FROM files;")
+func (d *DB) Stats(ctx context.Context) (Stats, error) {
+	files, err := d.scalarInt(ctx, "SELECT COUNT(*) FROM files;")
 	if err != nil {
 		return Stats{}, err
 	}
-	chunks, err := d.scalarInt("SELECT COUNT(*) FROM chunks;")
+	chunks, err := d.scalarInt(ctx, "SELECT COUNT(*) FROM chunks;")
 	if err != nil {
 		return Stats{}, err
 	}
-	terms, err := d.scalarInt("SELECT COUNT(*) FROM terms;")
+	terms, err := d.scalarInt(ctx, "SELECT COUNT(*) FROM terms;")
 	if err != nil {
 		return Stats{}, err
 	}
 	return Stats{Files: files, Chunks: chunks, Terms: terms}, nil
 }
 
-func (d *DB) DeleteFile(path string) error {
-	script := fmt.Sprintf(`
-BEGIN;
-DELETE FROM terms WHERE chunk_id IN (SELECT id FROM chunks WHERE file_path = %s);
-DELETE FROM chunks WHERE file_path = %s;
-DELETE FROM files WHERE path = %s;
-COMMIT;
-`, sqlQuote(path), sqlQuote(path), sqlQuote(path))
-	return d.runScript(script)
-}
-
-func (d *DB) ReplaceFileData(fr FileRecord, chunks []ChunkRecord, terms []TermRecord) error {
-	var buf bytes.Buffer
-	buf.WriteString("BEGIN;\n")
-	fmt.Fprintf(&buf, "DELETE FROM terms WHERE chunk_id IN (SELECT id FROM chunks WHERE file_path = %s);\n", sqlQuote(fr.Path))
-	fmt.Fprintf(&buf, "DELETE FROM chunks WHERE file_path = %s;\n", sqlQuote(fr.Path))
-	fmt.Fprintf(&buf, "INSERT INTO files(path, hash, mtime, size) VALUES(%s, %s, %d, %d)\n", sqlQuote(fr.Path), sqlQuote(fr.Hash), fr.MTime, fr.Size)
-	buf.WriteString("ON CONFLICT(path) DO UPDATE SET hash=excluded.hash, mtime=excluded.mtime, size=excluded.size;\n")
+// TestFiles returns the paths of files whose chunks are flagged as tests.
+func (d *DB) TestFiles(ctx context.Context) (map[string]bool, error) {
+	out, err := d.runQuery(ctx, "SELECT DISTINCT file_path FROM chunks WHERE is_test = 1;")
+	if err != nil {
+		return nil, err
+	}
+	files := map[string]bool{}
+	for _, path := range splitLines(out) {
+		files[path] = true
+	}
+	return files, nil
+}
+
+// Setting returns the value stored under key, and whether there is one.
+func (d *DB) Setting(ctx context.Context, key string) (string, bool, error) {
+	// The length column keeps the row visible when value is empty.
+	query := fmt.Sprintf("SELECT length(value), hex(value) FROM settings WHERE key = %s;", sqlQuote(key))
+	out, err := d.runQuery(ctx, query)
+	if err != nil {
+		return "", false, err
+	}
+	lines := splitLines(out)
+	if len(lines) == 0 {
+		return "", false, nil
+	}
+	_, encoded, _ := strings.Cut(lines[0], "\t")
+	value, err := decodeHexString(encoded)
+	if err != nil {
+		return "", false, err
+	}
+	return value, true, nil
+}
+
+// SetSetting stores value under key, replacing any previous value.
+func (d *DB) SetSetting(ctx context.Context, key, value string) error {
+	return d.runScript(ctx, setSetting(key, value))
+}
+
+func setSetting(key, value string) string {
+	return fmt.Sprintf("INSERT INTO settings(key, value) VALUES(%s, %s)\nON CONFLICT(key) DO UPDATE SET value=excluded.value;\n", sqlQuote(key), sqlQuote(value))
+}
+
+// ForgetStat clears the recorded modification time of every file, so the
+// next index run re-hashes them all.
+func (d *DB) ForgetStat(ctx context.Context) error {
+	return d.runScript(ctx, "UPDATE files SET mtime = 0;\n")
+}
+
+// Copy writes a consistent copy of the database at src to dst, which must
+// not exist, without changing src.
+func Copy(ctx context.Context, src, dst string) error {
+	cmd := exec.CommandContext(ctx, "sqlite3", "-readonly", src, fmt.Sprintf("VACUUM INTO %s;", sqlQuote(dst)))
+	out, err := cmd.CombinedOutput()
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	if err != nil {
+		return fmt.Errorf("sqlite3 copy: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func (d *DB) DeleteFile(ctx context.Context, path string) error {
+	b := d.Batch()
+	b.DeleteFile(path)
+	return b.Commit(ctx)
+}
+
+func (d *DB) ReplaceFileData(ctx context.Context, fr FileRecord, chunks []ChunkRecord, terms []TermRecord) error {
+	b := d.Batch()
+	b.ReplaceFileData(fr, chunks, terms)
+	return b.Commit(ctx)
+}
+
+// Batch accumulates file deletions and replacements that Commit applies in
+// a single transaction, or passes on to the Tx it belongs to.
+type Batch struct {
+	d     *DB
+	tx    *Tx
+	buf   bytes.Buffer
+	files int
+}
+
+func (d *DB) Batch() *Batch {
+	return &Batch{d: d}
+}
+
+// Len is the number of file operations queued since the last Commit.
+func (b *Batch) Len() int {
+	return b.files
+}
+
+// Clear removes every file, keeping settings.
+func (b *Batch) Clear() {
+	b.buf.WriteString("DELETE FROM terms;\nDELETE FROM chunks;\nDELETE FROM files;\n")
+	b.files++
+}
+
+// SetSetting stores value under key, replacing any previous value.
+func (b *Batch) SetSetting(key, value string) {
+	b.buf.WriteString(setSetting(key, value))
+	b.files++
+}
+
+func (b *Batch) DeleteFile(path string) {
+	b.deleteFileData(path)
+	fmt.Fprintf(&b.buf, "DELETE FROM files WHERE path = %s;\n", sqlQuote(path))
+	b.files++
+}
+
+func (b *Batch) ReplaceFileData(fr FileRecord, chunks []ChunkRecord, terms []TermRecord) {
+	b.ReplaceFileChunks(fr, nil, chunks, terms)
+}
+
+// ReplaceFileChunks is ReplaceFileData for a file some of whose chunks are
+// already stored. Chunks in keep retain their rows and terms, with positions
+// and flags updated; the file's other chunks are replaced by chunks and
+// terms.
+func (b *Batch) ReplaceFileChunks(fr FileRecord, keep, chunks []ChunkRecord, terms []TermRecord) {
+	kept := make([]string, len(keep))
+	for i, ch := range keep {
+		kept[i] = sqlQuote(ch.ID)
+	}
+	stale := fmt.Sprintf("SELECT id FROM chunks WHERE file_path = %s AND id NOT IN (%s)", sqlQuote(fr.Path), strings.Join(kept, ","))
+	fmt.Fprintf(&b.buf, "DELETE FROM terms WHERE chunk_id IN (%s);\n", stale)
+	fmt.Fprintf(&b.buf, "DELETE FROM chunks WHERE id IN (%s);\n", stale)
+	fmt.Fprintf(&b.buf, "INSERT INTO files(path, hash, mtime, size) VALUES(%s, %s, %d, %d)\n", sqlQuote(fr.Path), sqlQuote(fr.Hash), fr.MTime, fr.Size)
+	b.buf.WriteString("ON CONFLICT(path) DO UPDATE SET hash=excluded.hash, mtime=excluded.mtime, size=excluded.size;\n")
+	for _, ch := range keep {
+		fmt.Fprintf(&b.buf, "UPDATE chunks SET start_line = %d, end_line = %d, is_test = %d WHERE id = %s;\n",
+			ch.StartLine, ch.EndLine, boolInt(ch.Test), sqlQuote(ch.ID))
+	}
 	for _, ch := range chunks {
-		fmt.Fprintf(&buf, "INSERT INTO chunks(id, file_path, start_line, end_line, hash, content) VALUES(%s, %s, %d, %d, %s, %s);\n",
-			sqlQuote(ch.ID), sqlQuote(ch.FilePath), ch.StartLine, ch.EndLine, sqlQuote(ch.Hash), sqlQuote(ch.Content))
+		fmt.Fprintf(&b.buf, "INSERT INTO chunks(id, file_path, start_line, end_line, hash, content, is_test, lang) VALUES(%s, %s, %d, %d, %s, %s, %d, %s);\n",
+			sqlQuote(ch.ID), sqlQuote(ch.FilePath), ch.StartLine, ch.EndLine, sqlQuote(ch.Hash), sqlQuote(ch.Content), boolInt(ch.Test), sqlQuote(ch.Lang))
 	}
 	for _, tr := range terms {
-		fmt.Fprintf(&buf, "INSERT INTO terms(term, chunk_id, tf) VALUES(%s, %s, %d);\n",
+		fmt.Fprintf(&b.buf, "INSERT INTO terms(term, chunk_id, tf) VALUES(%s, %s, %d);\n",
 			sqlQuote(tr.Term), sqlQuote(tr.ChunkID), tr.TF)
 	}
-	buf.WriteString("COMMIT;\n")
-	return d.runScript(buf.String())
+	b.files++
+}
+
+// TouchFile updates the stat data of an indexed file whose content is
+// unchanged.
+func (b *Batch) TouchFile(fr FileRecord) {
+	fmt.Fprintf(&b.buf, "UPDATE files SET hash = %s, mtime = %d, size = %d WHERE path = %s;\n", sqlQuote(fr.Hash), fr.MTime, fr.Size, sqlQuote(fr.Path))
+	b.files++
+}
+
+// SetFileTest flags or unflags every chunk of path as a test.
+func (b *Batch) SetFileTest(path string, test bool) {
+	fmt.Fprintf(&b.buf, "UPDATE chunks SET is_test = %d WHERE file_path = %s;\n", boolInt(test), sqlQuote(path))
+	b.files++
+}
+
+func (b *Batch) deleteFileData(path string) {
+	fmt.Fprintf(&b.buf, "DELETE FROM terms WHERE chunk_id IN (SELECT id FROM chunks WHERE file_path = %s);\n", sqlQuote(path))
+	fmt.Fprintf(&b.buf, "DELETE FROM chunks WHERE file_path = %s;\n", sqlQuote(path))
+}
+
+// Commit applies the queued operations atomically and empties the batch.
+// On error, including cancellation of ctx, none of them are applied. The
+// operations of a Tx's batch are applied when the Tx commits.
+func (b *Batch) Commit(ctx context.Context) error {
+	if b.files == 0 {
+		return nil
+	}
+	script := b.buf.String()
+	b.buf.Reset()
+	b.files = 0
+	if b.tx != nil {
+		return b.tx.write(ctx, script)
+	}
+	return b.d.runScript(ctx, "BEGIN;\n"+script+"COMMIT;\n")
 }
 
-func (d *DB) runQuery(query string) (string, error) {
-	cmd := exec.Command("sqlite3", "-batch", "-noheader", "-separator", "\t", d.Path, query)
+func (d *DB) runQuery(ctx context.Context, query string) (string, error) {
+	if d.session != nil {
+		return d.session.query(ctx, query)
+	}
+	cmd := exec.CommandContext(ctx, "sqlite3", "-batch", "-noheader", "-separator", "\t", d.Path, query)
 	out, err := cmd.CombinedOutput()
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return "", ctxErr
+	}
 	if err != nil {
 		return "", fmt.Errorf("sqlite3 query: %w: %s", err, strings.TrimSpace(string(out)))
 	}
 	return string(out), nil
 }
 
-func (d *DB) scalarInt(query string) (int, error) {
-	out, err := d.runQuery(query)
+func (d *DB) scalarInt(ctx context.Context, query string) (int, error) {
+	out, err := d.runQuery(ctx, query)
 	if err != nil {
 		return 0, err
 	}
@@ -279,16 +499,31 @@ func (d *DB) scalarInt(query string) (int, error) {
 	return int(parseInt64(lines[0])), nil
 }
 
-func (d *DB) runScript(script string) error {
-	cmd := exec.Command("sqlite3", d.Path)
+func (d *DB) runScript(ctx context.Context, script string) error {
+	if d.session != nil {
+		return errors.New("metadata: snapshots are read-only")
+	}
+	cmd := exec.CommandContext(ctx, "sqlite3", "-bail", d.Path)
 	cmd.Stdin = strings.NewReader(script)
+	// A killed sqlite3 leaves an uncommitted transaction in its journal, which
+	// the next connection rolls back.
 	out, err := cmd.CombinedOutput()
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
 	if err != nil {
 		return fmt.Errorf("sqlite3 script: %w: %s", err, strings.TrimSpace(string(out)))
 	}
 	return nil
 }
 
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
 func sqlQuote(value string) string {
 	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
 }
@@ -1,9 +1,12 @@
 package metadata
 
 import (
+	"context"
+	"errors"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -20,7 +23,7 @@ func TestOpenRejectsDirectoryPath(t *testing.T) {
 	if err := os.Mkdir(filepath.Join(root, "dbdir"), 0o755); err != nil {
 		t.Fatalf("mkdir: %v", err)
 	}
-	_, err := Open(filepath.Join(root, "dbdir"))
+	_, err := Open(context.Background(), filepath.Join(root, "dbdir"))
 	if err == nil {
 		t.Fatalf("expected error opening directory as db path")
 	}
@@ -34,7 +37,7 @@ func TestOpenMissingSQLite(t *testing.T) {
 	t.Cleanup(func() {
 		_ = os.Setenv("PATH", orig)
 	})
-	if _, err := Open(filepath.Join(t.TempDir(), "index.db")); err == nil {
+	if _, err := Open(context.Background(), filepath.Join(t.TempDir(), "index.db")); err == nil {
 		t.Fatalf("expected sqlite3 missing error")
 	}
 }
@@ -43,7 +46,7 @@ func TestDBLifecycle(t *testing.T) {
 	requireSQLite(t)
 	root := t.TempDir()
 	dbPath := filepath.Join(root, "index.db")
-	store, err := Open(dbPath)
+	store, err := Open(context.Background(), dbPath)
 	if err != nil {
 		t.Fatalf("open db: %v", err)
 	}
@@ -54,11 +57,11 @@ func TestDBLifecycle(t *testing.T) {
 		{Term: "alpha", ChunkID: "c1", TF: 2},
 		{Term: "beta", ChunkID: "c1", TF: 1},
 	}
-	if err := store.ReplaceFileData(file, []ChunkRecord{chunk}, terms); err != nil {
+	if err := store.ReplaceFileData(context.Background(), file, []ChunkRecord{chunk}, terms); err != nil {
 		t.Fatalf("replace file data: %v", err)
 	}
 
-	gotFile, ok, err := store.GetFile("src/main.go")
+	gotFile, ok, err := store.GetFile(context.Background(), "src/main.go")
 	if err != nil {
 		t.Fatalf("get file: %v", err)
 	}
@@ -66,7 +69,7 @@ func TestDBLifecycle(t *testing.T) {
 		t.Fatalf("expected file record")
 	}
 
-	files, err := store.ListFiles()
+	files, err := store.ListFiles(context.Background())
 	if err != nil {
 		t.Fatalf("list files: %v", err)
 	}
@@ -74,7 +77,7 @@ func TestDBLifecycle(t *testing.T) {
 		t.Fatalf("unexpected files: %v", files)
 	}
 
-	view, ok, err := store.GetChunk("c1")
+	view, ok, err := store.GetChunk(context.Background(), "c1")
 	if err != nil {
 		t.Fatalf("get chunk: %v", err)
 	}
@@ -82,7 +85,7 @@ func TestDBLifecycle(t *testing.T) {
 		t.Fatalf("unexpected chunk view")
 	}
 
-	views, err := store.GetChunksByIDs([]string{"c1"})
+	views, err := store.GetChunksByIDs(context.Background(), []string{"c1"})
 	if err != nil {
 		t.Fatalf("get chunks: %v", err)
 	}
@@ -90,7 +93,7 @@ func TestDBLifecycle(t *testing.T) {
 		t.Fatalf("unexpected chunks: %v", views)
 	}
 
-	hits, err := store.TermHits("alpha")
+	hits, err := store.TermHits(context.Background(), "alpha")
 	if err != nil {
 		t.Fatalf("term hits: %v", err)
 	}
@@ -98,7 +101,7 @@ func TestDBLifecycle(t *testing.T) {
 		t.Fatalf("unexpected term hits: %v", hits)
 	}
 
-	stats, err := store.Stats()
+	stats, err := store.Stats(context.Background())
 	if err != nil {
 		t.Fatalf("stats: %v", err)
 	}
@@ -106,10 +109,10 @@ func TestDBLifecycle(t *testing.T) {
 		t.Fatalf("unexpected stats: %+v", stats)
 	}
 
-	if err := store.DeleteFile("src/main.go"); err != nil {
+	if err := store.DeleteFile(context.Background(), "src/main.go"); err != nil {
 		t.Fatalf("delete file: %v", err)
 	}
-	files, err = store.ListFiles()
+	files, err = store.ListFiles(context.Background())
 	if err != nil {
 		t.Fatalf("list files: %v", err)
 	}
@@ -122,24 +125,24 @@ func TestEmptyQueries(t *testing.T) {
 	requireSQLite(t)
 	root := t.TempDir()
 	dbPath := filepath.Join(root, "index.db")
-	store, err := Open(dbPath)
+	store, err := Open(context.Background(), dbPath)
 	if err != nil {
 		t.Fatalf("open db: %v", err)
 	}
 
-	if _, ok, err := store.GetFile("missing.go"); err != nil || ok {
+	if _, ok, err := store.GetFile(context.Background(), "missing.go"); err != nil || ok {
 		t.Fatalf("expected missing file, ok=%v err=%v", ok, err)
 	}
-	if _, ok, err := store.GetChunk("missing"); err != nil || ok {
+	if _, ok, err := store.GetChunk(context.Background(), "missing"); err != nil || ok {
 		t.Fatalf("expected missing chunk, ok=%v err=%v", ok, err)
 	}
-	if chunks, err := store.GetChunksByIDs(nil); err != nil || chunks != nil {
+	if chunks, err := store.GetChunksByIDs(context.Background(), nil); err != nil || chunks != nil {
 		t.Fatalf("expected nil chunks for empty ids, got %v err=%v", chunks, err)
 	}
-	if hits, err := store.TermHits("none"); err != nil || len(hits) != 0 {
+	if hits, err := store.TermHits(context.Background(), "none"); err != nil || len(hits) != 0 {
 		t.Fatalf("expected no term hits, got %v err=%v", hits, err)
 	}
-	stats, err := store.Stats()
+	stats, err := store.Stats(context.Background())
 	if err != nil {
 		t.Fatalf("stats: %v", err)
 	}
@@ -168,11 +171,11 @@ func TestRunQueryError(t *testing.T) {
 	requireSQLite(t)
 	root := t.TempDir()
 	dbPath := filepath.Join(root, "index.db")
-	store, err := Open(dbPath)
+	store, err := Open(context.Background(), dbPath)
 	if err != nil {
 		t.Fatalf("open db: %v", err)
 	}
-	if _, err := store.runQuery("SELECT nope;"); err == nil {
+	if _, err := store.runQuery(context.Background(), "SELECT nope;"); err == nil {
 		t.Fatalf("expected query error")
 	}
 }
@@ -186,22 +189,22 @@ func TestDBSchemaMissingErrors(t *testing.T) {
 	}
 	store := &DB{Path: dbPath}
 
-	if _, _, err := store.GetFile("x"); err == nil {
+	if _, _, err := store.GetFile(context.Background(), "x"); err == nil {
 		t.Fatalf("expected GetFile error")
 	}
-	if _, err := store.ListFiles(); err == nil {
+	if _, err := store.ListFiles(context.Background()); err == nil {
 		t.Fatalf("expected ListFiles error")
 	}
-	if _, _, err := store.GetChunk("c1"); err == nil {
+	if _, _, err := store.GetChunk(context.Background(), "c1"); err == nil {
 		t.Fatalf("expected GetChunk error")
 	}
-	if _, err := store.GetChunksByIDs([]string{"c1"}); err == nil {
+	if _, err := store.GetChunksByIDs(context.Background(), []string{"c1"}); err == nil {
 		t.Fatalf("expected GetChunksByIDs error")
 	}
-	if _, err := store.TermHits("alpha"); err == nil {
+	if _, err := store.TermHits(context.Background(), "alpha"); err == nil {
 		t.Fatalf("expected TermHits error")
 	}
-	if _, err := store.Stats(); err == nil {
+	if _, err := store.Stats(context.Background()); err == nil {
 		t.Fatalf("expected Stats error")
 	}
 }
@@ -210,11 +213,11 @@ func TestScalarIntEmptyResult(t *testing.T) {
 	requireSQLite(t)
 	root := t.TempDir()
 	dbPath := filepath.Join(root, "index.db")
-	store, err := Open(dbPath)
+	store, err := Open(context.Background(), dbPath)
 	if err != nil {
 		t.Fatalf("open db: %v", err)
 	}
-	got, err := store.scalarInt("SELECT 1 WHERE 0;")
+	got, err := store.scalarInt(context.Background(), "SELECT 1 WHERE 0;")
 	if err != nil {
 		t.Fatalf("scalarInt: %v", err)
 	}
@@ -222,3 +225,142 @@ func TestScalarIntEmptyResult(t *testing.T) {
 		t.Fatalf("expected 0, got %d", got)
 	}
 }
+
+func TestOpenMigratesLegacySchema(t *testing.T) {
+	requireSQLite(t)
+	dbPath := filepath.Join(t.TempDir(), "index.db")
+	legacy := `
+CREATE TABLE chunks (
+  id TEXT PRIMARY KEY,
+  file_path TEXT NOT NULL,
+  start_line INTEGER NOT NULL,
+  end_line INTEGER NOT NULL,
+  hash TEXT NOT NULL,
+  content TEXT NOT NULL
+);
+INSERT INTO chunks VALUES('c1', 'a.go', 1, 1, 'h', 'alpha');
+`
+	cmd := exec.Command("sqlite3", dbPath)
+	cmd.Stdin = strings.NewReader(legacy)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("create legacy db: %v: %s", err, out)
+	}
+	store, err := Open(context.Background(), dbPath)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	view, ok, err := store.GetChunk(context.Background(), "c1")
+	if err != nil || !ok {
+		t.Fatalf("get chunk: ok=%v err=%v", ok, err)
+	}
+	if view.Test || view.Content != "alpha" {
+		t.Fatalf("unexpected migrated chunk: %+v", view)
+	}
+	if _, err := Open(context.Background(), dbPath); err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+}
+
+func TestChunkTestFlagRoundTrip(t *testing.T) {
+	requireSQLite(t)
+	store, err := Open(context.Background(), filepath.Join(t.TempDir(), "index.db"))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	file := FileRecord{Path: "a_test.go", Hash: "h", MTime: 1, Size: 1}
+	chunk := ChunkRecord{ID: "c1", FilePath: "a_test.go", StartLine: 1, EndLine: 1, Hash: "h", Content: "x", Test: true, Lang: "go"}
+	if err := store.ReplaceFileData(context.Background(), file, []ChunkRecord{chunk}, nil); err != nil {
+		t.Fatalf("replace: %v", err)
+	}
+	views, err := store.GetChunksByIDs(context.Background(), []string{"c1"})
+	if err != nil {
+		t.Fatalf("get chunks: %v", err)
+	}
+	if len(views) != 1 || !views[0].Test || views[0].Lang != "go" {
+		t.Fatalf("expected test flag and language, got %+v", views)
+	}
+}
+
+func TestSettings(t *testing.T) {
+	requireSQLite(t)
+	store, err := Open(context.Background(), filepath.Join(t.TempDir(), "index.db"))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if _, ok, err := store.Setting(context.Background(), "analyzer"); err != nil || ok {
+		t.Fatalf("expected no setting, got ok=%v err=%v", ok, err)
+	}
+	for _, value := range []string{"v1 min=2", "it's\tdifferent", ""} {
+		if err := store.SetSetting(context.Background(), "analyzer", value); err != nil {
+			t.Fatalf("set setting: %v", err)
+		}
+		got, ok, err := store.Setting(context.Background(), "analyzer")
+		if err != nil || !ok || got != value {
+			t.Fatalf("expected %q, got %q ok=%v err=%v", value, got, ok, err)
+		}
+	}
+}
+
+func TestBatchCommitIsAtomic(t *testing.T) {
+	requireSQLite(t)
+	store, err := Open(context.Background(), filepath.Join(t.TempDir(), "index.db"))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if err := store.ReplaceFileData(context.Background(), FileRecord{Path: "old.go", Hash: "h0"}, nil, nil); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+
+	batch := store.Batch()
+	batch.DeleteFile("old.go")
+	batch.ReplaceFileData(FileRecord{Path: "a.go", Hash: "h1"}, []ChunkRecord{{ID: "c1", FilePath: "a.go", Content: "a"}}, nil)
+	// A duplicate chunk id fails the transaction half way through.
+	batch.ReplaceFileData(FileRecord{Path: "b.go", Hash: "h2"}, []ChunkRecord{{ID: "c1", FilePath: "b.go", Content: "b"}}, nil)
+	if batch.Len() != 3 {
+		t.Fatalf("expected 3 queued files, got %d", batch.Len())
+	}
+	if err := batch.Commit(context.Background()); err == nil {
+		t.Fatalf("expected commit error")
+	}
+	files, err := store.ListFiles(context.Background())
+	if err != nil {
+		t.Fatalf("list files: %v", err)
+	}
+	if strings.Join(files, ",") != "old.go" {
+		t.Fatalf("expected failed batch rolled back, got %v", files)
+	}
+
+	batch.DeleteFile("old.go")
+	batch.ReplaceFileData(FileRecord{Path: "a.go", Hash: "h1"}, []ChunkRecord{{ID: "c1", FilePath: "a.go", Content: "a"}}, nil)
+	if err := batch.Commit(context.Background()); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	if batch.Len() != 0 {
+		t.Fatalf("expected empty batch after commit")
+	}
+	files, err = store.ListFiles(context.Background())
+	if err != nil || strings.Join(files, ",") != "a.go" {
+		t.Fatalf("unexpected files after commit: %v err=%v", files, err)
+	}
+}
+
+func TestCancelledContextAbortsQueries(t *testing.T) {
+	requireSQLite(t)
+	ctx := context.Background()
+	store, err := Open(ctx, filepath.Join(t.TempDir(), "index.db"))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := store.ListFiles(cancelled); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled from query, got %v", err)
+	}
+	if err := store.ReplaceFileData(cancelled, FileRecord{Path: "a.go", Hash: "h1"}, nil, nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled from write, got %v", err)
+	}
+	files, err := store.ListFiles(ctx)
+	if err != nil || len(files) != 0 {
+		t.Fatalf("expected cancelled write not applied, got %v err=%v", files, err)
+	}
+}
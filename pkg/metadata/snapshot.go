@@ -0,0 +1,149 @@
+package metadata
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// ErrStale is returned by OpenSnapshot for indexes whose schema is older
+// than this version's; the next index run upgrades them.
+var ErrStale = errors.New("index was built by an older version; run `scry index`")
+
+// OpenSnapshot opens an existing index for reading. All its queries run in
+// one read transaction, so they see the index as it was when it was opened
+// however long the reader takes and whatever index runs commit meanwhile.
+// The database is never written: it is not migrated, and queries that would
+// change it fail. Close ends the transaction.
+func OpenSnapshot(ctx context.Context, path string) (*DB, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		return nil, fmt.Errorf("sqlite3 not found: %w", err)
+	}
+	s, err := startSession(path)
+	if err != nil {
+		return nil, err
+	}
+	db := &DB{Path: path, session: s}
+	// Reading the schema version starts the read transaction.
+	version, err := db.scalarInt(ctx, "PRAGMA query_only = ON;\nBEGIN;\nPRAGMA user_version;")
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	if version < len(migrations) {
+		db.Close()
+		return nil, ErrStale
+	}
+	return db, nil
+}
+
+// Close releases a snapshot. It does nothing for databases opened with
+// Open.
+func (d *DB) Close() error {
+	if d.session == nil {
+		return nil
+	}
+	return d.session.close()
+}
+
+// session is a sqlite3 process that runs queries read from its standard
+// input. Each query's output ends with a marker line; -bail makes sqlite3
+// exit on the first error, which then shows as output ending early.
+type session struct {
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	in     io.WriteCloser
+	out    *bufio.Reader
+	stderr strings.Builder
+	marker string
+	// err is set once the process has exited.
+	err error
+}
+
+func startSession(path string) (*session, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+	s := &session{marker: "-- end " + hex.EncodeToString(token)}
+	s.cmd = exec.Command("sqlite3", "-batch", "-bail", "-noheader", "-separator", "\t", path)
+	s.cmd.Stderr = &s.stderr
+	in, err := s.cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	out, err := s.cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.cmd.Start(); err != nil {
+		return nil, err
+	}
+	s.in, s.out = in, bufio.NewReader(out)
+	return s, nil
+}
+
+func (s *session) query(ctx context.Context, query string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return "", s.err
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	stop := context.AfterFunc(ctx, func() { _ = s.cmd.Process.Kill() })
+	defer stop()
+	if _, err := fmt.Fprintf(s.in, "%s\n.print '%s'\n", query, s.marker); err != nil {
+		return "", s.failed(ctx, err)
+	}
+	var out strings.Builder
+	for {
+		line, err := s.out.ReadString('\n')
+		if err != nil {
+			return "", s.failed(ctx, err)
+		}
+		if strings.TrimSuffix(line, "\n") == s.marker {
+			return out.String(), nil
+		}
+		out.WriteString(line)
+	}
+}
+
+// failed stops the process and reports why it stopped answering. Later
+// queries fail the same way.
+func (s *session) failed(ctx context.Context, err error) error {
+	_ = s.in.Close()
+	if waitErr := s.cmd.Wait(); waitErr != nil {
+		err = waitErr
+	}
+	s.err = fmt.Errorf("sqlite3 query: %w: %s", err, strings.TrimSpace(s.stderr.String()))
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		s.err = ctxErr
+	}
+	return s.err
+}
+
+func (s *session) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return nil
+	}
+	// Closing standard input ends the transaction and the process.
+	_ = s.in.Close()
+	err := s.cmd.Wait()
+	s.err = errors.New("metadata: snapshot closed")
+	return err
+}
@@ -0,0 +1,109 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSnapshotPinsIndex(t *testing.T) {
+	requireSQLite(t)
+	ctx := context.Background()
+	dbPath := filepath.Join(t.TempDir(), "index.db")
+	store, err := Open(ctx, dbPath)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if err := store.ReplaceFileData(ctx, FileRecord{Path: "a.go", Hash: "h1"}, nil, nil); err != nil {
+		t.Fatalf("replace: %v", err)
+	}
+	snap, err := OpenSnapshot(ctx, dbPath)
+	if err != nil {
+		t.Fatalf("open snapshot: %v", err)
+	}
+	defer snap.Close()
+
+	// An index run commits meanwhile.
+	tx, err := store.Begin(ctx)
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	b := tx.Batch()
+	b.Clear()
+	b.ReplaceFileData(FileRecord{Path: "b.go", Hash: "h2"}, nil, nil)
+	if err := b.Commit(ctx); err != nil {
+		t.Fatalf("batch: %v", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	if files, err := store.ListFiles(ctx); err != nil || len(files) != 1 || files[0] != "b.go" {
+		t.Fatalf("expected the run committed, got %v err=%v", files, err)
+	}
+
+	for i := 0; i < 2; i++ {
+		files, err := snap.ListFiles(ctx)
+		if err != nil || len(files) != 1 || files[0] != "a.go" {
+			t.Fatalf("expected the snapshot to keep a.go, got %v err=%v", files, err)
+		}
+	}
+	if err := snap.SetSetting(ctx, "k", "v"); err == nil {
+		t.Fatalf("expected snapshot writes to fail")
+	}
+	if err := snap.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if _, err := snap.ListFiles(ctx); err == nil {
+		t.Fatalf("expected queries after close to fail")
+	}
+}
+
+func TestSnapshotDoesNotMigrate(t *testing.T) {
+	requireSQLite(t)
+	ctx := context.Background()
+	dbPath := filepath.Join(t.TempDir(), "index.db")
+	if _, err := OpenSnapshot(ctx, dbPath); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected missing index error, got %v", err)
+	}
+	cmd := exec.Command("sqlite3", dbPath)
+	cmd.Stdin = strings.NewReader("CREATE TABLE files (path TEXT PRIMARY KEY, hash TEXT NOT NULL, mtime INTEGER NOT NULL, size INTEGER NOT NULL);\n")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("create legacy db: %v: %s", err, out)
+	}
+	before, err := os.ReadFile(dbPath)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if _, err := OpenSnapshot(ctx, dbPath); !errors.Is(err, ErrStale) {
+		t.Fatalf("expected stale index error, got %v", err)
+	}
+	after, err := os.ReadFile(dbPath)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if string(before) != string(after) {
+		t.Fatalf("expected the index to be left unchanged")
+	}
+}
+
+func TestSnapshotCancelledQuery(t *testing.T) {
+	requireSQLite(t)
+	dbPath := filepath.Join(t.TempDir(), "index.db")
+	if _, err := Open(context.Background(), dbPath); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	snap, err := OpenSnapshot(context.Background(), dbPath)
+	if err != nil {
+		t.Fatalf("open snapshot: %v", err)
+	}
+	defer snap.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := snap.Stats(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancellation, got %v", err)
+	}
+}
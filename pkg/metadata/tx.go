@@ -0,0 +1,99 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// Tx is a write transaction that stays open across batches, so a run of
+// any length is applied all at once or not at all. Its statements are fed
+// to one sqlite3 process; other connections keep reading the previous
+// version until Commit. Rollback, cancellation of the context it was begun
+// with, or a crash discards everything written since Begin.
+type Tx struct {
+	cmd    *exec.Cmd
+	in     io.WriteCloser
+	stderr strings.Builder
+	// err is set once the process has exited.
+	err error
+}
+
+// Begin starts a write transaction on d.
+func (d *DB) Begin(ctx context.Context) (*Tx, error) {
+	if d.session != nil {
+		return nil, errors.New("metadata: snapshots are read-only")
+	}
+	t := &Tx{cmd: exec.CommandContext(ctx, "sqlite3", "-batch", "-bail", d.Path)}
+	t.cmd.Stderr = &t.stderr
+	in, err := t.cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := t.cmd.Start(); err != nil {
+		return nil, err
+	}
+	t.in = in
+	if err := t.write(ctx, "PRAGMA busy_timeout = 5000;\nBEGIN IMMEDIATE;\n"); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Batch returns a batch whose Commit passes its operations on to t.
+func (t *Tx) Batch() *Batch {
+	return &Batch{tx: t}
+}
+
+func (t *Tx) write(ctx context.Context, script string) error {
+	if t.err != nil {
+		return t.err
+	}
+	if _, err := io.WriteString(t.in, script); err != nil {
+		return t.finish(ctx, err)
+	}
+	return nil
+}
+
+// Commit applies everything written to t.
+func (t *Tx) Commit(ctx context.Context) error {
+	if err := t.write(ctx, "COMMIT;\n"); err != nil {
+		return err
+	}
+	if err := t.finish(ctx, nil); err != nil {
+		return err
+	}
+	t.err = errors.New("metadata: transaction committed")
+	return nil
+}
+
+// Rollback discards everything written to t. It does nothing once t has
+// committed or failed.
+func (t *Tx) Rollback() {
+	if t.err != nil {
+		return
+	}
+	_ = t.cmd.Process.Kill()
+	_ = t.finish(context.Background(), nil)
+	t.err = errors.New("metadata: transaction rolled back")
+}
+
+// finish closes the process's input and waits for it to exit, reporting
+// the first error it hit.
+func (t *Tx) finish(ctx context.Context, err error) error {
+	_ = t.in.Close()
+	if waitErr := t.cmd.Wait(); waitErr != nil {
+		err = waitErr
+	}
+	if err == nil {
+		return nil
+	}
+	t.err = fmt.Errorf("sqlite3 script: %w: %s", err, strings.TrimSpace(t.stderr.String()))
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		t.err = ctxErr
+	}
+	return t.err
+}
@@ -11,8 +11,9 @@ import (
 )
 
 type goChunk struct {
-	start int
-	end   int
+	start  int
+	end    int
+	symbol string
 }
 
 func ChunkGo(path string, content string) []chunk.Chunk {
@@ -28,14 +29,14 @@ func ChunkGo(path string, content string) []chunk.Chunk {
 		case *ast.FuncDecl:
 			start := fset.Position(d.Pos()).Line
 			end := fset.Position(d.End()).Line
-			spans = append(spans, goChunk{start: start, end: end})
+			spans = append(spans, goChunk{start: start, end: end, symbol: funcSymbol(d)})
 		case *ast.GenDecl:
 			if d.Tok != token.TYPE {
 				continue
 			}
 			start := fset.Position(d.Pos()).Line
 			end := fset.Position(d.End()).Line
-			spans = append(spans, goChunk{start: start, end: end})
+			spans = append(spans, goChunk{start: start, end: end, symbol: typeSymbol(d)})
 		}
 	}
 
@@ -56,11 +57,49 @@ func ChunkGo(path string, content string) []chunk.Chunk {
 			EndLine:   end,
 			Text:      text,
 			Lang:      "go",
+			Symbol:    sp.symbol,
 		})
 	}
 	return chunks
 }
 
+// funcSymbol names a function "F" and a method "T.M", whatever the
+// receiver's pointerness or type parameters.
+func funcSymbol(d *ast.FuncDecl) string {
+	if d.Recv == nil || len(d.Recv.List) == 0 {
+		return d.Name.Name
+	}
+	typ := d.Recv.List[0].Type
+	for {
+		switch t := typ.(type) {
+		case *ast.StarExpr:
+			typ = t.X
+			continue
+		case *ast.IndexExpr:
+			typ = t.X
+			continue
+		case *ast.IndexListExpr:
+			typ = t.X
+			continue
+		case *ast.Ident:
+			return t.Name + "." + d.Name.Name
+		}
+		return d.Name.Name
+	}
+}
+
+// typeSymbol names a type declaration after its types, comma separated for
+// grouped declarations.
+func typeSymbol(d *ast.GenDecl) string {
+	var names []string
+	for _, spec := range d.Specs {
+		if ts, ok := spec.(*ast.TypeSpec); ok {
+			names = append(names, ts.Name.Name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
 func fallbackGoChunks(path string, content string) []chunk.Chunk {
 	lines := strings.Split(content, "\n")
 	if len(lines) == 0 {
@@ -9,8 +9,10 @@ import (
 func ChunkMarkdown(path string, content string) []chunk.Chunk {
 	lines := strings.Split(content, "\n")
 	var chunks []chunk.Chunk
+	var headings []heading
 	start := 1
 	current := ""
+	symbol := ""
 	for i, line := range lines {
 		ln := i + 1
 		if strings.HasPrefix(strings.TrimSpace(line), "#") {
@@ -21,8 +23,11 @@ func ChunkMarkdown(path string, content string) []chunk.Chunk {
 					EndLine:   ln - 1,
 					Text:      current,
 					Lang:      "md",
+					Symbol:    symbol,
 				})
 			}
+			headings = pushHeading(headings, line)
+			symbol = headingPath(headings)
 			start = ln
 			current = line
 			continue
@@ -41,7 +46,33 @@ func ChunkMarkdown(path string, content string) []chunk.Chunk {
 			EndLine:   len(lines),
 			Text:      current,
 			Lang:      "md",
+			Symbol:    symbol,
 		})
 	}
 	return chunks
 }
+
+type heading struct {
+	level int
+	title string
+}
+
+// pushHeading replaces the headings at line's level or deeper with line.
+func pushHeading(stack []heading, line string) []heading {
+	trimmed := strings.TrimSpace(line)
+	level := len(trimmed) - len(strings.TrimLeft(trimmed, "#"))
+	title := strings.TrimSpace(strings.Trim(strings.TrimSpace(trimmed[level:]), "#"))
+	for len(stack) > 0 && stack[len(stack)-1].level >= level {
+		stack = stack[:len(stack)-1]
+	}
+	return append(stack, heading{level: level, title: title})
+}
+
+// headingPath joins the enclosing headings, outermost first.
+func headingPath(stack []heading) string {
+	titles := make([]string, len(stack))
+	for i, h := range stack {
+		titles[i] = h.title
+	}
+	return strings.Join(titles, " > ")
+}
@@ -18,3 +18,31 @@ func ChunksForFile(path string, content string) []chunk.Chunk {
 		return nil
 	}
 }
+
+// SplitLong splits chunks longer than maxLines lines into consecutive pieces
+// that keep the chunk's symbol. A maxLines of zero leaves chunks whole.
+func SplitLong(chunks []chunk.Chunk, maxLines int) []chunk.Chunk {
+	if maxLines <= 0 {
+		return chunks
+	}
+	out := make([]chunk.Chunk, 0, len(chunks))
+	for _, ch := range chunks {
+		if ch.EndLine-ch.StartLine+1 <= maxLines {
+			out = append(out, ch)
+			continue
+		}
+		lines := strings.Split(ch.Text, "\n")
+		for i := 0; i < len(lines); i += maxLines {
+			end := i + maxLines
+			if end > len(lines) {
+				end = len(lines)
+			}
+			piece := ch
+			piece.StartLine = ch.StartLine + i
+			piece.EndLine = ch.StartLine + end - 1
+			piece.Text = strings.Join(lines[i:end], "\n")
+			out = append(out, piece)
+		}
+	}
+	return out
+}
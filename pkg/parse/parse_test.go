@@ -1,6 +1,10 @@
 package parse
 
-import "testing"
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
 
 func TestChunkGoByTopLevel(t *testing.T) {
 	src := `package main
@@ -82,3 +86,53 @@ func TestChunkMarkdownEmpty(t *testing.T) {
 		t.Fatalf("expected no chunks, got %d", len(chunks))
 	}
 }
+
+func TestChunkSymbols(t *testing.T) {
+	src := `package main
+
+type (
+	A struct{}
+	B[T any] struct{}
+)
+
+func (a *A) Run() {}
+
+func (b B[T]) Get() {}
+
+func main() {}
+`
+	var got []string
+	for _, c := range ChunkGo("main.go", src) {
+		got = append(got, c.Symbol)
+	}
+	if strings.Join(got, "|") != "A,B|A.Run|B.Get|main" {
+		t.Fatalf("unexpected go symbols: %v", got)
+	}
+
+	md := "Preamble\n# Guide\nIntro\n## Install\nSteps\n### Linux ##\napt\n## Usage\nRun\n"
+	got = nil
+	for _, c := range ChunkMarkdown("doc.md", md) {
+		got = append(got, c.Symbol)
+	}
+	if strings.Join(got, "|") != "|Guide|Guide > Install|Guide > Install > Linux|Guide > Usage" {
+		t.Fatalf("unexpected markdown symbols: %q", got)
+	}
+}
+
+func TestSplitLong(t *testing.T) {
+	src := "package main\n\nfunc main() {\n\ta := 1\n\tb := 2\n\tc := 3\n\t_ = a + b + c\n}\n\nfunc small() {}\n"
+	chunks := SplitLong(ChunkGo("main.go", src), 3)
+	var got []string
+	for _, c := range chunks {
+		got = append(got, fmt.Sprintf("%s:%d-%d", c.Symbol, c.StartLine, c.EndLine))
+	}
+	if strings.Join(got, ",") != "main:3-5,main:6-8,small:10-10" {
+		t.Fatalf("unexpected pieces: %v", got)
+	}
+	if chunks[1].Text != "\tc := 3\n\t_ = a + b + c\n}" {
+		t.Fatalf("unexpected piece text: %q", chunks[1].Text)
+	}
+	if n := len(SplitLong(ChunkGo("main.go", src), 0)); n != 2 {
+		t.Fatalf("expected zero to keep chunks whole, got %d", n)
+	}
+}
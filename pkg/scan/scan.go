@@ -1,73 +1,421 @@
 package scan
 
 import (
+	"context"
+	"errors"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
+	"scry/pkg/gitrepo"
 	"scry/pkg/ignore"
 )
 
+// DefaultMaxFileSize is the size limit used when Options.MaxFileSize is zero.
+const DefaultMaxFileSize = 1 << 20
+
+// Reasons recorded for files skipped by scan policy. Ignored files are not
+// reported.
+const (
+	SkipTooLarge      = "too_large"
+	SkipSymlink       = "symlink"
+	SkipBrokenSymlink = "broken_symlink"
+	SkipSymlinkCycle  = "symlink_cycle"
+	SkipSubmodule     = "submodule"
+	SkipNestedRepo    = "nested_repo"
+)
+
 type File struct {
 	Path string
 	Info os.FileInfo
+	// Test is set for files matching the matcher's test patterns.
+	Test bool
+}
+
+// Skipped is a path left out by scan policy, relative to Root.
+type Skipped struct {
+	Path   string
+	Reason string
 }
 
 type Scanner struct {
 	Root    string
 	Matcher *ignore.Matcher
+	Options Options
+	// Skipped lists paths left out by the last ListFiles call.
+	Skipped []Skipped
+}
+
+type Options struct {
+	Ignore ignore.Options
+	// Git lists tracked files from the git index instead of walking the tree.
+	// Tracked files are still filtered by .scryignore and the default
+	// patterns, but, as in git, not by .gitignore. Outside a git repository
+	// the walker is used.
+	Git bool
+	// Untracked also lists untracked files that are not ignored in Git mode.
+	Untracked bool
+	// FollowSymlinks lists symlink targets and descends into linked
+	// directories, skipping cycles. Otherwise symlinks are skipped.
+	FollowSymlinks bool
+	// Submodules descends into git submodules and nested repositories.
+	Submodules bool
+	// MaxFileSize skips larger files. Zero selects DefaultMaxFileSize and a
+	// negative value disables the limit.
+	MaxFileSize int64
 }
 
 func New(root string) (*Scanner, error) {
-	matcher, err := ignore.Load(root)
+	return NewWithOptions(root, Options{})
+}
+
+func NewWithOptions(root string, opts Options) (*Scanner, error) {
+	matcher, err := ignore.LoadOptions(root, opts.Ignore)
 	if err != nil {
 		return nil, err
 	}
-	return &Scanner{Root: root, Matcher: matcher}, nil
+	return &Scanner{Root: root, Matcher: matcher, Options: opts}, nil
+}
+
+// ListFiles enumerates the files under Root. It stops with ctx.Err() once
+// ctx is done.
+func (s *Scanner) ListFiles(ctx context.Context) ([]File, error) {
+	s.Skipped = nil
+	if s.Options.Git {
+		if repo, ok := gitrepo.Find(s.Root); ok {
+			files, err := s.listGit(ctx, repo, nil)
+			if !errors.Is(err, gitrepo.ErrSplitIndex) {
+				return files, err
+			}
+			s.Skipped = nil
+		}
+	}
+	w := s.newWalker(ctx, nil)
+	if err := w.walkRoot(); err != nil {
+		return w.files, err
+	}
+	sortFiles(w.files)
+	return w.files, nil
+}
+
+// ListPaths applies the scanner's policies to the given paths relative to
+// Root, listing directories recursively. Paths that do not exist are left
+// out. In Git mode only tracked files, and untracked ones when requested,
+// are listed, as with ListFiles.
+func (s *Scanner) ListPaths(ctx context.Context, rels []string) ([]File, error) {
+	s.Skipped = nil
+	var within []string
+	for _, rel := range rels {
+		rel = strings.Trim(filepath.ToSlash(filepath.Clean(rel)), "/")
+		if rel == "" || rel == "." {
+			return s.ListFiles(ctx)
+		}
+		if !builtinSkip(rel) {
+			within = append(within, rel)
+		}
+	}
+	if len(within) == 0 {
+		return nil, nil
+	}
+	if s.Options.Git {
+		if repo, ok := gitrepo.Find(s.Root); ok {
+			files, err := s.listGit(ctx, repo, within)
+			if !errors.Is(err, gitrepo.ErrSplitIndex) {
+				return dedupe(files), err
+			}
+			s.Skipped = nil
+		}
+	}
+	w := s.newWalker(ctx, nil)
+	if err := w.walkPaths(within); err != nil {
+		return nil, err
+	}
+	sortFiles(w.files)
+	return dedupe(w.files), nil
+}
+
+func dedupe(files []File) []File {
+	var out []File
+	for _, f := range files {
+		if len(out) == 0 || out[len(out)-1].Path != f.Path {
+			out = append(out, f)
+		}
+	}
+	return out
 }
 
-func (s *Scanner) ListFiles() ([]File, error) {
-	var files []File
-	err := filepath.WalkDir(s.Root, func(path string, d fs.DirEntry, err error) error {
+// listGit enumerates index entries under Root, plus untracked files when
+// requested. A non-nil within limits the listing to those paths.
+func (s *Scanner) listGit(ctx context.Context, repo gitrepo.Repo, within []string) ([]File, error) {
+	tracked := map[string]struct{}{}
+	w := s.newWalker(ctx, tracked)
+	w.within = within
+	if err := s.addIndexed(w, repo, tracked); err != nil {
+		return nil, err
+	}
+	if s.Options.Untracked {
+		var err error
+		if within == nil {
+			err = w.walkRoot()
+		} else {
+			err = w.walkPaths(within)
+		}
 		if err != nil {
-			return err
+			return nil, err
 		}
-		if path == s.Root {
-			return nil
+	}
+	sortFiles(w.files)
+	return w.files, nil
+}
+
+func (s *Scanner) addIndexed(w *walker, repo gitrepo.Repo, tracked map[string]struct{}) error {
+	entries, err := repo.Index()
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := w.ctx.Err(); err != nil {
+			return err
 		}
+		path := filepath.Join(repo.WorkTree, filepath.FromSlash(e.Path))
 		rel, err := filepath.Rel(s.Root, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		if _, ok := tracked[rel]; ok {
+			continue
+		}
+		tracked[rel] = struct{}{}
+		if e.Mode == gitrepo.ModeDir || builtinSkip(rel) || !w.wants(rel, e.Mode == gitrepo.ModeGitlink) {
+			continue
+		}
+		if e.Mode == gitrepo.ModeGitlink {
+			if s.Matcher != nil && s.Matcher.MatchTracked(rel, true) {
+				continue
+			}
+			sub := gitrepo.Dir(path)
+			if !s.Options.Submodules || sub == "" {
+				w.skip(rel, SkipSubmodule)
+				continue
+			}
+			if err := s.addIndexed(w, gitrepo.Repo{WorkTree: path, GitDir: sub}, tracked); err != nil {
+				return err
+			}
+			continue
+		}
+		info, err := os.Lstat(path)
 		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
 			return err
 		}
-		rel = filepath.ToSlash(rel)
-		if strings.HasPrefix(rel, ".git/") || rel == ".git" {
-			if d.IsDir() {
-				return filepath.SkipDir
+		if err := w.entry(path, rel, info, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type walker struct {
+	ctx     context.Context
+	s       *Scanner
+	exclude map[string]struct{}
+	files   []File
+	skipped map[string]struct{}
+	// ancestors holds the resolved directories being walked when following
+	// symlinks; a link back to one of them is a cycle.
+	ancestors map[string]struct{}
+	// within, when not nil, limits git index entries to these paths.
+	within []string
+}
+
+func (s *Scanner) newWalker(ctx context.Context, exclude map[string]struct{}) *walker {
+	return &walker{ctx: ctx, s: s, exclude: exclude, skipped: map[string]struct{}{}, ancestors: map[string]struct{}{}}
+}
+
+func (w *walker) skip(rel, reason string) {
+	if _, ok := w.skipped[rel]; ok {
+		return
+	}
+	w.skipped[rel] = struct{}{}
+	w.s.Skipped = append(w.s.Skipped, Skipped{Path: rel, Reason: reason})
+}
+
+func (w *walker) walkRoot() error {
+	if w.s.Options.FollowSymlinks {
+		real, err := filepath.EvalSymlinks(w.s.Root)
+		if err != nil {
+			return err
+		}
+		w.ancestors[real] = struct{}{}
+	}
+	return w.dir(w.s.Root, "")
+}
+
+// walkPaths visits the given paths relative to Root.
+func (w *walker) walkPaths(rels []string) error {
+	for _, rel := range rels {
+		if err := w.ctx.Err(); err != nil {
+			return err
+		}
+		if _, ok := w.exclude[rel]; ok {
+			continue
+		}
+		path := filepath.Join(w.s.Root, filepath.FromSlash(rel))
+		info, err := os.Lstat(path)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
 			}
-			return nil
+			return err
+		}
+		if err := w.entry(path, rel, info, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// wants reports whether rel lies within the listed paths; a directory also
+// qualifies when it contains one of them.
+func (w *walker) wants(rel string, dir bool) bool {
+	if w.within == nil {
+		return true
+	}
+	for _, p := range w.within {
+		if rel == p || strings.HasPrefix(rel, p+"/") || dir && strings.HasPrefix(p, rel+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *walker) dir(path, rel string) error {
+	if err := w.ctx.Err(); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		childPath := filepath.Join(path, e.Name())
+		childRel := e.Name()
+		if rel != "" {
+			childRel = rel + "/" + e.Name()
+		}
+		if builtinSkip(childRel) {
+			continue
 		}
-		if strings.HasPrefix(rel, ".scry/") || rel == ".scry" {
-			if d.IsDir() {
-				return filepath.SkipDir
+		if _, ok := w.exclude[childRel]; ok {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
 			}
+			return err
+		}
+		if err := w.entry(childPath, childRel, info, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// entry applies symlink, ignore, nesting and size policy to one path. Paths
+// tracked by git are matched with Matcher.MatchTracked.
+func (w *walker) entry(path, rel string, info os.FileInfo, tracked bool) error {
+	opts := w.s.Options
+	if info.Mode()&fs.ModeSymlink != 0 {
+		if !opts.FollowSymlinks {
+			w.skip(rel, SkipSymlink)
 			return nil
 		}
-		if s.Matcher != nil && s.Matcher.Ignored(rel) {
-			if d.IsDir() {
-				return filepath.SkipDir
-			}
+		target, err := os.Stat(path)
+		if err != nil {
+			w.skip(rel, SkipBrokenSymlink)
 			return nil
 		}
-		if d.IsDir() {
+		info = target
+	}
+	if w.s.Matcher != nil {
+		match := w.s.Matcher.Match
+		if tracked {
+			match = w.s.Matcher.MatchTracked
+		}
+		if match(rel, info.IsDir()) {
 			return nil
 		}
-		info, err := d.Info()
-		if err != nil {
-			return err
+	}
+	if info.IsDir() {
+		if reason := nestedRepo(path); reason != "" && !opts.Submodules {
+			w.skip(rel, reason)
+			return nil
+		}
+		if opts.FollowSymlinks {
+			real, err := filepath.EvalSymlinks(path)
+			if err != nil {
+				return err
+			}
+			if _, ok := w.ancestors[real]; ok {
+				w.skip(rel, SkipSymlinkCycle)
+				return nil
+			}
+			w.ancestors[real] = struct{}{}
+			defer delete(w.ancestors, real)
 		}
-		files = append(files, File{Path: path, Info: info})
+		return w.dir(path, rel)
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+	limit := opts.MaxFileSize
+	if limit == 0 {
+		limit = DefaultMaxFileSize
+	}
+	if limit > 0 && info.Size() > limit {
+		w.skip(rel, SkipTooLarge)
 		return nil
-	})
-	return files, err
+	}
+	w.files = append(w.files, w.s.file(path, rel, info))
+	return nil
+}
+
+func sortFiles(files []File) {
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+}
+
+func (s *Scanner) file(path, rel string, info os.FileInfo) File {
+	test := s.Matcher != nil && s.Matcher.IsTest(rel)
+	return File{Path: path, Info: info, Test: test}
+}
+
+// nestedRepo reports whether dir is the root of a submodule (a .git file)
+// or a nested repository (a .git directory).
+func nestedRepo(dir string) string {
+	info, err := os.Lstat(filepath.Join(dir, ".git"))
+	if err != nil {
+		return ""
+	}
+	if info.IsDir() {
+		return SkipNestedRepo
+	}
+	return SkipSubmodule
+}
+
+// builtinSkip reports paths scry never indexes: git metadata and scry
+// workspaces, at any depth.
+func builtinSkip(rel string) bool {
+	for _, part := range strings.Split(rel, "/") {
+		if part == ".git" || part == ".scry" {
+			return true
+		}
+	}
+	return false
 }
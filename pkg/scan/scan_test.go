@@ -1,8 +1,12 @@
 package scan
 
 import (
+	"context"
+	"errors"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -37,7 +41,7 @@ func TestScannerRespectsIgnore(t *testing.T) {
 	if err != nil {
 		t.Fatalf("new scanner: %v", err)
 	}
-	files, err := scanner.ListFiles()
+	files, err := scanner.ListFiles(context.Background())
 	if err != nil {
 		t.Fatalf("list files: %v", err)
 	}
@@ -75,7 +79,7 @@ func TestScannerSkipsGitAndScryDirs(t *testing.T) {
 	if err != nil {
 		t.Fatalf("new scanner: %v", err)
 	}
-	files, err := scanner.ListFiles()
+	files, err := scanner.ListFiles(context.Background())
 	if err != nil {
 		t.Fatalf("list files: %v", err)
 	}
@@ -104,7 +108,7 @@ func TestScannerListFilesWithoutMatcher(t *testing.T) {
 		t.Fatalf("write main: %v", err)
 	}
 	scanner := &Scanner{Root: root, Matcher: nil}
-	files, err := scanner.ListFiles()
+	files, err := scanner.ListFiles(context.Background())
 	if err != nil {
 		t.Fatalf("list files: %v", err)
 	}
@@ -124,7 +128,441 @@ func TestScannerListFilesError(t *testing.T) {
 	})
 
 	scanner := &Scanner{Root: root, Matcher: nil}
-	if _, err := scanner.ListFiles(); err == nil {
+	if _, err := scanner.ListFiles(context.Background()); err == nil {
 		t.Fatalf("expected error walking blocked dir")
 	}
 }
+
+func TestScannerNestedGitignoreAndNegation(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		".gitignore":        "*.gen.go\n",
+		"pkg/.gitignore":    "!keep.gen.go\n/local/\n",
+		"pkg/keep.gen.go":   "package pkg",
+		"pkg/drop.gen.go":   "package pkg",
+		"pkg/local/x.go":    "package local",
+		"other/local/y.go":  "package local",
+		"other/drop.gen.go": "package other",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	scanner, err := New(root)
+	if err != nil {
+		t.Fatalf("new scanner: %v", err)
+	}
+	listed, err := scanner.ListFiles(context.Background())
+	if err != nil {
+		t.Fatalf("list files: %v", err)
+	}
+	got := map[string]bool{}
+	for _, f := range listed {
+		rel, _ := filepath.Rel(root, f.Path)
+		got[filepath.ToSlash(rel)] = true
+	}
+	for _, want := range []string{"pkg/keep.gen.go", "other/local/y.go"} {
+		if !got[want] {
+			t.Fatalf("expected %s to be listed, got %v", want, got)
+		}
+	}
+	for _, unwanted := range []string{"pkg/drop.gen.go", "pkg/local/x.go", "other/drop.gen.go"} {
+		if got[unwanted] {
+			t.Fatalf("did not expect %s to be listed", unwanted)
+		}
+	}
+}
+
+func requireGit(t *testing.T) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skipf("git not found: %v", err)
+	}
+}
+
+func runGit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Env = append(os.Environ(), "GIT_CONFIG_GLOBAL=/dev/null", "GIT_CONFIG_NOSYSTEM=1")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v: %s", args, err, out)
+	}
+}
+
+func listedRel(t *testing.T, root string, files []File) []string {
+	t.Helper()
+	var out []string
+	for _, f := range files {
+		rel, err := filepath.Rel(root, f.Path)
+		if err != nil {
+			t.Fatalf("rel: %v", err)
+		}
+		out = append(out, filepath.ToSlash(rel))
+	}
+	return out
+}
+
+func TestScannerGitMode(t *testing.T) {
+	requireGit(t)
+	root := t.TempDir()
+	runGit(t, root, "init", "-q")
+	files := map[string]string{
+		".gitignore":      "*.tmp\nvendor/\n",
+		".scryignore":     "skip.go\n",
+		"forced.tmp":      "kept",
+		"vendor/lib.go":   "package lib",
+		"tracked.go":      "package main",
+		"skip.go":         "package main",
+		"sub/tracked.md":  "# doc",
+		"gone.go":         "package main",
+		"untracked.go":    "package main",
+		"scratch.tmp":     "junk",
+		"sub/scratch.tmp": "junk",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	runGit(t, root, "add", ".gitignore", ".scryignore", "tracked.go", "skip.go", "sub/tracked.md", "gone.go")
+	if err := os.Remove(filepath.Join(root, "gone.go")); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	// Tracked files are listed even where .gitignore would exclude them.
+	runGit(t, root, "add", "-f", "forced.tmp", "vendor/lib.go")
+
+	scanner, err := NewWithOptions(root, Options{Git: true})
+	if err != nil {
+		t.Fatalf("new scanner: %v", err)
+	}
+	listed, err := scanner.ListFiles(context.Background())
+	if err != nil {
+		t.Fatalf("list files: %v", err)
+	}
+	got := strings.Join(listedRel(t, root, listed), ",")
+	if got != ".gitignore,.scryignore,forced.tmp,sub/tracked.md,tracked.go,vendor/lib.go" {
+		t.Fatalf("unexpected tracked listing: %s", got)
+	}
+
+	scanner.Options.Untracked = true
+	listed, err = scanner.ListFiles(context.Background())
+	if err != nil {
+		t.Fatalf("list files: %v", err)
+	}
+	got = strings.Join(listedRel(t, root, listed), ",")
+	if got != ".gitignore,.scryignore,forced.tmp,sub/tracked.md,tracked.go,untracked.go,vendor/lib.go" {
+		t.Fatalf("unexpected listing with untracked: %s", got)
+	}
+
+	sub, err := NewWithOptions(filepath.Join(root, "sub"), Options{Git: true})
+	if err != nil {
+		t.Fatalf("new sub scanner: %v", err)
+	}
+	listed, err = sub.ListFiles(context.Background())
+	if err != nil {
+		t.Fatalf("list sub files: %v", err)
+	}
+	if got := strings.Join(listedRel(t, sub.Root, listed), ","); got != "tracked.md" {
+		t.Fatalf("unexpected subdirectory listing: %s", got)
+	}
+}
+
+func TestScannerGitModeFallsBackOutsideRepo(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "main.go"), []byte("package main"), 0o644); err != nil {
+		t.Fatalf("write main: %v", err)
+	}
+	scanner, err := NewWithOptions(root, Options{Git: true})
+	if err != nil {
+		t.Fatalf("new scanner: %v", err)
+	}
+	files, err := scanner.ListFiles(context.Background())
+	if err != nil {
+		t.Fatalf("list files: %v", err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("expected walker fallback to list 1 file, got %d", len(files))
+	}
+}
+
+func skippedReasons(s *Scanner) map[string]string {
+	out := map[string]string{}
+	for _, sk := range s.Skipped {
+		out[sk.Path] = sk.Reason
+	}
+	return out
+}
+
+func TestScannerSymlinkPolicy(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "real"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "real", "a.go"), []byte("package real"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	links := map[string]string{
+		"link.go":     "real/a.go",
+		"linkdir":     "real",
+		"real/loop":   "..",
+		"dangling.go": "missing.go",
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(root, filepath.FromSlash(name))); err != nil {
+			t.Skipf("symlinks unsupported: %v", err)
+		}
+	}
+
+	scanner, err := New(root)
+	if err != nil {
+		t.Fatalf("new scanner: %v", err)
+	}
+	files, err := scanner.ListFiles(context.Background())
+	if err != nil {
+		t.Fatalf("list files: %v", err)
+	}
+	if got := strings.Join(listedRel(t, root, files), ","); got != "real/a.go" {
+		t.Fatalf("expected symlinks skipped, got %s", got)
+	}
+	reasons := skippedReasons(scanner)
+	if reasons["link.go"] != SkipSymlink || reasons["linkdir"] != SkipSymlink {
+		t.Fatalf("unexpected skip reasons: %v", reasons)
+	}
+
+	scanner.Options.FollowSymlinks = true
+	files, err = scanner.ListFiles(context.Background())
+	if err != nil {
+		t.Fatalf("list files following symlinks: %v", err)
+	}
+	// A second path to a directory is walked; only links back to a
+	// directory being walked are cycles.
+	if got := strings.Join(listedRel(t, root, files), ","); got != "link.go,linkdir/a.go,real/a.go" {
+		t.Fatalf("unexpected listing following symlinks: %s", got)
+	}
+	reasons = skippedReasons(scanner)
+	if reasons["real/loop"] != SkipSymlinkCycle || reasons["linkdir/loop"] != SkipSymlinkCycle {
+		t.Fatalf("expected links to ancestors to be skipped as cycles, got %v", reasons)
+	}
+	if _, ok := reasons["linkdir"]; ok {
+		t.Fatalf("expected linkdir to be followed, got %v", reasons)
+	}
+	if reasons["dangling.go"] != SkipBrokenSymlink {
+		t.Fatalf("expected broken symlink reason, got %v", reasons)
+	}
+}
+
+func TestScannerNestedRepoPolicy(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"nested/.git", "sub"} {
+		if err := os.MkdirAll(filepath.Join(root, filepath.FromSlash(dir)), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, "sub", ".git"), []byte("gitdir: ../.git/modules/sub\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	for _, name := range []string{"nested/a.go", "sub/b.go", "main.go"} {
+		if err := os.WriteFile(filepath.Join(root, filepath.FromSlash(name)), []byte("package x"), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	scanner, err := New(root)
+	if err != nil {
+		t.Fatalf("new scanner: %v", err)
+	}
+	files, err := scanner.ListFiles(context.Background())
+	if err != nil {
+		t.Fatalf("list files: %v", err)
+	}
+	if got := strings.Join(listedRel(t, root, files), ","); got != "main.go" {
+		t.Fatalf("expected nested repos skipped, got %s", got)
+	}
+	reasons := skippedReasons(scanner)
+	if reasons["nested"] != SkipNestedRepo || reasons["sub"] != SkipSubmodule {
+		t.Fatalf("unexpected skip reasons: %v", reasons)
+	}
+
+	scanner.Options.Submodules = true
+	files, err = scanner.ListFiles(context.Background())
+	if err != nil {
+		t.Fatalf("list files: %v", err)
+	}
+	if got := strings.Join(listedRel(t, root, files), ","); got != "main.go,nested/a.go,sub/b.go" {
+		t.Fatalf("expected nested repos included, got %s", got)
+	}
+}
+
+func TestScannerMaxFileSize(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "big.go"), make([]byte, 2048), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "small.go"), []byte("package x"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	scanner, err := NewWithOptions(root, Options{MaxFileSize: 1024})
+	if err != nil {
+		t.Fatalf("new scanner: %v", err)
+	}
+	files, err := scanner.ListFiles(context.Background())
+	if err != nil {
+		t.Fatalf("list files: %v", err)
+	}
+	if got := strings.Join(listedRel(t, root, files), ","); got != "small.go" {
+		t.Fatalf("expected big file skipped, got %s", got)
+	}
+	if reasons := skippedReasons(scanner); reasons["big.go"] != SkipTooLarge {
+		t.Fatalf("unexpected skip reasons: %v", reasons)
+	}
+	scanner.Options.MaxFileSize = -1
+	files, err = scanner.ListFiles(context.Background())
+	if err != nil {
+		t.Fatalf("list files: %v", err)
+	}
+	if len(files) != 2 || len(scanner.Skipped) != 0 {
+		t.Fatalf("expected no limit, got %d files and %v", len(files), scanner.Skipped)
+	}
+}
+
+func TestScannerGitModeSubmodules(t *testing.T) {
+	requireGit(t)
+	base := t.TempDir()
+	lib := filepath.Join(base, "lib")
+	root := filepath.Join(base, "app")
+	for _, dir := range []string{lib, root} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		runGit(t, dir, "init", "-q")
+	}
+	if err := os.WriteFile(filepath.Join(lib, "lib.go"), []byte("package lib"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	runGit(t, lib, "add", ".")
+	runGit(t, lib, "-c", "user.name=t", "-c", "user.email=t@t", "commit", "-q", "-m", "lib")
+	if err := os.WriteFile(filepath.Join(root, "main.go"), []byte("package main"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	runGit(t, root, "-c", "protocol.file.allow=always", "submodule", "add", "-q", lib, "vendor/lib")
+	runGit(t, root, "add", ".")
+
+	scanner, err := NewWithOptions(root, Options{Git: true})
+	if err != nil {
+		t.Fatalf("new scanner: %v", err)
+	}
+	files, err := scanner.ListFiles(context.Background())
+	if err != nil {
+		t.Fatalf("list files: %v", err)
+	}
+	if got := strings.Join(listedRel(t, root, files), ","); got != ".gitmodules,main.go" {
+		t.Fatalf("unexpected listing: %s", got)
+	}
+	if reasons := skippedReasons(scanner); reasons["vendor/lib"] != SkipSubmodule {
+		t.Fatalf("expected submodule skip, got %v", reasons)
+	}
+
+	scanner.Options.Submodules = true
+	files, err = scanner.ListFiles(context.Background())
+	if err != nil {
+		t.Fatalf("list files: %v", err)
+	}
+	if got := strings.Join(listedRel(t, root, files), ","); got != ".gitmodules,main.go,vendor/lib/lib.go" {
+		t.Fatalf("unexpected listing with submodules: %s", got)
+	}
+}
+
+func TestScannerListPaths(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		".gitignore":    "gen/\n",
+		"a.go":          "package a",
+		"gen/x.go":      "package gen",
+		"sub/b.go":      "package sub",
+		"sub/deep/c.go": "package deep",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	scanner, err := New(root)
+	if err != nil {
+		t.Fatalf("new scanner: %v", err)
+	}
+	listed, err := scanner.ListPaths(context.Background(), []string{"sub", "sub/b.go", "gen/x.go", "missing.go", ".git/config", "a.go"})
+	if err != nil {
+		t.Fatalf("list paths: %v", err)
+	}
+	if got := strings.Join(listedRel(t, root, listed), ","); got != "a.go,sub/b.go,sub/deep/c.go" {
+		t.Fatalf("unexpected listing: %s", got)
+	}
+}
+
+func TestScannerListPathsGitMode(t *testing.T) {
+	requireGit(t)
+	root := t.TempDir()
+	runGit(t, root, "init", "-q")
+	for _, name := range []string{"a.go", "scratch.go", "sub/b.go", "sub/new.go"} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("package p"), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	runGit(t, root, "add", "a.go", "sub/b.go")
+
+	scanner, err := NewWithOptions(root, Options{Git: true})
+	if err != nil {
+		t.Fatalf("new scanner: %v", err)
+	}
+	rels := []string{"a.go", "scratch.go", "sub"}
+	listed, err := scanner.ListPaths(context.Background(), rels)
+	if err != nil {
+		t.Fatalf("list paths: %v", err)
+	}
+	if got := strings.Join(listedRel(t, root, listed), ","); got != "a.go,sub/b.go" {
+		t.Fatalf("expected tracked files only, got %s", got)
+	}
+
+	scanner.Options.Untracked = true
+	listed, err = scanner.ListPaths(context.Background(), rels)
+	if err != nil {
+		t.Fatalf("list paths: %v", err)
+	}
+	if got := strings.Join(listedRel(t, root, listed), ","); got != "a.go,scratch.go,sub/b.go,sub/new.go" {
+		t.Fatalf("unexpected listing with untracked: %s", got)
+	}
+}
+
+func TestScannerListFilesCancelled(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "a.go"), []byte("package a"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	scanner, err := New(root)
+	if err != nil {
+		t.Fatalf("new scanner: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := scanner.ListFiles(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,69 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// Source is one repository taking part in a federated search.
+type Source struct {
+	Name   string
+	Engine *Engine
+}
+
+// Federation searches several sources as one; see Federated.
+type Federation []Source
+
+func (f Federation) Search(ctx context.Context, query string, limit int) ([]Result, error) {
+	return Federated(ctx, f, query, limit)
+}
+
+// Federated runs query against every source and merges the results, each
+// labelled with its source's name. Raw scores are not comparable between
+// indexes, so each source's scores are divided by its best score, putting
+// them in [0,1]; ties keep the raw order. Result.Raw keeps the raw score.
+func Federated(ctx context.Context, sources []Source, query string, limit int) ([]Result, error) {
+	var all []Result
+	for _, src := range sources {
+		results, err := src.Engine.Search(ctx, query, limit)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", src.Name, err)
+		}
+		if len(results) == 0 {
+			continue
+		}
+		top := results[0].Score
+		for _, r := range results {
+			if top > 0 {
+				r.Score = r.Raw / top
+				if r.Explain != nil {
+					e := *r.Explain
+					e.Scale = 1 / top
+					e.Score = r.Score
+					r.Explain = &e
+				}
+			}
+			r.Repo = src.Name
+			all = append(all, r)
+		}
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		a, b := all[i], all[j]
+		switch {
+		case a.Score != b.Score:
+			return a.Score > b.Score
+		case a.Raw != b.Raw:
+			return a.Raw > b.Raw
+		case a.Repo != b.Repo:
+			return a.Repo < b.Repo
+		case a.Chunk.FilePath != b.Chunk.FilePath:
+			return a.Chunk.FilePath < b.Chunk.FilePath
+		}
+		return a.Chunk.StartLine < b.Chunk.StartLine
+	})
+	if limit > 0 && len(all) > limit {
+		all = all[:limit]
+	}
+	return all, nil
+}
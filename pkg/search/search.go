@@ -1,7 +1,10 @@
 package search
 
 import (
+	"context"
+	"fmt"
 	"sort"
+	"strings"
 
 	"scry/pkg/index/lexical"
 	"scry/pkg/metadata"
@@ -10,35 +13,112 @@ import (
 type Result struct {
 	Chunk metadata.ChunkView
 	Score float64
+	// Raw is the engine's score for the chunk. It equals Score except in
+	// federated results, whose Score is normalised.
+	Raw float64
+	// Repo names the repository the chunk comes from in federated results.
+	Repo string
+	// Explain breaks Score down when Engine.Explain is set.
+	Explain *Explanation
+}
+
+// Explanation shows how a result's score was computed: the sum of the
+// term contributions, times TestWeight (below 1 for down-ranked test chunks),
+// times Scale (set by Federated).
+type Explanation struct {
+	Terms      []TermScore `json:"terms"`
+	TestWeight float64     `json:"test_weight"`
+	Scale      float64     `json:"scale"`
+	Score      float64     `json:"score"`
+}
+
+// TermScore is one query term's contribution: its frequency in the chunk
+// (TF) and the score it added. Scoring is TF-only; how many chunks contain a
+// term does not matter.
+type TermScore struct {
+	Term  string  `json:"term"`
+	TF    int     `json:"tf"`
+	Score float64 `json:"score"`
 }
 
 type Store interface {
-	TermHits(term string) ([]metadata.TermHit, error)
-	GetChunksByIDs(ids []string) ([]metadata.ChunkView, error)
+	TermHits(ctx context.Context, term string) ([]metadata.TermHit, error)
+	GetChunksByIDs(ctx context.Context, ids []string) ([]metadata.ChunkView, error)
+}
+
+// Searcher ranks chunks for a query. It is implemented by *Engine and
+// Federation.
+type Searcher interface {
+	Search(ctx context.Context, query string, limit int) ([]Result, error)
+}
+
+// TestMode controls how chunks flagged as tests take part in ranking.
+type TestMode string
+
+const (
+	TestsInclude  TestMode = "include"
+	TestsDownRank TestMode = "downrank"
+	TestsExclude  TestMode = "exclude"
+	TestsOnly     TestMode = "only"
+)
+
+func ParseTestMode(s string) (TestMode, error) {
+	switch m := TestMode(s); m {
+	case "":
+		return TestsInclude, nil
+	case TestsInclude, TestsDownRank, TestsExclude, TestsOnly:
+		return m, nil
+	default:
+		return "", fmt.Errorf("invalid tests mode %q (want include|downrank|exclude|only)", s)
+	}
 }
 
 type Engine struct {
 	Store Store
+	Tests TestMode
+	// TestWeight scales the score of test chunks in TestsDownRank mode,
+	// usually to the configured scoring.test_weight.
+	TestWeight float64
+	// Scope limits results to chunks of files under this slash-separated
+	// directory; empty searches everything.
+	Scope string
+	// Explain attaches an Explanation to every result.
+	Explain bool
+	// Analyzer splits queries into terms. It must match the analyzer the
+	// index was built with.
+	Analyzer lexical.Analyzer
 }
 
 func New(store Store) *Engine {
 	return &Engine{Store: store}
 }
 
-func (e *Engine) Search(query string, limit int) ([]Result, error) {
-	terms := lexical.Tokenize(query)
+// Search ranks chunks matching query. Store lookups run under ctx, so a
+// cancelled or expired ctx aborts the search with its error.
+func (e *Engine) Search(ctx context.Context, query string, limit int) ([]Result, error) {
+	terms := e.Analyzer.Terms(query)
 	if len(terms) == 0 {
 		return nil, nil
 	}
 
 	scores := map[string]float64{}
+	var contributions map[string][]TermScore
+	if e.Explain {
+		contributions = map[string][]TermScore{}
+	}
 	for _, term := range terms {
-		hits, err := e.Store.TermHits(term)
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		hits, err := e.Store.TermHits(ctx, term)
 		if err != nil {
 			return nil, err
 		}
 		for _, h := range hits {
 			scores[h.ChunkID] += float64(h.TF)
+			if contributions != nil {
+				contributions[h.ChunkID] = append(contributions[h.ChunkID], TermScore{Term: term, TF: h.TF, Score: float64(h.TF)})
+			}
 		}
 	}
 	if len(scores) == 0 {
@@ -49,14 +129,42 @@ func (e *Engine) Search(query string, limit int) ([]Result, error) {
 	for id := range scores {
 		ids = append(ids, id)
 	}
-	chunks, err := e.Store.GetChunksByIDs(ids)
+	chunks, err := e.Store.GetChunksByIDs(ctx, ids)
 	if err != nil {
 		return nil, err
 	}
 
 	results := make([]Result, 0, len(chunks))
 	for _, ch := range chunks {
-		results = append(results, Result{Chunk: ch, Score: scores[ch.ID]})
+		if !inScope(ch.FilePath, e.Scope) {
+			continue
+		}
+		score := scores[ch.ID]
+		var explain *Explanation
+		if contributions != nil {
+			explain = &Explanation{Terms: contributions[ch.ID], TestWeight: 1, Scale: 1}
+		}
+		switch e.Tests {
+		case TestsExclude:
+			if ch.Test {
+				continue
+			}
+		case TestsOnly:
+			if !ch.Test {
+				continue
+			}
+		case TestsDownRank:
+			if ch.Test {
+				score *= e.TestWeight
+				if explain != nil {
+					explain.TestWeight = e.TestWeight
+				}
+			}
+		}
+		if explain != nil {
+			explain.Score = score
+		}
+		results = append(results, Result{Chunk: ch, Score: score, Raw: score, Explain: explain})
 	}
 
 	sort.Slice(results, func(i, j int) bool {
@@ -71,3 +179,8 @@ func (e *Engine) Search(query string, limit int) ([]Result, error) {
 	}
 	return results, nil
 }
+
+func inScope(path, scope string) bool {
+	scope = strings.Trim(scope, "/")
+	return scope == "" || scope == "." || path == scope || strings.HasPrefix(path, scope+"/")
+}
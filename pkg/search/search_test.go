@@ -1,8 +1,11 @@
 package search
 
 import (
+	"context"
+	"errors"
 	"os/exec"
 	"path/filepath"
+	"reflect"
 	"testing"
 
 	"scry/pkg/metadata"
@@ -19,7 +22,7 @@ func TestSearchRanksAndLimits(t *testing.T) {
 	requireSQLite(t)
 	root := t.TempDir()
 	dbPath := filepath.Join(root, "index.db")
-	store, err := metadata.Open(dbPath)
+	store, err := metadata.Open(context.Background(), dbPath)
 	if err != nil {
 		t.Fatalf("open db: %v", err)
 	}
@@ -29,7 +32,7 @@ func TestSearchRanksAndLimits(t *testing.T) {
 	terms1 := []metadata.TermRecord{
 		{Term: "alpha", ChunkID: "c1", TF: 2},
 	}
-	if err := store.ReplaceFileData(file1, []metadata.ChunkRecord{chunk1}, terms1); err != nil {
+	if err := store.ReplaceFileData(context.Background(), file1, []metadata.ChunkRecord{chunk1}, terms1); err != nil {
 		t.Fatalf("replace file1: %v", err)
 	}
 
@@ -39,12 +42,12 @@ func TestSearchRanksAndLimits(t *testing.T) {
 		{Term: "alpha", ChunkID: "c2", TF: 1},
 		{Term: "beta", ChunkID: "c2", TF: 1},
 	}
-	if err := store.ReplaceFileData(file2, []metadata.ChunkRecord{chunk2}, terms2); err != nil {
+	if err := store.ReplaceFileData(context.Background(), file2, []metadata.ChunkRecord{chunk2}, terms2); err != nil {
 		t.Fatalf("replace file2: %v", err)
 	}
 
 	engine := New(store)
-	results, err := engine.Search("alpha beta", 1)
+	results, err := engine.Search(context.Background(), "alpha beta", 1)
 	if err != nil {
 		t.Fatalf("search: %v", err)
 	}
@@ -60,13 +63,13 @@ func TestSearchEmptyOrNoHits(t *testing.T) {
 	requireSQLite(t)
 	root := t.TempDir()
 	dbPath := filepath.Join(root, "index.db")
-	store, err := metadata.Open(dbPath)
+	store, err := metadata.Open(context.Background(), dbPath)
 	if err != nil {
 		t.Fatalf("open db: %v", err)
 	}
 	engine := New(store)
 
-	results, err := engine.Search("a", 10)
+	results, err := engine.Search(context.Background(), "a", 10)
 	if err != nil {
 		t.Fatalf("search short query: %v", err)
 	}
@@ -74,7 +77,7 @@ func TestSearchEmptyOrNoHits(t *testing.T) {
 		t.Fatalf("expected nil results for empty terms")
 	}
 
-	results, err = engine.Search("gamma", 10)
+	results, err = engine.Search(context.Background(), "gamma", 10)
 	if err != nil {
 		t.Fatalf("search no hits: %v", err)
 	}
@@ -87,7 +90,7 @@ func TestSearchNoLimit(t *testing.T) {
 	requireSQLite(t)
 	root := t.TempDir()
 	dbPath := filepath.Join(root, "index.db")
-	store, err := metadata.Open(dbPath)
+	store, err := metadata.Open(context.Background(), dbPath)
 	if err != nil {
 		t.Fatalf("open db: %v", err)
 	}
@@ -95,12 +98,12 @@ func TestSearchNoLimit(t *testing.T) {
 	file := metadata.FileRecord{Path: "file.go", Hash: "h1", MTime: 1, Size: 10}
 	chunk := metadata.ChunkRecord{ID: "c1", FilePath: "file.go", StartLine: 1, EndLine: 1, Hash: "ch1", Content: "alpha"}
 	terms := []metadata.TermRecord{{Term: "alpha", ChunkID: "c1", TF: 1}}
-	if err := store.ReplaceFileData(file, []metadata.ChunkRecord{chunk}, terms); err != nil {
+	if err := store.ReplaceFileData(context.Background(), file, []metadata.ChunkRecord{chunk}, terms); err != nil {
 		t.Fatalf("replace file: %v", err)
 	}
 
 	engine := New(store)
-	results, err := engine.Search("alpha", 0)
+	results, err := engine.Search(context.Background(), "alpha", 0)
 	if err != nil {
 		t.Fatalf("search: %v", err)
 	}
@@ -116,14 +119,14 @@ type fakeStore struct {
 	chunkErr error
 }
 
-func (f *fakeStore) TermHits(term string) ([]metadata.TermHit, error) {
+func (f *fakeStore) TermHits(ctx context.Context, term string) ([]metadata.TermHit, error) {
 	if f.termErr != nil {
 		return nil, f.termErr
 	}
 	return f.termHits[term], nil
 }
 
-func (f *fakeStore) GetChunksByIDs(ids []string) ([]metadata.ChunkView, error) {
+func (f *fakeStore) GetChunksByIDs(ctx context.Context, ids []string) ([]metadata.ChunkView, error) {
 	if f.chunkErr != nil {
 		return nil, f.chunkErr
 	}
@@ -132,7 +135,7 @@ func (f *fakeStore) GetChunksByIDs(ids []string) ([]metadata.ChunkView, error) {
 
 func TestSearchTermHitsError(t *testing.T) {
 	engine := New(&fakeStore{termErr: errSentinel{}})
-	if _, err := engine.Search("alpha", 10); err == nil {
+	if _, err := engine.Search(context.Background(), "alpha", 10); err == nil {
 		t.Fatalf("expected error")
 	}
 }
@@ -144,7 +147,7 @@ func TestSearchChunkFetchError(t *testing.T) {
 		},
 		chunkErr: errSentinel{},
 	})
-	if _, err := engine.Search("alpha", 10); err == nil {
+	if _, err := engine.Search(context.Background(), "alpha", 10); err == nil {
 		t.Fatalf("expected error")
 	}
 }
@@ -152,3 +155,186 @@ func TestSearchChunkFetchError(t *testing.T) {
 type errSentinel struct{}
 
 func (errSentinel) Error() string { return "boom" }
+
+func TestSearchTestModes(t *testing.T) {
+	store := &fakeStore{
+		termHits: map[string][]metadata.TermHit{
+			"alpha": {{ChunkID: "c1", TF: 3}, {ChunkID: "c2", TF: 2}},
+		},
+		chunks: []metadata.ChunkView{
+			{ID: "c1", FilePath: "a_test.go", Test: true},
+			{ID: "c2", FilePath: "a.go"},
+		},
+	}
+	cases := []struct {
+		mode TestMode
+		want []string
+	}{
+		{TestsInclude, []string{"c1", "c2"}},
+		{TestsDownRank, []string{"c2", "c1"}},
+		{TestsExclude, []string{"c2"}},
+		{TestsOnly, []string{"c1"}},
+	}
+	for _, c := range cases {
+		engine := New(store)
+		engine.Tests, engine.TestWeight = c.mode, 0.5
+		results, err := engine.Search(context.Background(), "alpha", 10)
+		if err != nil {
+			t.Fatalf("mode %s: search: %v", c.mode, err)
+		}
+		if len(results) != len(c.want) {
+			t.Fatalf("mode %s: expected %d results, got %d", c.mode, len(c.want), len(results))
+		}
+		for i, id := range c.want {
+			if results[i].Chunk.ID != id {
+				t.Fatalf("mode %s: result %d expected %s got %s", c.mode, i, id, results[i].Chunk.ID)
+			}
+		}
+	}
+}
+
+func TestParseTestMode(t *testing.T) {
+	if m, err := ParseTestMode(""); err != nil || m != TestsInclude {
+		t.Fatalf("expected include for empty mode, got %q err=%v", m, err)
+	}
+	if m, err := ParseTestMode("downrank"); err != nil || m != TestsDownRank {
+		t.Fatalf("expected downrank, got %q err=%v", m, err)
+	}
+	if _, err := ParseTestMode("sometimes"); err == nil {
+		t.Fatalf("expected error for invalid mode")
+	}
+}
+
+func TestSearchCancelled(t *testing.T) {
+	engine := New(&fakeStore{
+		termHits: map[string][]metadata.TermHit{
+			"alpha": {{ChunkID: "c1", TF: 1}},
+		},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := engine.Search(ctx, "alpha", 10); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestSearchScope(t *testing.T) {
+	engine := New(&fakeStore{
+		termHits: map[string][]metadata.TermHit{
+			"alpha": {{ChunkID: "c1", TF: 1}, {ChunkID: "c2", TF: 2}, {ChunkID: "c3", TF: 3}},
+		},
+		chunks: []metadata.ChunkView{
+			{ID: "c1", FilePath: "pkg/scan/scan.go"},
+			{ID: "c2", FilePath: "pkg/scanner/x.go"},
+			{ID: "c3", FilePath: "cmd/scry/main.go"},
+		},
+	})
+	engine.Scope = "pkg/scan"
+	results, err := engine.Search(context.Background(), "alpha", 10)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(results) != 1 || results[0].Chunk.ID != "c1" {
+		t.Fatalf("expected only pkg/scan results, got %+v", results)
+	}
+	engine.Scope = "."
+	if results, _ := engine.Search(context.Background(), "alpha", 10); len(results) != 3 {
+		t.Fatalf("expected root scope to keep all results, got %d", len(results))
+	}
+}
+
+func TestFederatedNormalisesScores(t *testing.T) {
+	big := New(&fakeStore{
+		termHits: map[string][]metadata.TermHit{
+			"alpha": {{ChunkID: "b1", TF: 10}, {ChunkID: "b2", TF: 5}},
+		},
+		chunks: []metadata.ChunkView{{ID: "b1", FilePath: "x.go"}, {ID: "b2", FilePath: "y.go"}},
+	})
+	small := New(&fakeStore{
+		termHits: map[string][]metadata.TermHit{
+			"alpha": {{ChunkID: "s1", TF: 2}, {ChunkID: "s2", TF: 1}},
+		},
+		chunks: []metadata.ChunkView{{ID: "s1", FilePath: "x.go"}, {ID: "s2", FilePath: "y.go"}},
+	})
+	sources := []Source{{Name: "small", Engine: small}, {Name: "big", Engine: big}}
+	results, err := Federated(context.Background(), sources, "alpha", 3)
+	if err != nil {
+		t.Fatalf("federated: %v", err)
+	}
+	want := []struct {
+		repo, id   string
+		score, raw float64
+	}{{"big", "b1", 1, 10}, {"small", "s1", 1, 2}, {"big", "b2", 0.5, 5}}
+	if len(results) != len(want) {
+		t.Fatalf("expected %d results, got %+v", len(want), results)
+	}
+	for i, w := range want {
+		r := results[i]
+		if r.Repo != w.repo || r.Chunk.ID != w.id || r.Score != w.score || r.Raw != w.raw {
+			t.Fatalf("result %d: expected %s/%s %.1f (raw %.1f), got %s/%s %.1f (raw %.1f)", i, w.repo, w.id, w.score, w.raw, r.Repo, r.Chunk.ID, r.Score, r.Raw)
+		}
+	}
+
+	failing := []Source{{Name: "broken", Engine: New(&fakeStore{termErr: errSentinel{}})}}
+	if _, err := Federated(context.Background(), failing, "alpha", 3); err == nil || err.Error() != "broken: boom" {
+		t.Fatalf("expected error labelled with repo, got %v", err)
+	}
+}
+
+func TestSearchExplain(t *testing.T) {
+	store := &fakeStore{
+		termHits: map[string][]metadata.TermHit{
+			"alpha": {{ChunkID: "c1", TF: 3}, {ChunkID: "c2", TF: 1}},
+			"beta":  {{ChunkID: "c1", TF: 2}},
+		},
+		chunks: []metadata.ChunkView{
+			{ID: "c1", FilePath: "a_test.go", Test: true},
+			{ID: "c2", FilePath: "a.go"},
+		},
+	}
+	engine := New(store)
+	engine.Tests, engine.TestWeight = TestsDownRank, 0.5
+	results, err := engine.Search(context.Background(), "alpha beta", 10)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if results[0].Explain != nil {
+		t.Fatalf("expected no explanation unless asked")
+	}
+
+	engine.Explain = true
+	results, err = engine.Search(context.Background(), "alpha beta", 10)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	var c1 *Explanation
+	for _, r := range results {
+		if r.Explain == nil || r.Explain.Score != r.Score {
+			t.Fatalf("%s: explanation does not match score %v: %+v", r.Chunk.ID, r.Score, r.Explain)
+		}
+		if r.Chunk.ID == "c1" {
+			c1 = r.Explain
+		}
+	}
+	want := []TermScore{{Term: "alpha", TF: 3, Score: 3}, {Term: "beta", TF: 2, Score: 2}}
+	if c1 == nil || !reflect.DeepEqual(c1.Terms, want) || c1.TestWeight != 0.5 || c1.Scale != 1 || c1.Score != 2.5 {
+		t.Fatalf("unexpected explanation: %+v", c1)
+	}
+
+	sources := []Source{
+		{Name: "one", Engine: engine},
+		{Name: "two", Engine: &Engine{Store: &fakeStore{
+			termHits: map[string][]metadata.TermHit{"alpha": {{ChunkID: "x", TF: 10}}},
+			chunks:   []metadata.ChunkView{{ID: "x", FilePath: "x.go"}},
+		}, Explain: true}},
+	}
+	results, err = Federated(context.Background(), sources, "alpha beta", 10)
+	if err != nil {
+		t.Fatalf("federated: %v", err)
+	}
+	for _, r := range results {
+		if r.Repo == "one" && (r.Explain.Scale != 0.4 || r.Explain.Score != r.Score) {
+			t.Fatalf("expected scale recorded, got %+v for score %v", r.Explain, r.Score)
+		}
+	}
+}
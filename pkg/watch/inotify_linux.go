@@ -0,0 +1,106 @@
+package watch
+
+import (
+	"encoding/binary"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+const inotifyMask = syscall.IN_CREATE | syscall.IN_CLOSE_WRITE | syscall.IN_MODIFY |
+	syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_ONLYDIR
+
+type inotify struct {
+	fd   int
+	file *os.File
+	mu   sync.Mutex
+	// dirs maps watch descriptors to directories relative to the root.
+	dirs map[int32]string
+}
+
+func newBackend() (backend, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	// A non-blocking descriptor is served by the runtime poller, so Close
+	// interrupts a pending Read.
+	return &inotify{fd: fd, file: os.NewFile(uintptr(fd), "inotify"), dirs: map[int32]string{}}, nil
+}
+
+func (in *inotify) add(dir, rel string) error {
+	wd, err := syscall.InotifyAddWatch(in.fd, dir, inotifyMask)
+	if err != nil {
+		if err == syscall.ENOENT || err == syscall.ENOTDIR {
+			return os.ErrNotExist
+		}
+		return &os.PathError{Op: "inotify_add_watch", Path: dir, Err: err}
+	}
+	in.mu.Lock()
+	in.dirs[int32(wd)] = rel
+	in.mu.Unlock()
+	return nil
+}
+
+func (in *inotify) remove(rel string) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	for wd, dir := range in.dirs {
+		if dir == rel || strings.HasPrefix(dir, rel+"/") {
+			_, _ = syscall.InotifyRmWatch(in.fd, uint32(wd))
+			delete(in.dirs, wd)
+		}
+	}
+}
+
+func (in *inotify) read() ([]event, error) {
+	var buf [64 * 1024]byte
+	n, err := in.file.Read(buf[:])
+	if err != nil {
+		return nil, err
+	}
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	var events []event
+	for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+		wd := int32(binary.NativeEndian.Uint32(buf[off:]))
+		mask := binary.NativeEndian.Uint32(buf[off+4:])
+		size := int(binary.NativeEndian.Uint32(buf[off+12:]))
+		nameStart := off + syscall.SizeofInotifyEvent
+		if nameStart+size > n {
+			break
+		}
+		name := strings.TrimRight(string(buf[nameStart:nameStart+size]), "\x00")
+		off = nameStart + size
+
+		switch {
+		case mask&syscall.IN_Q_OVERFLOW != 0:
+			events = append(events, event{overflow: true})
+			continue
+		case mask&syscall.IN_IGNORED != 0:
+			delete(in.dirs, wd)
+			continue
+		}
+		dir, ok := in.dirs[wd]
+		if !ok || name == "" {
+			continue
+		}
+		rel := name
+		if dir != "" {
+			rel = path.Join(dir, name)
+		}
+		events = append(events, event{
+			rel:     rel,
+			dir:     mask&syscall.IN_ISDIR != 0,
+			created: mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0,
+			moved:   mask&syscall.IN_MOVED_FROM != 0,
+		})
+	}
+	return events, nil
+}
+
+func (in *inotify) close() error {
+	return in.file.Close()
+}
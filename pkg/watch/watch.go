@@ -0,0 +1,248 @@
+// Package watch reports batches of changed paths below a directory tree.
+package watch
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"scry/pkg/ignore"
+)
+
+// DefaultDebounce is the quiet period used when Options.Debounce is zero.
+const DefaultDebounce = 200 * time.Millisecond
+
+// ErrUnsupported is returned by New on platforms without a watcher backend.
+var ErrUnsupported = errors.New("watching is not supported on this platform")
+
+type Options struct {
+	Ignore ignore.Options
+	// Debounce is how long events must stop arriving before a batch is
+	// delivered. A steady stream of events is flushed every ten intervals.
+	Debounce time.Duration
+}
+
+// Batch is a set of changed paths, relative to the root and sorted. Full is
+// set when changes may have been missed, after an event queue overflow or an
+// ignore file change, and the whole tree should be rescanned.
+type Batch struct {
+	Paths []string
+	Full  bool
+}
+
+// event is one change reported by a backend, relative to the root.
+type event struct {
+	rel      string
+	dir      bool
+	created  bool
+	moved    bool
+	overflow bool
+}
+
+// backend is the platform notification mechanism.
+type backend interface {
+	// add watches the directory at path, reporting changes relative to rel.
+	add(path, rel string) error
+	// remove stops watching rel and the directories below it.
+	remove(rel string)
+	// read blocks until events are available or the backend is closed.
+	read() ([]event, error)
+	close() error
+}
+
+type Watcher struct {
+	root      string
+	opts      Options
+	matcher   *ignore.Matcher
+	backend   backend
+	closeOnce sync.Once
+}
+
+// New watches every directory below root that the ignore rules do not
+// exclude. Directories created later are watched as they appear.
+func New(root string, opts Options) (*Watcher, error) {
+	matcher, err := ignore.LoadOptions(root, opts.Ignore)
+	if err != nil {
+		return nil, err
+	}
+	b, err := newBackend()
+	if err != nil {
+		return nil, err
+	}
+	w := &Watcher{root: root, opts: opts, matcher: matcher, backend: b}
+	if err := w.addTree(""); err != nil {
+		w.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *Watcher) Close() error {
+	var err error
+	w.closeOnce.Do(func() { err = w.backend.close() })
+	return err
+}
+
+// Run delivers batches to fn until ctx is done, then closes the watcher and
+// returns nil. An error from fn or the backend stops it early.
+func (w *Watcher) Run(ctx context.Context, fn func(Batch) error) error {
+	defer w.Close()
+	debounce := w.opts.Debounce
+	if debounce <= 0 {
+		debounce = DefaultDebounce
+	}
+
+	events := make(chan []event)
+	errs := make(chan error, 1)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			evs, err := w.backend.read()
+			if err != nil {
+				errs <- err
+				return
+			}
+			select {
+			case events <- evs:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	pending := map[string]struct{}{}
+	full := false
+	var first time.Time
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-errs:
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		case evs := <-events:
+			changed := false
+			for _, ev := range evs {
+				if w.handle(ev, pending, &full) {
+					changed = true
+				}
+			}
+			if !changed {
+				continue
+			}
+			now := time.Now()
+			if first.IsZero() {
+				first = now
+			}
+			wait := debounce
+			if limit := first.Add(10 * debounce).Sub(now); limit < wait {
+				wait = limit
+			}
+			timer.Reset(wait)
+		case <-timer.C:
+			batch := Batch{Full: full}
+			if !full {
+				for rel := range pending {
+					batch.Paths = append(batch.Paths, rel)
+				}
+				sort.Strings(batch.Paths)
+			}
+			pending = map[string]struct{}{}
+			full = false
+			first = time.Time{}
+			if err := fn(batch); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// handle records ev, reporting whether it is relevant.
+func (w *Watcher) handle(ev event, pending map[string]struct{}, full *bool) bool {
+	if ev.overflow {
+		*full = true
+		return true
+	}
+	if builtinSkip(ev.rel) {
+		return false
+	}
+	if ev.dir && ev.moved {
+		// Watches inside a renamed directory would keep reporting its old
+		// path; the new location is watched afresh.
+		w.backend.remove(ev.rel)
+	}
+	if ignore.IsIgnoreFile(path.Base(ev.rel)) {
+		if matcher, err := ignore.LoadOptions(w.root, w.opts.Ignore); err == nil {
+			w.matcher = matcher
+		}
+		*full = true
+		return true
+	}
+	if w.matcher.Match(ev.rel, ev.dir) {
+		return false
+	}
+	if ev.dir && ev.created {
+		// Files created before the watch was added are covered by listing
+		// the directory when the batch is indexed.
+		_ = w.addTree(ev.rel)
+	}
+	pending[ev.rel] = struct{}{}
+	return true
+}
+
+// addTree watches rel and the directories below it that are not ignored.
+// Symlinked directories are not followed.
+func (w *Watcher) addTree(rel string) error {
+	dir := filepath.Join(w.root, filepath.FromSlash(rel))
+	if err := w.backend.add(dir, rel); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		child := e.Name()
+		if rel != "" {
+			child = rel + "/" + child
+		}
+		if builtinSkip(child) || w.matcher.Match(child, true) {
+			continue
+		}
+		if err := w.addTree(child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// builtinSkip mirrors the scanner: git metadata and scry workspaces are
+// never indexed, and the index itself changes while indexing.
+func builtinSkip(rel string) bool {
+	for _, part := range strings.Split(rel, "/") {
+		if part == ".git" || part == ".scry" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func startWatcher(t *testing.T, root string) (<-chan Batch, func()) {
+	t.Helper()
+	w, err := New(root, Options{Debounce: 50 * time.Millisecond})
+	if errors.Is(err, ErrUnsupported) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatalf("new watcher: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	batches := make(chan Batch, 16)
+	done := make(chan error, 1)
+	go func() {
+		done <- w.Run(ctx, func(b Batch) error {
+			batches <- b
+			return nil
+		})
+	}()
+	stop := func() {
+		cancel()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("run: %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Errorf("watcher did not stop")
+		}
+	}
+	return batches, stop
+}
+
+func nextBatch(t *testing.T, batches <-chan Batch) Batch {
+	t.Helper()
+	select {
+	case b := <-batches:
+		return b
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for a batch")
+	}
+	return Batch{}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+}
+
+// waitPaths reads batches until each of want has been reported. Batches
+// may be split however the events were timed, so they are merged; a path
+// outside want, other than one below a wanted directory, fails the test.
+func waitPaths(t *testing.T, batches <-chan Batch, want ...string) {
+	t.Helper()
+	seen := map[string]bool{}
+	deadline := time.After(5 * time.Second)
+	for len(seen) < len(want) {
+		select {
+		case b := <-batches:
+			if b.Full {
+				t.Fatalf("unexpected full rescan waiting for %v", want)
+			}
+			for _, p := range b.Paths {
+				matched := false
+				for _, w := range want {
+					if p == w {
+						seen[w], matched = true, true
+					} else if strings.HasPrefix(p, w+"/") {
+						matched = true
+					}
+				}
+				if !matched {
+					t.Fatalf("unexpected path %s waiting for %v", p, want)
+				}
+			}
+		case <-deadline:
+			t.Fatalf("timed out waiting for %v, saw %v", want, seen)
+		}
+	}
+}
+
+func TestWatcherDebouncesAndFilters(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, ".gitignore"), "build/\n")
+	writeFile(t, filepath.Join(root, "build", "out.go"), "x")
+	writeFile(t, filepath.Join(root, "pkg", "a.go"), "package pkg")
+	batches, stop := startWatcher(t, root)
+	defer stop()
+
+	writeFile(t, filepath.Join(root, "build", "out.go"), "y")
+	writeFile(t, filepath.Join(root, ".scry", "index.db"), "db")
+	for i := 0; i < 3; i++ {
+		writeFile(t, filepath.Join(root, "pkg", "a.go"), strings.Repeat("x", i))
+		writeFile(t, filepath.Join(root, "main.go"), strings.Repeat("y", i))
+	}
+	waitPaths(t, batches, "main.go", "pkg/a.go")
+
+	if err := os.Remove(filepath.Join(root, "main.go")); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	waitPaths(t, batches, "main.go")
+}
+
+func TestWatcherNewAndRenamedDirectories(t *testing.T) {
+	root := t.TempDir()
+	batches, stop := startWatcher(t, root)
+	defer stop()
+
+	// New directories are watched before their batch is delivered.
+	writeFile(t, filepath.Join(root, "sub", "deep", "a.go"), "package deep")
+	waitPaths(t, batches, "sub")
+	writeFile(t, filepath.Join(root, "sub", "deep", "b.go"), "package deep")
+	waitPaths(t, batches, "sub/deep/b.go")
+
+	if err := os.Rename(filepath.Join(root, "sub"), filepath.Join(root, "moved")); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+	waitPaths(t, batches, "moved", "sub")
+	writeFile(t, filepath.Join(root, "moved", "deep", "c.go"), "package deep")
+	waitPaths(t, batches, "moved/deep/c.go")
+}
+
+func TestWatcherIgnoreFileChangeRescans(t *testing.T) {
+	root := t.TempDir()
+	batches, stop := startWatcher(t, root)
+	defer stop()
+
+	writeFile(t, filepath.Join(root, ".scryignore"), "gen/\n")
+	if b := nextBatch(t, batches); !b.Full {
+		t.Fatalf("expected full rescan after ignore file change, got %+v", b)
+	}
+	writeFile(t, filepath.Join(root, "gen", "x.go"), "x")
+	writeFile(t, filepath.Join(root, "y.go"), "y")
+	waitPaths(t, batches, "y.go")
+}
@@ -0,0 +1,222 @@
+package workspace
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"scry/pkg/gitrepo"
+	"scry/pkg/ignore"
+)
+
+// Actions reported by Init.
+const (
+	InitCreated   = "created"
+	InitUpdated   = "updated"
+	InitUnchanged = "unchanged"
+	InitSkipped   = "skipped"
+)
+
+// InitOptions controls Init.
+type InitOptions struct {
+	// ConfigPath receives Config when it does not exist yet.
+	ConfigPath string
+	Config     string
+	// Gitignore adds .scry/ to the root .gitignore when git does not
+	// already ignore it.
+	Gitignore bool
+}
+
+// InitStep describes what Init did to one path, relative to the root.
+type InitStep struct {
+	Path     string   `json:"path"`
+	Action   string   `json:"action"`
+	Detail   string   `json:"detail,omitempty"`
+	Patterns []string `json:"patterns,omitempty"`
+}
+
+// Suggestion is an ignore pattern proposed for a repository.
+type Suggestion struct {
+	Pattern string
+	Reason  string
+}
+
+// ecosystems maps marker files to the dependency and build directories of
+// their toolchains.
+var ecosystems = []struct {
+	markers  []string
+	patterns []string
+}{
+	{[]string{"go.mod"}, []string{"vendor/"}},
+	{[]string{"package.json"}, []string{"node_modules/", "dist/", "coverage/"}},
+	{[]string{"Cargo.toml"}, []string{"target/"}},
+	{[]string{"pyproject.toml", "setup.py", "requirements.txt"}, []string{"__pycache__/", ".venv/"}},
+	{[]string{"pom.xml", "build.gradle", "build.gradle.kts"}, []string{"target/", "build/"}},
+}
+
+// buildDirs are suggested whenever they exist at the root.
+var buildDirs = []string{"vendor/", "node_modules/", "dist/", "build/", "out/", "target/", ".venv/"}
+
+// SuggestIgnores proposes ignore patterns for dependency and build
+// directories, based on the toolchain marker files and directories found at
+// root. Patterns already ignored are left out.
+func SuggestIgnores(root string) ([]Suggestion, error) {
+	matcher, err := ignore.LoadOptions(root, ignore.Options{IncludeTests: true})
+	if err != nil {
+		return nil, err
+	}
+	var out []Suggestion
+	seen := map[string]bool{}
+	add := func(pattern, reason string) {
+		if seen[pattern] || matcher.Match(strings.TrimSuffix(pattern, "/"), true) {
+			return
+		}
+		seen[pattern] = true
+		out = append(out, Suggestion{Pattern: pattern, Reason: reason})
+	}
+	for _, eco := range ecosystems {
+		for _, marker := range eco.markers {
+			if _, err := os.Stat(filepath.Join(root, marker)); err != nil {
+				continue
+			}
+			for _, p := range eco.patterns {
+				add(p, marker)
+			}
+			break
+		}
+	}
+	for _, dir := range buildDirs {
+		if info, err := os.Stat(filepath.Join(root, dir)); err == nil && info.IsDir() {
+			add(dir, "directory exists")
+		}
+	}
+	return out, nil
+}
+
+// Init prepares paths.Root for indexing: it creates the workspace directory,
+// writes a starter config, records suggested ignore patterns in .scryignore
+// and, if asked, makes git ignore the workspace. Running it again changes
+// nothing that is already in place.
+func Init(paths Paths, opts InitOptions) ([]InitStep, error) {
+	var steps []InitStep
+	root := paths.Root
+	action := InitCreated
+	if _, err := os.Stat(paths.Workspace); err == nil {
+		action = InitUnchanged
+	}
+	if err := Ensure(paths); err != nil {
+		return nil, err
+	}
+	steps = append(steps, InitStep{Path: relTo(root, paths.Workspace) + "/", Action: action})
+
+	if opts.ConfigPath != "" {
+		step := InitStep{Path: relTo(root, opts.ConfigPath), Action: InitUnchanged}
+		if _, err := os.Stat(opts.ConfigPath); errors.Is(err, fs.ErrNotExist) {
+			if err := os.WriteFile(opts.ConfigPath, []byte(opts.Config), 0o644); err != nil {
+				return nil, err
+			}
+			step.Action = InitCreated
+		} else if err != nil {
+			return nil, err
+		}
+		steps = append(steps, step)
+	}
+
+	suggestions, err := SuggestIgnores(root)
+	if err != nil {
+		return nil, err
+	}
+	var patterns []string
+	for _, s := range suggestions {
+		patterns = append(patterns, s.Pattern)
+	}
+	step, err := appendLines(root, ".scryignore", "# Dependency and build directories (added by scry init)", patterns)
+	if err != nil {
+		return nil, err
+	}
+	steps = append(steps, step)
+
+	// Indexes kept outside the work tree need no git ignore rule.
+	if _, ok := gitrepo.Find(root); ok && paths.Shared == "" {
+		step, err := initGitignore(root, opts.Gitignore)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+func initGitignore(root string, add bool) (InitStep, error) {
+	matcher, err := ignore.LoadOptions(root, ignore.Options{IncludeTests: true})
+	if err != nil {
+		return InitStep{}, err
+	}
+	if p, ignored := matcher.Explain(".scry", true); ignored && p.Source != ".scryignore" {
+		return InitStep{Path: ".gitignore", Action: InitUnchanged}, nil
+	}
+	if !add {
+		return InitStep{
+			Path:   ".gitignore",
+			Action: InitSkipped,
+			Detail: ".scry/ is not ignored by git; rerun with --gitignore to add it",
+		}, nil
+	}
+	return appendLines(root, ".gitignore", "# scry workspace", []string{".scry/"})
+}
+
+// appendLines adds the lines missing from the file name under root, below
+// header. Files that would stay empty are not created.
+func appendLines(root, name, header string, lines []string) (InitStep, error) {
+	path := filepath.Join(root, name)
+	step := InitStep{Path: name, Action: InitUnchanged}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return step, err
+	}
+	exists := err == nil
+	have := map[string]bool{}
+	for _, l := range strings.Split(string(data), "\n") {
+		have[strings.TrimSpace(l)] = true
+	}
+	var missing []string
+	for _, l := range lines {
+		if !have[l] {
+			missing = append(missing, l)
+		}
+	}
+	if len(missing) == 0 {
+		return step, nil
+	}
+	var buf bytes.Buffer
+	buf.Write(data)
+	if len(data) > 0 {
+		if !bytes.HasSuffix(data, []byte("\n")) {
+			buf.WriteByte('\n')
+		}
+		buf.WriteByte('\n')
+	}
+	buf.WriteString(header + "\n")
+	for _, l := range missing {
+		buf.WriteString(l + "\n")
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		return step, err
+	}
+	step.Action = InitCreated
+	if exists {
+		step.Action = InitUpdated
+	}
+	step.Patterns = missing
+	return step, nil
+}
+
+func relTo(root, path string) string {
+	if rel, err := filepath.Rel(root, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
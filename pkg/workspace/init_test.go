@@ -0,0 +1,66 @@
+package workspace
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInitIsIdempotent(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{".git", "vendor", "out"} {
+		if err := os.Mkdir(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+	}
+	files := map[string]string{
+		"go.mod":     "module x\n",
+		".gitignore": "out/",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	opts := InitOptions{ConfigPath: filepath.Join(root, ".scry.yml"), Config: "# starter\n"}
+
+	summarize := func(steps []InitStep) string {
+		var out []string
+		for _, s := range steps {
+			out = append(out, fmt.Sprintf("%s %s %v", s.Action, s.Path, s.Patterns))
+		}
+		return strings.Join(out, "; ")
+	}
+	steps, err := Init(Resolve(root), opts)
+	if err != nil {
+		t.Fatalf("init: %v", err)
+	}
+	want := "created .scry/ []; created .scry.yml []; created .scryignore [vendor/]; skipped .gitignore []"
+	if got := summarize(steps); got != want {
+		t.Fatalf("first run:\n got %s\nwant %s", got, want)
+	}
+
+	opts.Gitignore = true
+	if steps, err = Init(Resolve(root), opts); err != nil {
+		t.Fatalf("init: %v", err)
+	}
+	want = "unchanged .scry/ []; unchanged .scry.yml []; unchanged .scryignore []; updated .gitignore [.scry/]"
+	if got := summarize(steps); got != want {
+		t.Fatalf("second run:\n got %s\nwant %s", got, want)
+	}
+	data, err := os.ReadFile(filepath.Join(root, ".gitignore"))
+	if err != nil || !strings.HasPrefix(string(data), "out/\n\n") || !strings.HasSuffix(string(data), "\n.scry/\n") {
+		t.Fatalf("unexpected .gitignore: %q err=%v", data, err)
+	}
+
+	if steps, err = Init(Resolve(root), opts); err != nil {
+		t.Fatalf("init: %v", err)
+	}
+	for _, s := range steps {
+		if s.Action != InitUnchanged {
+			t.Fatalf("expected third run to change nothing, got %s", summarize(steps))
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package workspace
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Lock timing. A held lock is refreshed every LockRefresh; one that has not
+// been refreshed for LockStale, or whose process is gone, is stale and may
+// be taken over.
+var (
+	LockRefresh = 10 * time.Second
+	LockStale   = time.Minute
+	lockPoll    = 200 * time.Millisecond
+)
+
+// ErrLocked is matched by errors.Is for a *LockedError.
+var ErrLocked = errors.New("index is locked")
+
+// LockedError reports the live holder of the index lock.
+type LockedError struct {
+	PID   int
+	Host  string
+	Since time.Time
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("index is locked by pid %d on %s since %s", e.PID, e.Host, e.Since.Format(time.RFC3339))
+}
+
+func (e *LockedError) Is(target error) bool {
+	return target == ErrLocked
+}
+
+// Lock is an advisory lock on a workspace's index, held by index writers.
+// Readers do not take it; see metadata.OpenSnapshot.
+type Lock struct {
+	path string
+	// holder is the lock file content as created; only updated changes
+	// while the lock is held.
+	holder lockInfo
+	stop   chan struct{}
+	wg     sync.WaitGroup
+	once   sync.Once
+}
+
+// lockInfo is the content of the lock file.
+type lockInfo struct {
+	pid     int
+	host    string
+	started time.Time
+	updated time.Time
+}
+
+func (paths Paths) lockPath() string {
+	return filepath.Join(paths.Workspace, "index.lock")
+}
+
+// AcquireLock takes the index lock, replacing a stale one. It fails with a
+// *LockedError if another process holds it.
+func AcquireLock(paths Paths) (*Lock, error) {
+	if err := Ensure(paths); err != nil {
+		return nil, err
+	}
+	path := paths.lockPath()
+	for attempt := 0; attempt < 3; attempt++ {
+		now := time.Now()
+		info := lockInfo{pid: os.Getpid(), host: hostname(), started: now, updated: now}
+		err := createLock(path, info)
+		if err == nil {
+			l := &Lock{path: path, holder: info, stop: make(chan struct{})}
+			l.wg.Add(1)
+			go l.refresh()
+			return l, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		held, err := parseLock(path, data)
+		if err == nil && !held.stale(now) {
+			return nil, &LockedError{PID: held.pid, Host: held.host, Since: held.started}
+		}
+		// Stale, or unreadable because it was damaged.
+		if err := takeOver(path, data); err != nil {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("acquire index lock: %s keeps changing", path)
+}
+
+// createLock links a lock file holding info into place, only if there is
+// none. Lock files are written to a temporary file first so they are never
+// read half-written.
+func createLock(path string, info lockInfo) error {
+	tmp, err := writeTemp(path, info)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	return os.Link(tmp, path)
+}
+
+// replaceLock rewrites the lock file of a holder.
+func replaceLock(path string, info lockInfo) error {
+	tmp, err := writeTemp(path, info)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+func writeTemp(path string, info lockInfo) (string, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return "", err
+	}
+	_, err = tmp.WriteString(info.String())
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+var claimSeq atomic.Int64
+
+// takeOver removes the stale lock file whose content was data. It is
+// renamed away first: of several processes taking over the same lock only
+// one moves it, and one that finds it moved a lock created since puts that
+// lock back. If yet another lock has been created meanwhile, the moved one
+// cannot be put back and takeOver fails rather than leave two holders.
+func takeOver(path string, data []byte) error {
+	claim := fmt.Sprintf("%s.%d-%d.stale", path, os.Getpid(), claimSeq.Add(1))
+	if err := os.Rename(path, claim); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer os.Remove(claim)
+	moved, err := os.ReadFile(claim)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(moved, data) {
+		return nil
+	}
+	if err := os.Link(claim, path); err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("acquire index lock: %s was replaced while taking over a stale lock", path)
+		}
+		return err
+	}
+	return nil
+}
+
+// WaitLock is AcquireLock retried until the lock is free or ctx is done.
+func WaitLock(ctx context.Context, paths Paths) (*Lock, error) {
+	for {
+		l, err := AcquireLock(paths)
+		if !errors.Is(err, ErrLocked) {
+			return l, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lockPoll):
+		}
+	}
+}
+
+// Release stops refreshing and removes the lock file, unless the lock was
+// taken over while this holder stalled: then the new holder's lock is left
+// alone and Release reports it.
+func (l *Lock) Release() error {
+	var err error
+	l.once.Do(func() {
+		close(l.stop)
+		l.wg.Wait()
+		if err = l.owned(); err == nil {
+			err = os.Remove(l.path)
+		}
+	})
+	return err
+}
+
+// refresh updates the lock file every LockRefresh while the lock is still
+// this holder's.
+func (l *Lock) refresh() {
+	defer l.wg.Done()
+	ticker := time.NewTicker(LockRefresh)
+	defer ticker.Stop()
+	info := l.holder
+	for {
+		select {
+		case <-l.stop:
+			return
+		case now := <-ticker.C:
+			if l.owned() != nil {
+				return
+			}
+			info.updated = now
+			_ = replaceLock(l.path, info)
+		}
+	}
+}
+
+// owned returns an error unless the lock file still belongs to l.
+func (l *Lock) owned() error {
+	info, err := readLock(l.path)
+	if err != nil {
+		return err
+	}
+	if info.pid != l.holder.pid || info.host != l.holder.host || !info.started.Equal(l.holder.started) {
+		return fmt.Errorf("index lock %s was taken over by pid %d on %s", l.path, info.pid, info.host)
+	}
+	return nil
+}
+
+// stale reports whether the holder is gone: not refreshed within LockStale,
+// or, on this host, no longer running.
+func (i lockInfo) stale(now time.Time) bool {
+	if now.Sub(i.updated) > LockStale {
+		return true
+	}
+	return i.host == hostname() && !processAlive(i.pid)
+}
+
+func (i lockInfo) String() string {
+	return fmt.Sprintf("pid %d\nhost %s\nstarted %s\nupdated %s\n",
+		i.pid, i.host, i.started.Format(time.RFC3339Nano), i.updated.Format(time.RFC3339Nano))
+}
+
+func readLock(path string) (lockInfo, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return lockInfo{}, err
+	}
+	return parseLock(path, data)
+}
+
+func parseLock(path string, data []byte) (lockInfo, error) {
+	var err error
+	var info lockInfo
+	for _, line := range strings.Split(string(data), "\n") {
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "pid":
+			info.pid, err = strconv.Atoi(value)
+		case "host":
+			info.host = value
+		case "started":
+			info.started, err = time.Parse(time.RFC3339Nano, value)
+		case "updated":
+			info.updated, err = time.Parse(time.RFC3339Nano, value)
+		}
+		if err != nil {
+			return lockInfo{}, fmt.Errorf("malformed lock file %s: %w", path, err)
+		}
+	}
+	if info.pid == 0 || info.updated.IsZero() {
+		return lockInfo{}, fmt.Errorf("malformed lock file %s", path)
+	}
+	return info, nil
+}
+
+func hostname() string {
+	name, err := os.Hostname()
+	if err != nil {
+		return "unknown"
+	}
+	return name
+}
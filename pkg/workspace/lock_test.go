@@ -0,0 +1,226 @@
+package workspace
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAcquireLockExcludesSecondWriter(t *testing.T) {
+	paths := Resolve(t.TempDir())
+	lock, err := AcquireLock(paths)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	_, err = AcquireLock(paths)
+	var locked *LockedError
+	if !errors.As(err, &locked) || !errors.Is(err, ErrLocked) || locked.PID != os.Getpid() {
+		t.Fatalf("expected locked error naming this process, got %v", err)
+	}
+	if err := lock.Release(); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	lock, err = AcquireLock(paths)
+	if err != nil {
+		t.Fatalf("reacquire: %v", err)
+	}
+	_ = lock.Release()
+}
+
+func writeLock(t *testing.T, paths Paths, info lockInfo) {
+	t.Helper()
+	if err := Ensure(paths); err != nil {
+		t.Fatalf("ensure: %v", err)
+	}
+	if err := os.WriteFile(paths.lockPath(), []byte(info.String()), 0o644); err != nil {
+		t.Fatalf("write lock: %v", err)
+	}
+}
+
+func deadPID(t *testing.T) int {
+	t.Helper()
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Skipf("cannot start a process: %v", err)
+	}
+	return cmd.Process.Pid
+}
+
+func TestAcquireLockReplacesStaleLocks(t *testing.T) {
+	now := time.Now()
+	cases := map[string]lockInfo{
+		"dead process":  {pid: deadPID(t), host: hostname(), started: now, updated: now},
+		"not refreshed": {pid: os.Getpid(), host: "elsewhere", started: now.Add(-time.Hour), updated: now.Add(-2 * LockStale)},
+	}
+	for name, info := range cases {
+		paths := Resolve(t.TempDir())
+		writeLock(t, paths, info)
+		lock, err := AcquireLock(paths)
+		if err != nil {
+			t.Fatalf("%s: expected stale lock replaced, got %v", name, err)
+		}
+		_ = lock.Release()
+	}
+
+	paths := Resolve(t.TempDir())
+	writeLock(t, paths, lockInfo{pid: os.Getpid(), host: "elsewhere", started: now, updated: now})
+	if _, err := AcquireLock(paths); !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected a fresh lock from another host to be honoured, got %v", err)
+	}
+
+	if err := os.WriteFile(paths.lockPath(), []byte("garbage"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	lock, err := AcquireLock(paths)
+	if err != nil {
+		t.Fatalf("expected malformed lock replaced, got %v", err)
+	}
+	_ = lock.Release()
+}
+
+func TestAcquireLockConcurrentTakeover(t *testing.T) {
+	for round := 0; round < 20; round++ {
+		paths := Resolve(t.TempDir())
+		writeLock(t, paths, lockInfo{pid: os.Getpid(), host: "elsewhere", started: time.Now().Add(-time.Hour), updated: time.Now().Add(-2 * LockStale)})
+		const takers = 8
+		var (
+			wg    sync.WaitGroup
+			mu    sync.Mutex
+			held  []*Lock
+			start = make(chan struct{})
+		)
+		for i := 0; i < takers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+				lock, err := AcquireLock(paths)
+				if err != nil {
+					if !errors.Is(err, ErrLocked) {
+						t.Errorf("acquire: %v", err)
+					}
+					return
+				}
+				mu.Lock()
+				held = append(held, lock)
+				mu.Unlock()
+			}()
+		}
+		close(start)
+		wg.Wait()
+		if len(held) != 1 {
+			t.Fatalf("round %d: expected exactly one holder, got %d", round, len(held))
+		}
+		info, err := readLock(paths.lockPath())
+		if err != nil || info.host != hostname() {
+			t.Fatalf("round %d: expected the holder's lock in place, got %+v err=%v", round, info, err)
+		}
+		_ = held[0].Release()
+	}
+}
+
+func TestTakeOverKeepsNewerLock(t *testing.T) {
+	paths := Resolve(t.TempDir())
+	writeLock(t, paths, lockInfo{pid: deadPID(t), host: hostname(), started: time.Now(), updated: time.Now()})
+	// Two processes read the same stale lock; the first takes it over.
+	stale, err := os.ReadFile(paths.lockPath())
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	first, err := AcquireLock(paths)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	defer first.Release()
+	held, err := os.ReadFile(paths.lockPath())
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	// The second must leave the first's lock in place.
+	if err := takeOver(paths.lockPath(), stale); err != nil {
+		t.Fatalf("take over: %v", err)
+	}
+	if now, err := os.ReadFile(paths.lockPath()); err != nil || string(now) != string(held) {
+		t.Fatalf("expected the new lock kept, got %q err=%v", now, err)
+	}
+	if _, err := AcquireLock(paths); !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected the lock still held, got %v", err)
+	}
+}
+
+func TestLockRefreshesTimestamp(t *testing.T) {
+	defer func(old time.Duration) { LockRefresh = old }(LockRefresh)
+	LockRefresh = 10 * time.Millisecond
+	paths := Resolve(t.TempDir())
+	lock, err := AcquireLock(paths)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	defer lock.Release()
+	first, err := readLock(paths.lockPath())
+	if err != nil {
+		t.Fatalf("read lock: %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+		info, err := readLock(paths.lockPath())
+		if err == nil && info.updated.After(first.updated) {
+			if !info.started.Equal(first.started) {
+				t.Fatalf("expected start time kept, got %v then %v", first.started, info.started)
+			}
+			return
+		}
+	}
+	t.Fatalf("lock timestamp was not refreshed")
+}
+
+func TestLockLeavesTakenOverLockAlone(t *testing.T) {
+	defer func(old time.Duration) { LockRefresh = old }(LockRefresh)
+	LockRefresh = 10 * time.Millisecond
+	paths := Resolve(t.TempDir())
+	lock, err := AcquireLock(paths)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	// This holder stalled and another process took the lock over.
+	other := lockInfo{pid: os.Getpid() + 1, host: "elsewhere", started: time.Now(), updated: time.Now()}
+	writeLock(t, paths, other)
+	time.Sleep(5 * LockRefresh)
+	if info, err := readLock(paths.lockPath()); err != nil || info.host != other.host {
+		t.Fatalf("expected the new holder's lock kept by refresh, got %+v, %v", info, err)
+	}
+	if err := lock.Release(); err == nil {
+		t.Fatalf("expected release to report the lost lock")
+	}
+	if info, err := readLock(paths.lockPath()); err != nil || info.host != other.host {
+		t.Fatalf("expected the new holder's lock kept by release, got %+v, %v", info, err)
+	}
+}
+
+func TestWaitLock(t *testing.T) {
+	paths := Resolve(t.TempDir())
+	held, err := AcquireLock(paths)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		_ = held.Release()
+	}()
+	lock, err := WaitLock(context.Background(), paths)
+	if err != nil {
+		t.Fatalf("wait: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := WaitLock(ctx, paths); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected wait to give up with the context, got %v", err)
+	}
+	_ = lock.Release()
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package workspace
+
+// processAlive cannot probe processes here, so only the lock timestamp
+// decides staleness.
+func processAlive(pid int) bool {
+	return pid > 0
+}
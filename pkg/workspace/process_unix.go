@@ -0,0 +1,17 @@
+//go:build unix
+
+package workspace
+
+import (
+	"errors"
+	"syscall"
+)
+
+// processAlive reports whether pid exists. Permission errors mean it does.
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
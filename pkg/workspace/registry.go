@@ -0,0 +1,105 @@
+package workspace
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Repo is a repository registered for federated queries.
+type Repo struct {
+	Name string `json:"name"`
+	Root string `json:"root"`
+}
+
+// Registry is the user's list of repositories that search --all and
+// ask --repos query together.
+type Registry struct {
+	path  string
+	Repos []Repo `json:"repos"`
+}
+
+// LoadRegistry reads the registry stored at path. A missing file is an empty
+// registry.
+func LoadRegistry(path string) (*Registry, error) {
+	r := &Registry{path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return r, nil
+}
+
+// Add registers root under name. Names are used in --repos lists, so they
+// may not contain commas or spaces.
+func (r *Registry) Add(name, root string) error {
+	if name == "" || strings.ContainsAny(name, ", \t") {
+		return fmt.Errorf("invalid repository name %q", name)
+	}
+	for _, repo := range r.Repos {
+		if repo.Name == name {
+			return fmt.Errorf("repository %q is already registered (%s)", name, repo.Root)
+		}
+		if repo.Root == root {
+			return fmt.Errorf("%s is already registered as %q", root, repo.Name)
+		}
+	}
+	r.Repos = append(r.Repos, Repo{Name: name, Root: root})
+	return nil
+}
+
+// Remove unregisters the repository called name.
+func (r *Registry) Remove(name string) error {
+	for i, repo := range r.Repos {
+		if repo.Name == name {
+			r.Repos = append(r.Repos[:i], r.Repos[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown repository %q", name)
+}
+
+// Lookup returns the repositories called names, in that order.
+func (r *Registry) Lookup(names []string) ([]Repo, error) {
+	var out []Repo
+	for _, name := range names {
+		found := false
+		for _, repo := range r.Repos {
+			if repo.Name == name {
+				out = append(out, repo)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown repository %q; see `scry workspace list`", name)
+		}
+	}
+	return out, nil
+}
+
+// Save writes the registry back to the file it was loaded from.
+func (r *Registry) Save() error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return err
+	}
+	tmp := r.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, r.path)
+}
@@ -0,0 +1,116 @@
+package workspace
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"scry/pkg/gitrepo"
+)
+
+// Index locations.
+const (
+	// LocationRepo keeps the index in <root>/.scry.
+	LocationRepo = "repo"
+	// LocationCache keeps it in a per-user cache directory, outside the
+	// checkout.
+	LocationCache = "cache"
+)
+
+// Storage selects where a root's index lives. The zero value keeps it in
+// the repository.
+type Storage struct {
+	Location string
+	// CacheDir overrides the cache directory, DefaultCacheDir() when empty.
+	CacheDir string
+}
+
+// DefaultCacheDir returns $XDG_CACHE_HOME/scry, falling back to
+// ~/.cache/scry.
+func DefaultCacheDir() string {
+	dir := os.Getenv("XDG_CACHE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return filepath.Join(os.TempDir(), "scry-cache")
+		}
+		dir = filepath.Join(home, ".cache")
+	}
+	return filepath.Join(dir, "scry")
+}
+
+// ResolveStorage returns the workspace paths for root under st. In the
+// cache, indexes are grouped by repository identity: every worktree of a
+// git repository gets its own index in one directory per repository, named
+// after the repository's common git directory.
+func ResolveStorage(root string, st Storage) Paths {
+	if st.Location != LocationCache {
+		return Resolve(root)
+	}
+	cache := st.CacheDir
+	if cache == "" {
+		cache = DefaultCacheDir()
+	}
+	identity, name := root, filepath.Base(root)
+	if repo, ok := gitrepo.Find(root); ok {
+		common := repo.CommonDir()
+		identity = common
+		name = strings.TrimSuffix(filepath.Base(common), ".git")
+		if name == "" {
+			name = filepath.Base(filepath.Dir(common))
+		}
+		// Roots below the work tree top get their own group.
+		if rel, err := filepath.Rel(repo.WorkTree, root); err == nil && rel != "." {
+			identity += "\x00" + filepath.ToSlash(rel)
+		}
+	}
+	shared := filepath.Join(cache, storageKey(name, identity))
+	ws := filepath.Join(shared, storageKey(filepath.Base(root), root))
+	return Paths{
+		Root:        root,
+		Workspace:   ws,
+		IndexDBPath: filepath.Join(ws, "index.db"),
+		Shared:      shared,
+	}
+}
+
+func storageKey(name, identity string) string {
+	sum := sha256.Sum256([]byte(identity))
+	return name + "-" + hex.EncodeToString(sum[:6])
+}
+
+// SiblingIndexes lists the indexes of the other worktrees sharing paths'
+// repository directory, most recently updated first. It is empty for
+// in-repository storage.
+func SiblingIndexes(paths Paths) []string {
+	if paths.Shared == "" {
+		return nil
+	}
+	entries, err := os.ReadDir(paths.Shared)
+	if err != nil {
+		return nil
+	}
+	type candidate struct {
+		path  string
+		mtime int64
+	}
+	var found []candidate
+	for _, e := range entries {
+		db := filepath.Join(paths.Shared, e.Name(), "index.db")
+		if !e.IsDir() || filepath.Join(paths.Shared, e.Name()) == paths.Workspace {
+			continue
+		}
+		if info, err := os.Stat(db); err == nil {
+			found = append(found, candidate{db, info.ModTime().UnixNano()})
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].mtime > found[j].mtime })
+	out := make([]string, len(found))
+	for i, c := range found {
+		out[i] = c.path
+	}
+	return out
+}
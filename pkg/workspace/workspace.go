@@ -9,6 +9,9 @@ type Paths struct {
 	Root        string
 	Workspace   string
 	IndexDBPath string
+	// Shared holds the workspaces of every worktree of the repository when
+	// the index lives in the cache; empty otherwise.
+	Shared string
 }
 
 func Resolve(root string) Paths {
@@ -28,3 +31,25 @@ func Exists(paths Paths) bool {
 	_, err := os.Stat(paths.IndexDBPath)
 	return err == nil
 }
+
+// rootMarkers identify a workspace root: an existing workspace, a repo
+// config file or a git repository.
+var rootMarkers = []string{".scry", ".scry.yml", ".git"}
+
+// FindRoot returns the nearest directory at or above start that contains a
+// root marker. It returns start and false when there is none.
+func FindRoot(start string) (string, bool) {
+	dir := filepath.Clean(start)
+	for {
+		for _, m := range rootMarkers {
+			if _, err := os.Lstat(filepath.Join(dir, m)); err == nil {
+				return dir, true
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return start, false
+		}
+		dir = parent
+	}
+}
@@ -3,6 +3,7 @@ package workspace
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -39,3 +40,148 @@ func TestEnsureAndExists(t *testing.T) {
 		t.Fatalf("expected index db to exist")
 	}
 }
+
+func TestFindRoot(t *testing.T) {
+	top := t.TempDir()
+	repo := filepath.Join(top, "repo")
+	deep := filepath.Join(repo, "pkg", "scan")
+	if err := os.MkdirAll(deep, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if root, ok := FindRoot(deep); ok || root != deep {
+		t.Fatalf("expected no root found, got %s %v", root, ok)
+	}
+	if err := os.Mkdir(filepath.Join(repo, ".git"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if root, ok := FindRoot(deep); !ok || root != repo {
+		t.Fatalf("expected git root %s, got %s %v", repo, root, ok)
+	}
+	// A nearer workspace or config file wins over an outer repository.
+	sub := filepath.Join(repo, "pkg")
+	if err := os.WriteFile(filepath.Join(sub, ".scry.yml"), nil, 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if root, ok := FindRoot(deep); !ok || root != sub {
+		t.Fatalf("expected config root %s, got %s %v", sub, root, ok)
+	}
+	if err := Ensure(Resolve(deep)); err != nil {
+		t.Fatalf("ensure: %v", err)
+	}
+	if root, ok := FindRoot(deep); !ok || root != deep {
+		t.Fatalf("expected workspace root %s, got %s %v", deep, root, ok)
+	}
+}
+
+// makeWorktrees creates a git repository at main and a linked worktree at wt.
+func makeWorktrees(t *testing.T) (main, wt string) {
+	t.Helper()
+	top := t.TempDir()
+	main = filepath.Join(top, "proj")
+	wt = filepath.Join(top, "proj-feature")
+	gitWT := filepath.Join(main, ".git", "worktrees", "feature")
+	for _, dir := range []string{gitWT, wt} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(gitWT, "commondir"), []byte("../..\n"), 0o644); err != nil {
+		t.Fatalf("write commondir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(wt, ".git"), []byte("gitdir: "+gitWT+"\n"), 0o644); err != nil {
+		t.Fatalf("write .git: %v", err)
+	}
+	return main, wt
+}
+
+func TestResolveStorage(t *testing.T) {
+	main, wt := makeWorktrees(t)
+	if paths := ResolveStorage(main, Storage{}); paths != Resolve(main) {
+		t.Fatalf("repo storage: %+v", paths)
+	}
+	cache := t.TempDir()
+	st := Storage{Location: LocationCache, CacheDir: cache}
+	a, b := ResolveStorage(main, st), ResolveStorage(wt, st)
+	if a.Shared == "" || a.Shared != b.Shared {
+		t.Fatalf("worktrees should share a directory: %q, %q", a.Shared, b.Shared)
+	}
+	if filepath.Dir(a.Shared) != cache || !strings.HasPrefix(filepath.Base(a.Shared), "proj-") {
+		t.Fatalf("unexpected shared dir: %s", a.Shared)
+	}
+	if a.Workspace == b.Workspace || filepath.Dir(a.Workspace) != a.Shared {
+		t.Fatalf("worktrees should have their own workspaces: %q, %q", a.Workspace, b.Workspace)
+	}
+	if a.IndexDBPath != filepath.Join(a.Workspace, "index.db") {
+		t.Fatalf("unexpected index path: %s", a.IndexDBPath)
+	}
+	if again := ResolveStorage(main, st); again != a {
+		t.Fatalf("resolution not stable: %+v vs %+v", again, a)
+	}
+	other := ResolveStorage(t.TempDir(), st)
+	if other.Shared == a.Shared {
+		t.Fatalf("unrelated root shares %s", a.Shared)
+	}
+}
+
+func TestSiblingIndexes(t *testing.T) {
+	main, wt := makeWorktrees(t)
+	st := Storage{Location: LocationCache, CacheDir: t.TempDir()}
+	a, b := ResolveStorage(main, st), ResolveStorage(wt, st)
+	if got := SiblingIndexes(b); len(got) != 0 {
+		t.Fatalf("expected no siblings, got %v", got)
+	}
+	for _, p := range []Paths{a, b} {
+		if err := Ensure(p); err != nil {
+			t.Fatalf("ensure: %v", err)
+		}
+		if err := os.WriteFile(p.IndexDBPath, nil, 0o644); err != nil {
+			t.Fatalf("write index: %v", err)
+		}
+	}
+	if got := SiblingIndexes(b); len(got) != 1 || got[0] != a.IndexDBPath {
+		t.Fatalf("unexpected siblings: %v", got)
+	}
+	if got := SiblingIndexes(Resolve(main)); got != nil {
+		t.Fatalf("repo storage has no siblings, got %v", got)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scry", "repos.json")
+	reg, err := LoadRegistry(path)
+	if err != nil || len(reg.Repos) != 0 {
+		t.Fatalf("expected empty registry, got %+v, %v", reg, err)
+	}
+	if err := reg.Add("api", "/src/api"); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	if err := reg.Add("web", "/src/web"); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	for _, bad := range [][2]string{{"api", "/src/other"}, {"other", "/src/api"}, {"a,b", "/src/ab"}, {"", "/src/x"}} {
+		if err := reg.Add(bad[0], bad[1]); err == nil {
+			t.Fatalf("expected error adding %q at %s", bad[0], bad[1])
+		}
+	}
+	if err := reg.Save(); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	reg, err = LoadRegistry(path)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	repos, err := reg.Lookup([]string{"web", "api"})
+	if err != nil || len(repos) != 2 || repos[0].Root != "/src/web" || repos[1].Root != "/src/api" {
+		t.Fatalf("unexpected lookup: %+v, %v", repos, err)
+	}
+	if _, err := reg.Lookup([]string{"nope"}); err == nil {
+		t.Fatalf("expected unknown repository error")
+	}
+	if err := reg.Remove("api"); err != nil || len(reg.Repos) != 1 {
+		t.Fatalf("remove: %+v, %v", reg.Repos, err)
+	}
+	if err := reg.Remove("api"); err == nil {
+		t.Fatalf("expected error removing unknown repository")
+	}
+}